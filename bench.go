@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// benchResult is the outcome of one timed import.
+type benchResult struct {
+	batchSize   int
+	concurrency int
+	stats       lnd.WriteStats
+}
+
+// parseIntList parses a comma-separated list of positive integers.
+func parseIntList(name, s string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("-%s must be a comma-separated list of positive integers, got %q", name, s)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// rate formats n per d as a per-second rate.
+func rate(n int, d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", float64(n)/d.Seconds())
+}
+
+// runBench implements the bench command: it imports a snapshot through the
+// batched write path once per combination of batch size and concurrency
+// (times -runs), dropping the benchmark namespace before each import, and
+// prints nodes/sec and edges/sec for each. Returns the exit code.
+func runBench(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	batchSizes := flags.String("batch-sizes", "100,500,1000", "comma-separated batch sizes to measure")
+	concurrency := flags.String("concurrency", "1,4,8", "comma-separated write concurrency settings to measure")
+	runs := flags.Int("runs", 1, "imports per setting")
+	namespace := flags.String("namespace", "bench", "namespace to import into; it is dropped before every run and at the end")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lnstream bench [flags] <describegraph.json>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *runs < 1 {
+		flags.Usage()
+		return 2
+	}
+	sizes, err := parseIntList("batch-sizes", *batchSizes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	levels, err := parseIntList("concurrency", *concurrency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return bench(flags.Arg(0), *namespace, sizes, levels, *runs)
+}
+
+// bench runs the measurements configured by runBench.
+func bench(path, namespace string, sizes, levels []int, runs int) int {
+	snapshot, err := lnd.ReadSnapshot(path)
+	if err != nil {
+		log.Printf("Failed to read snapshot: %v", err)
+		return 1
+	}
+	snapshot, report := lnd.ValidateSnapshot(snapshot)
+	graph, err := snapshot.LndGraph()
+	if err != nil {
+		log.Printf("Failed to convert snapshot: %v", err)
+		return 1
+	}
+	log.Printf("Benchmarking %s: %d nodes, %d channels (%d invalid records skipped)", path,
+		report.ValidNodes, report.ValidEdges, report.InvalidNodes+report.InvalidEdges)
+
+	driver, err := memgraph.ConnectNeo4j()
+	if err != nil {
+		log.Printf("Failed to connect to Neo4j: %v", err)
+		return 1
+	}
+	defer memgraph.CloseDriver(driver)
+
+	// Interrupting stops at the next batch; the namespace is still dropped.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []benchResult
+	for _, size := range sizes {
+		for _, level := range levels {
+			for run := 0; run < runs; run++ {
+				if err := memgraph.DropNamespace(ctx, driver, namespace); err != nil {
+					log.Printf("Failed to drop namespace %q: %v", namespace, err)
+					return 1
+				}
+				lnd.BatchSize, lnd.Concurrency = size, level
+				stats, err := lnd.WriteGraphToMemgraph(ctx, graph, driver, namespace)
+				if err != nil {
+					log.Printf("Import failed (batch size %d, concurrency %d): %v", size, level, err)
+					memgraph.DropNamespace(context.Background(), driver, namespace)
+					return 1
+				}
+				results = append(results, benchResult{batchSize: size, concurrency: level, stats: stats})
+			}
+		}
+	}
+	if err := memgraph.DropNamespace(context.Background(), driver, namespace); err != nil {
+		log.Printf("Failed to drop namespace %q: %v", namespace, err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "batch size\tconcurrency\tnodes\tnode time\tnodes/s\tedges\tedge time\tedges/s\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%d\t%s\t%s\t\n", r.batchSize, r.concurrency,
+			r.stats.Nodes, r.stats.NodeTime.Round(time.Millisecond), rate(r.stats.Nodes, r.stats.NodeTime),
+			r.stats.Edges, r.stats.EdgeTime.Round(time.Millisecond), rate(r.stats.Edges, r.stats.EdgeTime))
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,280 @@
+// Package capture archives the raw graph update stream as NDJSON files, one
+// received update per line, so that gossip can be analyzed with external
+// tools independently of what reaches the database.
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"ln-stream/lnd"
+)
+
+// bufferSize is how many received updates may wait to be written before
+// further ones are dropped.
+const bufferSize = 10000
+
+// flushInterval is how often buffered lines are written out, so that files
+// can be followed while they grow.
+const flushInterval = time.Second
+
+// Config configures a Recorder. Files are rotated when they are older than
+// RotateEvery or larger than MaxBytes (uncompressed); zero disables either
+// limit.
+type Config struct {
+	Dir         string
+	RotateEvery time.Duration
+	MaxBytes    int64
+	Compress    bool
+}
+
+// Record is one line of a capture file: a graph update as received from a
+// source, before coalescing and before it is written to the database.
+type Record struct {
+	ReceivedAt     time.Time       `json:"received_at"`
+	Source         string          `json:"source"`
+	NodeUpdates    []NodeUpdate    `json:"node_updates,omitempty"`
+	ChannelUpdates []ChannelUpdate `json:"channel_updates,omitempty"`
+	ChannelCloses  []ChannelClose  `json:"channel_closes,omitempty"`
+}
+
+// NodeUpdate is a node announcement.
+type NodeUpdate struct {
+	PubKey    string   `json:"pubkey"`
+	Alias     string   `json:"alias"`
+	Color     string   `json:"color"`
+	Addresses []string `json:"addresses"`
+	Features  []int64  `json:"features"`
+}
+
+// ChannelUpdate is one direction's channel update. ChannelID is in the
+// block x index x output form stored on edges and SCID its integer form.
+type ChannelUpdate struct {
+	ChannelID        string `json:"channel_id"`
+	SCID             uint64 `json:"scid"`
+	ChanPoint        string `json:"chan_point,omitempty"`
+	Capacity         int64  `json:"capacity"`
+	AdvertisingNode  string `json:"advertising_node"`
+	ConnectingNode   string `json:"connecting_node"`
+	TimeLockDelta    uint32 `json:"time_lock_delta"`
+	MinHtlcMsat      int64  `json:"min_htlc_msat"`
+	MaxHtlcMsat      uint64 `json:"max_htlc_msat"`
+	FeeBaseMsat      int64  `json:"fee_base_msat"`
+	FeeRateMilliMsat int64  `json:"fee_rate_milli_msat"`
+	Disabled         bool   `json:"disabled"`
+	LastUpdate       int64  `json:"last_update"`
+}
+
+// ChannelClose is a channel close.
+type ChannelClose struct {
+	ChannelID    string `json:"channel_id"`
+	SCID         uint64 `json:"scid"`
+	ChanPoint    string `json:"chan_point,omitempty"`
+	Capacity     int64  `json:"capacity"`
+	ClosedHeight uint32 `json:"closed_height"`
+}
+
+// Recorder writes received updates to rotated capture files in the
+// background. Call Run to start writing.
+type Recorder struct {
+	config  Config
+	records chan Record
+	dropped atomic.Bool
+
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	size    int64
+	started time.Time
+}
+
+// New returns a Recorder writing to config.Dir, which is created if needed.
+func New(config Config) (*Recorder, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &Recorder{config: config, records: make(chan Record, bufferSize)}, nil
+}
+
+// Record queues an update from the named source for writing without
+// blocking. If the writer has fallen too far behind, the update is dropped.
+func (r *Recorder) Record(source string, update *lndclient.GraphTopologyUpdate) {
+	select {
+	case r.records <- newRecord(source, update, time.Now().UTC()):
+	default:
+		if r.dropped.CompareAndSwap(false, true) {
+			log.Printf("Gossip capture is falling behind, dropping updates")
+		}
+	}
+}
+
+// Run writes queued updates until stop is closed, then closes the current
+// file.
+func (r *Recorder) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	defer r.close()
+	for {
+		select {
+		case record := <-r.records:
+			if err := r.write(record); err != nil {
+				log.Printf("Failed to write gossip capture: %v", err)
+				r.close()
+			}
+		case <-ticker.C:
+			if r.w != nil {
+				if err := r.flush(); err != nil {
+					log.Printf("Failed to write gossip capture: %v", err)
+					r.close()
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// write appends a record to the current file, rotating it first if needed.
+func (r *Recorder) write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode update: %w", err)
+	}
+	line = append(line, '\n')
+	if r.w != nil && r.due(int64(len(line))) {
+		r.close()
+	}
+	if r.w == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	n, err := r.w.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// due reports whether the current file must be rotated before a line of the
+// given length is written.
+func (r *Recorder) due(length int64) bool {
+	if r.config.RotateEvery > 0 && time.Since(r.started) >= r.config.RotateEvery {
+		return true
+	}
+	return r.config.MaxBytes > 0 && r.size > 0 && r.size+length > r.config.MaxBytes
+}
+
+// open starts a new capture file named after the current time.
+func (r *Recorder) open() error {
+	r.started = time.Now().UTC()
+	name := "gossip-" + r.started.Format("20060102T150405Z") + ".ndjson"
+	if r.config.Compress {
+		name += ".gz"
+	}
+	file, err := os.OpenFile(filepath.Join(r.config.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create capture file: %w", err)
+	}
+	var w io.Writer = file
+	if r.config.Compress {
+		r.gz = gzip.NewWriter(file)
+		w = r.gz
+	}
+	r.file, r.w, r.size = file, bufio.NewWriter(w), 0
+	log.Printf("Capturing gossip to %s", file.Name())
+	return nil
+}
+
+// flush writes buffered lines to the file.
+func (r *Recorder) flush() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if r.gz != nil {
+		return r.gz.Flush()
+	}
+	return nil
+}
+
+// close flushes and closes the current file, if any.
+func (r *Recorder) close() {
+	if r.file == nil {
+		return
+	}
+	if err := r.w.Flush(); err != nil {
+		log.Printf("Failed to write gossip capture: %v", err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Close(); err != nil {
+			log.Printf("Failed to write gossip capture: %v", err)
+		}
+	}
+	if err := r.file.Close(); err != nil {
+		log.Printf("Failed to close gossip capture: %v", err)
+	}
+	r.file, r.gz, r.w = nil, nil, nil
+}
+
+// newRecord converts an update to its capture form.
+func newRecord(source string, update *lndclient.GraphTopologyUpdate, at time.Time) Record {
+	record := Record{ReceivedAt: at, Source: source}
+	for _, u := range update.NodeUpdates {
+		record.NodeUpdates = append(record.NodeUpdates, NodeUpdate{
+			PubKey:    u.IdentityKey.String(),
+			Alias:     u.Alias,
+			Color:     u.Color,
+			Addresses: u.Addresses,
+			Features:  lnd.FeatureBits(u.Features),
+		})
+	}
+	for _, u := range update.ChannelEdgeUpdates {
+		record.ChannelUpdates = append(record.ChannelUpdates, ChannelUpdate{
+			ChannelID:        channelID(u.ChannelID),
+			SCID:             u.ChannelID.ToUint64(),
+			ChanPoint:        chanPoint(u.ChannelPoint),
+			Capacity:         int64(u.Capacity),
+			AdvertisingNode:  u.AdvertisingNode.String(),
+			ConnectingNode:   u.ConnectingNode.String(),
+			TimeLockDelta:    u.RoutingPolicy.TimeLockDelta,
+			MinHtlcMsat:      u.RoutingPolicy.MinHtlcMsat,
+			MaxHtlcMsat:      u.RoutingPolicy.MaxHtlcMsat,
+			FeeBaseMsat:      u.RoutingPolicy.FeeBaseMsat,
+			FeeRateMilliMsat: u.RoutingPolicy.FeeRateMilliMsat,
+			Disabled:         u.RoutingPolicy.Disabled,
+			LastUpdate:       u.RoutingPolicy.LastUpdate.Unix(),
+		})
+	}
+	for _, u := range update.ChannelCloseUpdates {
+		record.ChannelCloses = append(record.ChannelCloses, ChannelClose{
+			ChannelID:    channelID(u.ChannelID),
+			SCID:         u.ChannelID.ToUint64(),
+			ChanPoint:    chanPoint(u.ChannelPoint),
+			Capacity:     int64(u.Capacity),
+			ClosedHeight: u.ClosedHeight,
+		})
+	}
+	return record
+}
+
+// channelID formats a short channel ID as block x index x output.
+func channelID(id lnwire.ShortChannelID) string {
+	return fmt.Sprintf("%dx%dx%d", id.BlockHeight, id.TxIndex, id.TxPosition)
+}
+
+// chanPoint formats a funding outpoint, or returns "" if it is unknown.
+func chanPoint(outpoint wire.OutPoint) string {
+	if outpoint == (wire.OutPoint{}) {
+		return ""
+	}
+	return outpoint.String()
+}
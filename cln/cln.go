@@ -0,0 +1,216 @@
+// Package cln converts Core Lightning graph data into the snapshot format used
+// by the lnd package, so CLN users can run ln-stream in snapshot-only mode.
+package cln
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"ln-stream/gossip"
+	"ln-stream/lnd"
+)
+
+// listNodesOutput is the result of `lightning-cli listnodes`.
+type listNodesOutput struct {
+	Nodes []struct {
+		NodeID        string `json:"nodeid"`
+		Alias         string `json:"alias"`
+		Color         string `json:"color"`
+		LastTimestamp int64  `json:"last_timestamp"`
+		Features      string `json:"features"`
+		Addresses     []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+			Port    int    `json:"port"`
+		} `json:"addresses"`
+	} `json:"nodes"`
+}
+
+// channelDirection is one entry of `lightning-cli listchannels`. CLN reports
+// each direction of a channel as a separate entry.
+type channelDirection struct {
+	Source              string          `json:"source"`
+	Destination         string          `json:"destination"`
+	ShortChannelID      string          `json:"short_channel_id"`
+	Direction           int             `json:"direction"`
+	AmountMsat          json.RawMessage `json:"amount_msat"`
+	Active              bool            `json:"active"`
+	LastUpdate          int64           `json:"last_update"`
+	BaseFeeMillisatoshi int64           `json:"base_fee_millisatoshi"`
+	FeePerMillionth     int64           `json:"fee_per_millionth"`
+	Delay               int             `json:"delay"`
+	HtlcMinimumMsat     json.RawMessage `json:"htlc_minimum_msat"`
+	HtlcMaximumMsat     json.RawMessage `json:"htlc_maximum_msat"`
+}
+
+// listChannelsOutput is the result of `lightning-cli listchannels`.
+type listChannelsOutput struct {
+	Channels []channelDirection `json:"channels"`
+}
+
+// parseMsat decodes a CLN millisatoshi amount, which is a plain number in
+// recent releases and a "1000msat" string in older ones.
+func parseMsat(raw json.RawMessage) (int64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	s := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, err
+		}
+		s = strings.TrimSuffix(s, "msat")
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ParseShortChannelID converts a CLN "BLOCKxTXxOUT" short channel ID into its
+// compact uint64 form.
+func ParseShortChannelID(scid string) (uint64, error) {
+	parts := strings.Split(scid, "x")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("malformed short channel id %q", scid)
+	}
+	block, err := strconv.ParseUint(parts[0], 10, 24)
+	if err != nil {
+		return 0, fmt.Errorf("malformed short channel id %q: %w", scid, err)
+	}
+	tx, err := strconv.ParseUint(parts[1], 10, 24)
+	if err != nil {
+		return 0, fmt.Errorf("malformed short channel id %q: %w", scid, err)
+	}
+	out, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("malformed short channel id %q: %w", scid, err)
+	}
+	return block<<40 | tx<<16 | out, nil
+}
+
+// featureBits expands a hex-encoded feature bitfield into the map form used
+// by LND snapshots, keyed by bit number.
+func featureBits(features string) map[string]interface{} {
+	raw, err := hex.DecodeString(features)
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	return gossip.FeatureMap(raw)
+}
+
+// convertPolicy maps one CLN channel direction to an LND routing policy.
+func convertPolicy(dir channelDirection) (lnd.RoutingPolicy, error) {
+	minHtlc, err := parseMsat(dir.HtlcMinimumMsat)
+	if err != nil {
+		return lnd.RoutingPolicy{}, fmt.Errorf("channel %s: htlc_minimum_msat: %w", dir.ShortChannelID, err)
+	}
+	maxHtlc, err := parseMsat(dir.HtlcMaximumMsat)
+	if err != nil {
+		return lnd.RoutingPolicy{}, fmt.Errorf("channel %s: htlc_maximum_msat: %w", dir.ShortChannelID, err)
+	}
+	return lnd.RoutingPolicy{
+		TimeLockDelta:    dir.Delay,
+		MinHtlc:          strconv.FormatInt(minHtlc, 10),
+		FeeBaseMsat:      strconv.FormatInt(dir.BaseFeeMillisatoshi, 10),
+		FeeRateMilliMsat: strconv.FormatInt(dir.FeePerMillionth, 10),
+		Disabled:         !dir.Active,
+		MaxHtlcMsat:      strconv.FormatInt(maxHtlc, 10),
+		LastUpdate:       int(dir.LastUpdate),
+	}, nil
+}
+
+// convertGraph maps decoded listnodes/listchannels output into an lnd.Graph.
+// Channel directions are paired by short channel ID; direction 0 is node1.
+// Channels with malformed IDs are kept with an empty ID so that
+// lnd.ValidateSnapshot reports them instead of them disappearing silently.
+func convertGraph(nodes listNodesOutput, channels listChannelsOutput) (*lnd.Graph, error) {
+	graph := &lnd.Graph{}
+
+	for _, n := range nodes.Nodes {
+		addresses := make([]interface{}, 0, len(n.Addresses))
+		for _, addr := range n.Addresses {
+			addresses = append(addresses, map[string]interface{}{
+				"network": "tcp",
+				"addr":    net.JoinHostPort(addr.Address, strconv.Itoa(addr.Port)),
+			})
+		}
+		color := n.Color
+		if color != "" && !strings.HasPrefix(color, "#") {
+			color = "#" + color
+		}
+		graph.Nodes = append(graph.Nodes, lnd.Node{
+			Pub_Key:    n.NodeID,
+			LastUpdate: n.LastTimestamp,
+			Alias:      n.Alias,
+			Color:      color,
+			Features:   featureBits(n.Features),
+			Addresses:  addresses,
+		})
+	}
+
+	index := map[string]int{}
+	for _, dir := range channels.Channels {
+		policy, err := convertPolicy(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[dir.ShortChannelID]
+		if !ok {
+			capacityMsat, err := parseMsat(dir.AmountMsat)
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: amount_msat: %w", dir.ShortChannelID, err)
+			}
+			edge := lnd.ChannelEdge{Capacity: strconv.FormatInt(capacityMsat/1000, 10)}
+			if scid, err := ParseShortChannelID(dir.ShortChannelID); err == nil {
+				edge.ChannelId = strconv.FormatUint(scid, 10)
+			}
+			graph.Edges = append(graph.Edges, edge)
+			i = len(graph.Edges) - 1
+			index[dir.ShortChannelID] = i
+		}
+
+		edge := &graph.Edges[i]
+		if dir.Direction == 0 {
+			edge.Node1_Pub, edge.Node2_Pub = dir.Source, dir.Destination
+			edge.Node1Policy = policy
+		} else {
+			edge.Node1_Pub, edge.Node2_Pub = dir.Destination, dir.Source
+			edge.Node2Policy = policy
+		}
+		if dir.LastUpdate > edge.LastUpdate {
+			edge.LastUpdate = dir.LastUpdate
+		}
+	}
+
+	return graph, nil
+}
+
+// readJSON decodes a JSON file into v.
+func readJSON(filename string, v interface{}) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ReadSnapshot loads the JSON output of `lightning-cli listnodes` and
+// `lightning-cli listchannels` and converts it into an lnd.Graph.
+func ReadSnapshot(listNodesFilename, listChannelsFilename string) (*lnd.Graph, error) {
+	var nodes listNodesOutput
+	if err := readJSON(listNodesFilename, &nodes); err != nil {
+		return nil, err
+	}
+	var channels listChannelsOutput
+	if err := readJSON(listChannelsFilename, &channels); err != nil {
+		return nil, err
+	}
+	return convertGraph(nodes, channels)
+}
@@ -0,0 +1,127 @@
+package cln
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"ln-stream/gossip"
+	"ln-stream/lnd"
+)
+
+// gossip_store record flags (see common/gossip_store.h in Core Lightning).
+const (
+	gossipStoreDeleted = 0x8000
+
+	// gossipStoreMajorVersionMask selects the major version bits of the
+	// version byte. Only major version 0 (versions 12 and later) is supported.
+	gossipStoreMajorVersionMask = 0xE0
+	gossipStoreMinVersion       = 12
+)
+
+// Store-internal message types found in a gossip_store alongside the BOLT 7
+// gossip messages.
+const (
+	msgChannelAmount = 4101
+	msgDeleteChannel = 4103
+)
+
+// ReadGossipStore reconstructs the channel graph from a Core Lightning
+// gossip_store file, keeping the latest node announcement per node and the
+// latest channel_update per channel direction. Deleted records and
+// messages that cannot be parsed are skipped. verify selects whether
+// messages with invalid signatures are kept, dropped or flagged; the
+// returned stats count them and the unparsable ones.
+func ReadGossipStore(filename string, verify gossip.VerifyMode) (*lnd.Graph, gossip.VerifyStats, error) {
+	builder, err := readGossipStore(filename, verify)
+	if err != nil {
+		return nil, gossip.VerifyStats{}, err
+	}
+	return builder.Graph(), builder.Invalid(), nil
+}
+
+// readGossipStore implements ReadGossipStore, returning the builder.
+func readGossipStore(filename string, verify gossip.VerifyMode) (*gossip.Builder, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gossip store: %w", err)
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gossip store version: %w", err)
+	}
+	if version&gossipStoreMajorVersionMask != 0 || version < gossipStoreMinVersion {
+		return nil, fmt.Errorf("unsupported gossip store version %d", version)
+	}
+
+	builder := gossip.NewBuilder()
+	builder.Verify = verify
+	var lastAnnounced uint64
+
+	header := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read gossip store record header: %w", err)
+		}
+		flags := binary.BigEndian.Uint16(header[0:2])
+		length := binary.BigEndian.Uint16(header[2:4])
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(reader, msg); err != nil {
+			return nil, fmt.Errorf("failed to read gossip store record: %w", err)
+		}
+		if flags&gossipStoreDeleted != 0 || len(msg) < 2 {
+			continue
+		}
+
+		body := msg[2:]
+		switch binary.BigEndian.Uint16(msg[:2]) {
+		case gossip.MsgChannelAnnouncement:
+			announcement, err := gossip.ParseChannelAnnouncement(body)
+			if err != nil {
+				// Keep its channel amount record from landing on the
+				// previous channel.
+				lastAnnounced = 0
+				builder.SkipMalformed()
+				continue
+			}
+			// Dropped announcements are counted by the builder.
+			_ = builder.AddChannelAnnouncement(announcement)
+			lastAnnounced = announcement.ShortChannelID
+		case msgChannelAmount:
+			// The channel amount record always follows its channel_announcement.
+			if len(body) >= 8 {
+				builder.SetCapacity(lastAnnounced, binary.BigEndian.Uint64(body))
+			}
+		case gossip.MsgChannelUpdate:
+			update, err := gossip.ParseChannelUpdate(body)
+			if err != nil {
+				builder.SkipMalformed()
+				continue
+			}
+			// Updates for channels whose announcement was deleted are ignored.
+			_ = builder.AddChannelUpdate(update)
+		case gossip.MsgNodeAnnouncement:
+			node, err := gossip.ParseNodeAnnouncement(body)
+			if err != nil {
+				builder.SkipMalformed()
+				continue
+			}
+			_ = builder.AddNodeAnnouncement(node)
+		case msgDeleteChannel:
+			if len(body) >= 8 {
+				builder.DeleteChannel(binary.BigEndian.Uint64(body))
+			}
+		}
+	}
+
+	return builder, nil
+}
@@ -0,0 +1,250 @@
+// Package gossip decodes raw BOLT 7 gossip messages (channel_announcement,
+// channel_update and node_announcement), verifies their signatures, and
+// converts them into the snapshot graph types used by the lnd package. It is
+// the foundation for ingestion paths that do not go through LND.
+package gossip
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// BOLT 7 gossip message types.
+const (
+	MsgChannelAnnouncement = 256
+	MsgNodeAnnouncement    = 257
+	MsgChannelUpdate       = 258
+)
+
+// ChannelAnnouncement is a decoded channel_announcement message.
+type ChannelAnnouncement struct {
+	NodeSignature1    [64]byte
+	NodeSignature2    [64]byte
+	BitcoinSignature1 [64]byte
+	BitcoinSignature2 [64]byte
+	Features          []byte
+	ChainHash         [32]byte
+	ShortChannelID    uint64
+	NodeID1           [33]byte
+	NodeID2           [33]byte
+	BitcoinKey1       [33]byte
+	BitcoinKey2       [33]byte
+
+	// signedData is the portion of the message covered by the signatures.
+	signedData []byte
+}
+
+// ChannelUpdate is a decoded channel_update message.
+type ChannelUpdate struct {
+	Signature                 [64]byte
+	ChainHash                 [32]byte
+	ShortChannelID            uint64
+	Timestamp                 uint32
+	MessageFlags              byte
+	ChannelFlags              byte
+	CltvExpiryDelta           uint16
+	HtlcMinimumMsat           uint64
+	FeeBaseMsat               uint32
+	FeeProportionalMillionths uint32
+	HtlcMaximumMsat           uint64
+
+	signedData []byte
+}
+
+// Direction returns 0 if the update was sent by node_id_1 and 1 otherwise.
+func (u *ChannelUpdate) Direction() int {
+	return int(u.ChannelFlags & 1)
+}
+
+// Disabled reports whether the update disables its channel direction.
+func (u *ChannelUpdate) Disabled() bool {
+	return u.ChannelFlags&2 != 0
+}
+
+// NodeAnnouncement is a decoded node_announcement message.
+type NodeAnnouncement struct {
+	Signature [64]byte
+	Features  []byte
+	Timestamp uint32
+	NodeID    [33]byte
+	RGBColor  [3]byte
+	Alias     [32]byte
+	Addresses []string
+
+	signedData []byte
+}
+
+// reader decodes big-endian Lightning wire fields from a message body.
+type reader struct {
+	buf []byte
+	err error
+}
+
+// next returns the next n bytes of the message, recording an error on underflow.
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u8() byte    { return r.next(1)[0] }
+func (r *reader) u16() uint16 { return binary.BigEndian.Uint16(r.next(2)) }
+func (r *reader) u32() uint32 { return binary.BigEndian.Uint32(r.next(4)) }
+func (r *reader) u64() uint64 { return binary.BigEndian.Uint64(r.next(8)) }
+
+// Parse decodes a gossip message including its 2-byte type prefix. It returns
+// a *ChannelAnnouncement, *ChannelUpdate or *NodeAnnouncement.
+func Parse(msg []byte) (interface{}, error) {
+	if len(msg) < 2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	body := msg[2:]
+	switch msgType := binary.BigEndian.Uint16(msg[:2]); msgType {
+	case MsgChannelAnnouncement:
+		return ParseChannelAnnouncement(body)
+	case MsgChannelUpdate:
+		return ParseChannelUpdate(body)
+	case MsgNodeAnnouncement:
+		return ParseNodeAnnouncement(body)
+	default:
+		return nil, fmt.Errorf("unsupported gossip message type %d", msgType)
+	}
+}
+
+// ParseChannelAnnouncement decodes a channel_announcement body (without the type prefix).
+func ParseChannelAnnouncement(body []byte) (*ChannelAnnouncement, error) {
+	r := &reader{buf: body}
+	a := &ChannelAnnouncement{}
+	copy(a.NodeSignature1[:], r.next(64))
+	copy(a.NodeSignature2[:], r.next(64))
+	copy(a.BitcoinSignature1[:], r.next(64))
+	copy(a.BitcoinSignature2[:], r.next(64))
+	signed := r.buf
+	a.Features = r.next(int(r.u16()))
+	copy(a.ChainHash[:], r.next(32))
+	a.ShortChannelID = r.u64()
+	copy(a.NodeID1[:], r.next(33))
+	copy(a.NodeID2[:], r.next(33))
+	copy(a.BitcoinKey1[:], r.next(33))
+	copy(a.BitcoinKey2[:], r.next(33))
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode channel_announcement: %w", r.err)
+	}
+	a.signedData = signed
+	return a, nil
+}
+
+// ParseChannelUpdate decodes a channel_update body (without the type prefix).
+func ParseChannelUpdate(body []byte) (*ChannelUpdate, error) {
+	r := &reader{buf: body}
+	u := &ChannelUpdate{}
+	copy(u.Signature[:], r.next(64))
+	signed := r.buf
+	copy(u.ChainHash[:], r.next(32))
+	u.ShortChannelID = r.u64()
+	u.Timestamp = r.u32()
+	u.MessageFlags = r.u8()
+	u.ChannelFlags = r.u8()
+	u.CltvExpiryDelta = r.u16()
+	u.HtlcMinimumMsat = r.u64()
+	u.FeeBaseMsat = r.u32()
+	u.FeeProportionalMillionths = r.u32()
+	if len(r.buf) >= 8 {
+		u.HtlcMaximumMsat = r.u64()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode channel_update: %w", r.err)
+	}
+	u.signedData = signed
+	return u, nil
+}
+
+// ParseNodeAnnouncement decodes a node_announcement body (without the type prefix).
+func ParseNodeAnnouncement(body []byte) (*NodeAnnouncement, error) {
+	r := &reader{buf: body}
+	n := &NodeAnnouncement{}
+	copy(n.Signature[:], r.next(64))
+	signed := r.buf
+	n.Features = r.next(int(r.u16()))
+	n.Timestamp = r.u32()
+	copy(n.NodeID[:], r.next(33))
+	copy(n.RGBColor[:], r.next(3))
+	copy(n.Alias[:], r.next(32))
+	addresses := r.next(int(r.u16()))
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode node_announcement: %w", r.err)
+	}
+	var err error
+	if n.Addresses, err = decodeAddresses(addresses); err != nil {
+		return nil, fmt.Errorf("failed to decode node_announcement addresses: %w", err)
+	}
+	n.signedData = signed
+	return n, nil
+}
+
+// decodeAddresses parses node_announcement address descriptors into host:port
+// strings. Unknown descriptor types end parsing, as required by BOLT 7.
+func decodeAddresses(data []byte) ([]string, error) {
+	r := &reader{buf: data}
+	onion := base32.StdEncoding.WithPadding(base32.NoPadding)
+	var addresses []string
+	for len(r.buf) > 0 && r.err == nil {
+		var host string
+		switch r.u8() {
+		case 1:
+			host = net.IP(r.next(4)).String()
+		case 2:
+			host = net.IP(r.next(16)).String()
+		case 3:
+			host = strings.ToLower(onion.EncodeToString(r.next(10))) + ".onion"
+		case 4:
+			host = strings.ToLower(onion.EncodeToString(r.next(35))) + ".onion"
+		case 5:
+			host = string(r.next(int(r.u8())))
+		default:
+			return addresses, nil
+		}
+		port := r.u16()
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	}
+	return addresses, r.err
+}
+
+// FeatureMap expands a raw feature bitfield into the map form used by LND
+// snapshots, keyed by bit number.
+func FeatureMap(features []byte) map[string]interface{} {
+	bits := map[string]interface{}{}
+	for i := range features {
+		b := features[len(features)-1-i]
+		for j := 0; j < 8; j++ {
+			if b&(1<<j) != 0 {
+				bit := i*8 + j
+				bits[strconv.Itoa(bit)] = map[string]interface{}{"is_required": bit%2 == 0}
+			}
+		}
+	}
+	return bits
+}
+
+// AliasString returns the announced alias with trailing padding removed.
+func (n *NodeAnnouncement) AliasString() string {
+	return strings.TrimRight(string(n.Alias[:]), "\x00")
+}
+
+// NodeIDString returns the hex-encoded node public key.
+func (n *NodeAnnouncement) NodeIDString() string {
+	return hex.EncodeToString(n.NodeID[:])
+}
@@ -0,0 +1,250 @@
+package gossip
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"ln-stream/lnd"
+)
+
+// Node converts a node_announcement into a snapshot node.
+func (n *NodeAnnouncement) Node() lnd.Node {
+	addresses := make([]interface{}, 0, len(n.Addresses))
+	for _, addr := range n.Addresses {
+		addresses = append(addresses, map[string]interface{}{"network": "tcp", "addr": addr})
+	}
+	return lnd.Node{
+		Pub_Key:    n.NodeIDString(),
+		LastUpdate: int64(n.Timestamp),
+		Alias:      n.AliasString(),
+		Color:      "#" + hex.EncodeToString(n.RGBColor[:]),
+		Features:   FeatureMap(n.Features),
+		Addresses:  addresses,
+	}
+}
+
+// Policy converts a channel_update into a snapshot routing policy.
+func (u *ChannelUpdate) Policy() lnd.RoutingPolicy {
+	return lnd.RoutingPolicy{
+		TimeLockDelta:    int(u.CltvExpiryDelta),
+		MinHtlc:          strconv.FormatUint(u.HtlcMinimumMsat, 10),
+		FeeBaseMsat:      strconv.FormatUint(uint64(u.FeeBaseMsat), 10),
+		FeeRateMilliMsat: strconv.FormatUint(uint64(u.FeeProportionalMillionths), 10),
+		Disabled:         u.Disabled(),
+		MaxHtlcMsat:      strconv.FormatUint(u.HtlcMaximumMsat, 10),
+		LastUpdate:       int(u.Timestamp),
+	}
+}
+
+// VerifyMode selects what a Builder does with messages whose signatures do
+// not verify.
+type VerifyMode string
+
+const (
+	// VerifyOff skips signature checks.
+	VerifyOff VerifyMode = ""
+	// VerifyDrop rejects messages with invalid signatures.
+	VerifyDrop VerifyMode = "drop"
+	// VerifyFlag keeps them, marking the resulting nodes, channels and
+	// policies with InvalidSignature.
+	VerifyFlag VerifyMode = "flag"
+)
+
+// ParseVerifyMode parses "off", "drop" or "flag"; empty means off.
+func ParseVerifyMode(s string) (VerifyMode, error) {
+	switch s {
+	case "", "off":
+		return VerifyOff, nil
+	case "drop":
+		return VerifyDrop, nil
+	case "flag":
+		return VerifyFlag, nil
+	}
+	return VerifyOff, fmt.Errorf("verification must be off, drop or flag, got %q", s)
+}
+
+// VerifyStats counts the messages whose signatures did not verify, and
+// Malformed those that could not be parsed and were skipped.
+type VerifyStats struct {
+	Mode                 VerifyMode `json:"mode"`
+	ChannelAnnouncements int        `json:"channel_announcements"`
+	ChannelUpdates       int        `json:"channel_updates"`
+	NodeAnnouncements    int        `json:"node_announcements"`
+	Malformed            int        `json:"malformed"`
+}
+
+// channel is the accumulated gossip state of a single channel. invalid
+// marks an announcement, and invalidUpdates the updates, kept in flag mode
+// despite failing verification.
+type channel struct {
+	announcement   *ChannelAnnouncement
+	capacity       uint64
+	updates        [2]*ChannelUpdate
+	invalid        bool
+	invalidUpdates [2]bool
+}
+
+// Builder reconstructs a channel graph from a stream of gossip messages,
+// keeping the latest node_announcement per node and the latest channel_update
+// per channel direction.
+type Builder struct {
+	// Verify selects whether and how the Add methods check signatures.
+	Verify VerifyMode
+
+	invalid VerifyStats
+
+	nodes        map[[33]byte]*NodeAnnouncement
+	invalidNodes map[[33]byte]bool
+	channels     map[uint64]*channel
+}
+
+// NewBuilder returns an empty graph builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		nodes:        map[[33]byte]*NodeAnnouncement{},
+		invalidNodes: map[[33]byte]bool{},
+		channels:     map[uint64]*channel{},
+	}
+}
+
+// Invalid returns how many messages have failed verification so far.
+func (b *Builder) Invalid() VerifyStats {
+	stats := b.invalid
+	stats.Mode = b.Verify
+	return stats
+}
+
+// SkipMalformed counts a message that could not be parsed and was skipped.
+func (b *Builder) SkipMalformed() {
+	b.invalid.Malformed++
+}
+
+// check verifies a message according to b.Verify. It returns an error if the
+// message must be rejected, and whether it is kept despite failing.
+func (b *Builder) check(verify func() error, count *int) (bool, error) {
+	if b.Verify == VerifyOff {
+		return false, nil
+	}
+	if err := verify(); err != nil {
+		*count++
+		if b.Verify == VerifyDrop {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Add applies any decoded gossip message returned by Parse.
+func (b *Builder) Add(msg interface{}) error {
+	switch m := msg.(type) {
+	case *ChannelAnnouncement:
+		return b.AddChannelAnnouncement(m)
+	case *ChannelUpdate:
+		return b.AddChannelUpdate(m)
+	case *NodeAnnouncement:
+		return b.AddNodeAnnouncement(m)
+	default:
+		return fmt.Errorf("unsupported gossip message %T", msg)
+	}
+}
+
+// AddChannelAnnouncement registers a channel. Re-announcing a known channel
+// keeps its existing updates and capacity.
+func (b *Builder) AddChannelAnnouncement(a *ChannelAnnouncement) error {
+	invalid, err := b.check(a.Verify, &b.invalid.ChannelAnnouncements)
+	if err != nil {
+		return err
+	}
+	if c, ok := b.channels[a.ShortChannelID]; ok {
+		c.announcement, c.invalid = a, invalid
+		return nil
+	}
+	b.channels[a.ShortChannelID] = &channel{announcement: a, invalid: invalid}
+	return nil
+}
+
+// SetCapacity records a channel's capacity in satoshis, which is not part of
+// the announcement itself and must come from the funding output.
+func (b *Builder) SetCapacity(scid uint64, capacity uint64) {
+	if c, ok := b.channels[scid]; ok {
+		c.capacity = capacity
+	}
+}
+
+// AddChannelUpdate applies a channel_update if it is newer than the stored
+// update for its direction. Updates for unannounced channels are rejected.
+func (b *Builder) AddChannelUpdate(u *ChannelUpdate) error {
+	c, ok := b.channels[u.ShortChannelID]
+	if !ok {
+		return fmt.Errorf("channel_update for unknown channel %d", u.ShortChannelID)
+	}
+	invalid, err := b.check(func() error { return u.Verify(c.announcement) }, &b.invalid.ChannelUpdates)
+	if err != nil {
+		return err
+	}
+	if prev := c.updates[u.Direction()]; prev == nil || u.Timestamp >= prev.Timestamp {
+		c.updates[u.Direction()] = u
+		c.invalidUpdates[u.Direction()] = invalid
+	}
+	return nil
+}
+
+// AddNodeAnnouncement applies a node_announcement if it is newer than the
+// stored announcement for the node.
+func (b *Builder) AddNodeAnnouncement(n *NodeAnnouncement) error {
+	invalid, err := b.check(n.Verify, &b.invalid.NodeAnnouncements)
+	if err != nil {
+		return err
+	}
+	if prev, ok := b.nodes[n.NodeID]; !ok || n.Timestamp >= prev.Timestamp {
+		b.nodes[n.NodeID] = n
+		b.invalidNodes[n.NodeID] = invalid
+	}
+	return nil
+}
+
+// DeleteChannel forgets a channel, e.g. after it was closed on chain.
+func (b *Builder) DeleteChannel(scid uint64) {
+	delete(b.channels, scid)
+}
+
+// Graph returns the reconstructed graph in snapshot form.
+func (b *Builder) Graph() *lnd.Graph {
+	graph := &lnd.Graph{}
+
+	for key, n := range b.nodes {
+		node := n.Node()
+		node.InvalidSignature = b.invalidNodes[key]
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for scid, c := range b.channels {
+		edge := lnd.ChannelEdge{
+			ChannelId:        strconv.FormatUint(scid, 10),
+			Capacity:         strconv.FormatUint(c.capacity, 10),
+			Node1_Pub:        hex.EncodeToString(c.announcement.NodeID1[:]),
+			Node2_Pub:        hex.EncodeToString(c.announcement.NodeID2[:]),
+			InvalidSignature: c.invalid,
+		}
+		for dir, u := range c.updates {
+			if u == nil {
+				continue
+			}
+			policy := u.Policy()
+			policy.InvalidSignature = c.invalidUpdates[dir]
+			if dir == 0 {
+				edge.Node1Policy = policy
+			} else {
+				edge.Node2Policy = policy
+			}
+			if int64(u.Timestamp) > edge.LastUpdate {
+				edge.LastUpdate = int64(u.Timestamp)
+			}
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	return graph
+}
@@ -0,0 +1,80 @@
+package lnd
+
+import (
+	"net"
+	"strings"
+)
+
+// Connectivity classes of a node, stored as its connectivity property.
+const (
+	ConnectivityClearnet = "clearnet"
+	ConnectivityTor      = "tor"
+	ConnectivityHybrid   = "hybrid"
+	ConnectivityNone     = "none"
+)
+
+// ConnectivityClasses lists the values of the connectivity property.
+var ConnectivityClasses = []string{ConnectivityClearnet, ConnectivityTor, ConnectivityHybrid, ConnectivityNone}
+
+// ClassifyAddresses returns the node properties describing how a node with
+// the given advertised host:port addresses can be reached:
+//   - has_ipv4, has_ipv6, has_tor: an address of that kind is advertised
+//   - has_clearnet: an IP or DNS address is advertised
+//   - ipv6_only: all clearnet addresses are IPv6
+//   - connectivity: clearnet, tor, hybrid (both) or none
+func ClassifyAddresses(addresses []string) map[string]interface{} {
+	var ipv4, ipv6, dns, tor bool
+	for _, address := range addresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		switch ip := net.ParseIP(host); {
+		case strings.HasSuffix(strings.ToLower(host), ".onion"):
+			tor = true
+		case ip == nil:
+			if host != "" {
+				dns = true
+			}
+		case ip.To4() != nil:
+			ipv4 = true
+		default:
+			ipv6 = true
+		}
+	}
+	clearnet := ipv4 || ipv6 || dns
+	connectivity := ConnectivityNone
+	switch {
+	case clearnet && tor:
+		connectivity = ConnectivityHybrid
+	case clearnet:
+		connectivity = ConnectivityClearnet
+	case tor:
+		connectivity = ConnectivityTor
+	}
+	return map[string]interface{}{
+		"has_clearnet": clearnet,
+		"has_ipv4":     ipv4,
+		"has_ipv6":     ipv6,
+		"has_tor":      tor,
+		"ipv6_only":    ipv6 && !ipv4 && !dns,
+		"connectivity": connectivity,
+	}
+}
+
+// addressList returns the host:port addresses of a snapshot node, which
+// describegraph.json lists as {"network": ..., "addr": ...} objects.
+func (n Node) addressList() []string {
+	addresses := make([]string, 0, len(n.Addresses))
+	for _, a := range n.Addresses {
+		switch a := a.(type) {
+		case map[string]interface{}:
+			if addr, ok := a["addr"].(string); ok && addr != "" {
+				addresses = append(addresses, addr)
+			}
+		case string:
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,105 @@
+package lnd
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// CacheFile is where PullGraph stores the last graph it pulled, gob-encoded
+// and gzipped, so that a namespace can be rebuilt without asking the source
+// again. Empty disables the cache.
+var CacheFile string
+
+// ErrNoCache is returned by ReadGraphCache when no graph has been cached.
+var ErrNoCache = errors.New("no cached graph")
+
+// cachedGraph is the content of the cache file.
+type cachedGraph struct {
+	PulledAt time.Time
+	Network  string
+	Graph    *lndclient.Graph
+}
+
+// writeGraphCache replaces the cache file with graph. The file is written
+// under a temporary name and renamed, so a failed write never leaves a
+// truncated cache behind.
+func writeGraphCache(path, network string, graph *lndclient.Graph) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create graph cache: %w", err)
+	}
+	gz := gzip.NewWriter(file)
+	err = gob.NewEncoder(gz).Encode(cachedGraph{PulledAt: time.Now().UTC(), Network: network, Graph: graph})
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write graph cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write graph cache: %w", err)
+	}
+	return nil
+}
+
+// ReadGraphCache returns the graph cached by the last successful PullGraph,
+// the network it was pulled on and when. Returns ErrNoCache if the cache is
+// disabled or empty.
+func ReadGraphCache() (*lndclient.Graph, string, time.Time, error) {
+	if CacheFile == "" {
+		return nil, "", time.Time{}, ErrNoCache
+	}
+	file, err := os.Open(CacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", time.Time{}, ErrNoCache
+	}
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("failed to open graph cache: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("failed to read graph cache: %w", err)
+	}
+	var cached cachedGraph
+	if err := gob.NewDecoder(gz).Decode(&cached); err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("failed to read graph cache: %w", err)
+	}
+	if cached.Graph == nil {
+		return nil, "", time.Time{}, ErrNoCache
+	}
+	return cached.Graph, cached.Network, cached.PulledAt, nil
+}
+
+// cacheGraph stores a pulled graph in CacheFile, if set, logging failures.
+func cacheGraph(graph *lndclient.Graph) {
+	if CacheFile == "" {
+		return
+	}
+	network := os.Getenv("LND_NETWORK")
+	if network == "" {
+		network = "mainnet"
+	}
+	start := time.Now()
+	if err := writeGraphCache(CacheFile, network, graph); err != nil {
+		log.Printf("Failed to cache pulled graph: %v", err)
+		return
+	}
+	log.Printf("Cached pulled graph to %s in %s", CacheFile, time.Since(start).Round(time.Millisecond))
+}
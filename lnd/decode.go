@@ -0,0 +1,189 @@
+package lnd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Snapshots produced by `lncli describegraph` and by the REST `/v1/graph`
+// endpoint differ slightly: the REST gateway may emit camelCase keys
+// (pubKey, lastUpdate) and encodes 64-bit integers as strings, while lncli
+// uses snake_case and emits some integers as plain numbers. The decoders in
+// this file accept either shape by normalizing keys and tolerating both
+// string and numeric encodings. A numeric field that does not parse is
+// zeroed and noted on the record instead of failing the whole snapshot;
+// ValidateSnapshot then drops the record and reports why.
+
+// normalizeKey folds snake_case and camelCase keys to a common form.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
+
+// fields is a JSON object keyed by normalized field names.
+type fields map[string]json.RawMessage
+
+// decodeFields decodes a JSON object and normalizes its keys.
+func decodeFields(data []byte) (fields, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	out := make(fields, len(raw))
+	for key, value := range raw {
+		out[normalizeKey(key)] = value
+	}
+	return out, nil
+}
+
+// present reports whether key exists and is not JSON null.
+func (f fields) present(key string) bool {
+	value, ok := f[key]
+	return ok && string(value) != "null"
+}
+
+// decode unmarshals the named field into dst, leaving dst untouched if the
+// field is missing or null.
+func (f fields) decode(key string, dst interface{}) error {
+	if !f.present(key) {
+		return nil
+	}
+	if err := json.Unmarshal(f[key], dst); err != nil {
+		return fmt.Errorf("field %s: %w", key, err)
+	}
+	return nil
+}
+
+// string returns the named field as a string, accepting JSON strings and numbers.
+func (f fields) string(key string) (string, error) {
+	if !f.present(key) {
+		return "", nil
+	}
+	value := f[key]
+	if value[0] == '"' {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return "", fmt.Errorf("field %s: %w", key, err)
+		}
+		return s, nil
+	}
+	return string(value), nil
+}
+
+// int64 returns the named field as an integer, accepting JSON numbers and
+// numeric strings.
+func (f fields) int64(key string) (int64, error) {
+	s, err := f.string(key)
+	if err != nil || s == "" {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("field %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// number returns the field of the given snake_case name as an integer, like
+// int64. A value that does not parse yields 0 and appends the name to
+// malformed.
+func (f fields) number(name string, malformed *[]string) int64 {
+	n, err := f.int64(normalizeKey(name))
+	if err != nil {
+		*malformed = append(*malformed, name)
+		return 0
+	}
+	return n
+}
+
+// UnmarshalJSON decodes a node from either the lncli or REST snapshot format.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	f, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	if n.Pub_Key, err = f.string("pubkey"); err != nil {
+		return err
+	}
+	n.LastUpdate = f.number("last_update", &n.malformed)
+	if n.Alias, err = f.string("alias"); err != nil {
+		return err
+	}
+	if n.Color, err = f.string("color"); err != nil {
+		return err
+	}
+	if err := f.decode("features", &n.Features); err != nil {
+		return err
+	}
+	if err := f.decode("addresses", &n.Addresses); err != nil {
+		return err
+	}
+	if err := f.decode("invalidsignature", &n.InvalidSignature); err != nil {
+		return err
+	}
+	return f.decode("customrecords", &n.CustomRecords)
+}
+
+// UnmarshalJSON decodes a channel from either the lncli or REST snapshot format.
+func (e *ChannelEdge) UnmarshalJSON(data []byte) error {
+	f, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	if e.ChannelId, err = f.string("channelid"); err != nil {
+		return err
+	}
+	if e.ChanPoint, err = f.string("chanpoint"); err != nil {
+		return err
+	}
+	e.LastUpdate = f.number("last_update", &e.malformed)
+	if e.Node1_Pub, err = f.string("node1pub"); err != nil {
+		return err
+	}
+	if e.Node2_Pub, err = f.string("node2pub"); err != nil {
+		return err
+	}
+	if e.Capacity, err = f.string("capacity"); err != nil {
+		return err
+	}
+	if err := f.decode("node1policy", &e.Node1Policy); err != nil {
+		return err
+	}
+	if err := f.decode("node2policy", &e.Node2Policy); err != nil {
+		return err
+	}
+	if err := f.decode("invalidsignature", &e.InvalidSignature); err != nil {
+		return err
+	}
+	return f.decode("customrecords", &e.CustomRecords)
+}
+
+// UnmarshalJSON decodes a routing policy from either the lncli or REST snapshot format.
+func (p *RoutingPolicy) UnmarshalJSON(data []byte) error {
+	f, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	p.TimeLockDelta = int(f.number("time_lock_delta", &p.malformed))
+	if p.MinHtlc, err = f.string("minhtlc"); err != nil {
+		return err
+	}
+	if p.FeeBaseMsat, err = f.string("feebasemsat"); err != nil {
+		return err
+	}
+	if p.FeeRateMilliMsat, err = f.string("feeratemillimsat"); err != nil {
+		return err
+	}
+	if err := f.decode("disabled", &p.Disabled); err != nil {
+		return err
+	}
+	if p.MaxHtlcMsat, err = f.string("maxhtlcmsat"); err != nil {
+		return err
+	}
+	p.LastUpdate = int(f.number("last_update", &p.malformed))
+	if err := f.decode("invalidsignature", &p.InvalidSignature); err != nil {
+		return err
+	}
+	return f.decode("customrecords", &p.CustomRecords)
+}
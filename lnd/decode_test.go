@@ -0,0 +1,109 @@
+package lnd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const (
+	testPub1 = "020000000000000000000000000000000000000000000000000000000000000001"
+	testPub2 = "030000000000000000000000000000000000000000000000000000000000000002"
+)
+
+func TestDecodeSnapshotVariants(t *testing.T) {
+	lncli := `{
+		"nodes": [{"pub_key": "` + testPub1 + `", "last_update": 1700000000, "alias": "a", "color": "#ffffff"}],
+		"edges": [{
+			"channel_id": "869059488457703425", "chan_point": "txid:1", "last_update": 1700000001,
+			"node1_pub": "` + testPub1 + `", "node2_pub": "` + testPub2 + `", "capacity": "500000",
+			"node1_policy": {"time_lock_delta": 40, "min_htlc": "1000", "fee_base_msat": "1000",
+				"fee_rate_milli_msat": "100", "disabled": false, "max_htlc_msat": "495000000", "last_update": 1700000002}
+		}]
+	}`
+	rest := `{
+		"nodes": [{"pubKey": "` + testPub1 + `", "lastUpdate": "1700000000", "alias": "a", "color": "#ffffff"}],
+		"edges": [{
+			"channelId": "869059488457703425", "chanPoint": "txid:1", "lastUpdate": "1700000001",
+			"node1Pub": "` + testPub1 + `", "node2Pub": "` + testPub2 + `", "capacity": 500000,
+			"node1Policy": {"timeLockDelta": "40", "minHtlc": 1000, "feeBaseMsat": 1000,
+				"feeRateMilliMsat": 100, "disabled": false, "maxHtlcMsat": 495000000, "lastUpdate": "1700000002"}
+		}]
+	}`
+
+	var fromLncli, fromRest Graph
+	if err := json.Unmarshal([]byte(lncli), &fromLncli); err != nil {
+		t.Fatalf("decoding lncli snapshot: %v", err)
+	}
+	if err := json.Unmarshal([]byte(rest), &fromRest); err != nil {
+		t.Fatalf("decoding REST snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(fromLncli, fromRest) {
+		t.Errorf("variants decode differently:\nlncli: %+v\nREST:  %+v", fromLncli, fromRest)
+	}
+
+	edge := fromLncli.Edges[0]
+	if edge.LastUpdate != 1700000001 || edge.Capacity != "500000" || edge.Node1Policy.TimeLockDelta != 40 ||
+		edge.Node1Policy.LastUpdate != 1700000002 || edge.Node1Policy.FeeRateMilliMsat != "100" {
+		t.Errorf("unexpected edge %+v", edge)
+	}
+	if fromLncli.Nodes[0].LastUpdate != 1700000000 {
+		t.Errorf("node last_update = %d, want 1700000000", fromLncli.Nodes[0].LastUpdate)
+	}
+}
+
+func TestDecodeToleratesUnparsableNumbers(t *testing.T) {
+	goodPolicy := `{"time_lock_delta": 40, "last_update": 1700000002}`
+	tests := []struct {
+		name   string
+		node   string
+		policy string
+		edgeLU string
+		reason string
+	}{
+		{"node last_update", `"yesterday"`, goodPolicy, `1700000001`, "unparsable node last_update"},
+		{"channel last_update", `1700000000`, goodPolicy, `"1.5"`, "unparsable channel last_update"},
+		{"policy time_lock_delta", `1700000000`, `{"time_lock_delta": "forty", "last_update": 1700000002}`, `1700000001`,
+			"unparsable policy time_lock_delta"},
+		{"policy last_update", `1700000000`, `{"time_lock_delta": 40, "last_update": 1.5}`, `1700000001`,
+			"unparsable policy last_update"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := `{
+				"nodes": [
+					{"pub_key": "` + testPub1 + `", "last_update": ` + test.node + `},
+					{"pub_key": "` + testPub2 + `", "last_update": 1700000000}
+				],
+				"edges": [
+					{"channel_id": "1", "node1_pub": "` + testPub1 + `", "node2_pub": "` + testPub2 + `",
+						"capacity": "100", "last_update": ` + test.edgeLU + `, "node1_policy": ` + test.policy + `},
+					{"channel_id": "2", "node1_pub": "` + testPub1 + `", "node2_pub": "` + testPub2 + `",
+						"capacity": "100", "last_update": 1700000001, "node1_policy": ` + goodPolicy + `}
+				]
+			}`
+			var graph Graph
+			if err := json.Unmarshal([]byte(data), &graph); err != nil {
+				t.Fatalf("decoding failed instead of tolerating the field: %v", err)
+			}
+			valid, report := ValidateSnapshot(&graph)
+			if report.Problems[test.reason] != 1 {
+				t.Errorf("problems = %v, want one %q", report.Problems, test.reason)
+			}
+			if report.InvalidNodes+report.InvalidEdges != 1 {
+				t.Errorf("%d invalid nodes and %d invalid edges, want one invalid record",
+					report.InvalidNodes, report.InvalidEdges)
+			}
+			if len(valid.Nodes)+len(valid.Edges) != 3 {
+				t.Errorf("kept %d nodes and %d edges, want the other 3 records", len(valid.Nodes), len(valid.Edges))
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsMalformedJSON(t *testing.T) {
+	var graph Graph
+	if err := json.Unmarshal([]byte(`{"nodes": [{"pub_key": }]}`), &graph); err == nil {
+		t.Error("malformed JSON decoded without error")
+	}
+}
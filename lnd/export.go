@@ -0,0 +1,249 @@
+package lnd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// wumboFeature is the feature entry exported for nodes flagged is_wumbo.
+var wumboFeature = map[string]interface{}{"name": "large-channels", "is_required": false, "is_known": true}
+
+// ReadGraphFromMemgraph reads a namespace's graph back into the
+// describegraph snapshot format, so that it can be archived and later
+// re-imported. Node1 of each channel is the lexicographically smaller
+// pubkey, as in LND's output.
+func ReadGraphFromMemgraph(driver neo4j.Driver, namespace string) (*Graph, error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+	params := map[string]interface{}{"namespace": namespace}
+
+	graph := &Graph{Nodes: []Node{}, Edges: []ChannelEdge{}}
+	result, err := session.Run("MATCH (m:graph_meta {namespace: $namespace}) RETURN m.network AS network", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network: %w", err)
+	}
+	if result.Next() {
+		network, _ := result.Record().Get("network")
+		graph.Network, _ = network.(string)
+	}
+
+	result, err = session.Run(`
+		MATCH (n:node {namespace: $namespace})
+		RETURN n.pubkey AS pubkey, n.alias AS alias, n.color AS color, n.addresses AS addresses,
+			n.last_update AS last_update, n.is_wumbo AS is_wumbo, n.invalid_signature AS invalid_signature
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	for result.Next() {
+		record := result.Record()
+		values := make(map[string]interface{}, len(record.Keys))
+		for i, key := range record.Keys {
+			values[key] = record.Values[i]
+		}
+		node := Node{Features: map[string]interface{}{}, Addresses: []interface{}{}}
+		node.Pub_Key, _ = values["pubkey"].(string)
+		node.Alias, _ = values["alias"].(string)
+		node.Color, _ = values["color"].(string)
+		node.LastUpdate = exportInt(values["last_update"])
+		node.InvalidSignature, _ = values["invalid_signature"].(bool)
+		if wumbo, _ := values["is_wumbo"].(bool); wumbo {
+			node.Features["19"] = wumboFeature
+		}
+		if addresses, ok := values["addresses"].([]interface{}); ok {
+			for _, address := range addresses {
+				node.Addresses = append(node.Addresses, map[string]interface{}{"network": "tcp", "addr": fmt.Sprint(address)})
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+
+	result, err = session.Run(`
+		MATCH (a:node {namespace: $namespace})-[r:edge]->(b:node)
+		WHERE coalesce(r.pending, '') <> 'opening'
+		RETURN a.pubkey AS from, b.pubkey AS to, r.channel_id AS channel_id, r.scid AS scid,
+			r.capacity AS capacity, r.fee_base_msat AS fee_base_msat, r.fee_rate_milli_msat AS fee_rate_milli_msat,
+			r.time_lock_delta AS time_lock_delta, r.disabled AS disabled, r.min_htlc_msat AS min_htlc_msat,
+			r.max_htlc_msat AS max_htlc_msat, r.last_update AS last_update, r.chan_point AS chan_point,
+			r.invalid_signature AS invalid_signature
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channels: %w", err)
+	}
+	channels := map[string]*ChannelEdge{}
+	var order []string
+	for result.Next() {
+		record := result.Record()
+		values := make(map[string]interface{}, len(record.Keys))
+		for i, key := range record.Keys {
+			values[key] = record.Values[i]
+		}
+		from, _ := values["from"].(string)
+		to, _ := values["to"].(string)
+		chanID, _ := values["channel_id"].(string)
+		scid := uint64(exportInt(values["scid"]))
+		if scid == 0 {
+			scid = parseChannelIDString(chanID)
+		}
+
+		edge, ok := channels[chanID]
+		if !ok {
+			edge = &ChannelEdge{ChannelId: strconv.FormatUint(scid, 10), Capacity: exportString(values["capacity"])}
+			edge.ChanPoint, _ = values["chan_point"].(string)
+			edge.Node1_Pub, edge.Node2_Pub = from, to
+			if to < from {
+				edge.Node1_Pub, edge.Node2_Pub = to, from
+			}
+			channels[chanID] = edge
+			order = append(order, chanID)
+		}
+		policy := RoutingPolicy{
+			TimeLockDelta:    int(exportInt(values["time_lock_delta"])),
+			MinHtlc:          exportString(values["min_htlc_msat"]),
+			FeeBaseMsat:      exportString(values["fee_base_msat"]),
+			FeeRateMilliMsat: exportString(values["fee_rate_milli_msat"]),
+			MaxHtlcMsat:      exportString(values["max_htlc_msat"]),
+			LastUpdate:       int(exportInt(values["last_update"])),
+		}
+		policy.Disabled, _ = values["disabled"].(bool)
+		policy.InvalidSignature, _ = values["invalid_signature"].(bool)
+		// The snapshot reader skips policies without max_htlc_msat.
+		if policy.MaxHtlcMsat == "" {
+			policy.MaxHtlcMsat = strconv.FormatInt(exportInt(values["capacity"])*1000, 10)
+		}
+		if from == edge.Node1_Pub {
+			edge.Node1Policy = policy
+		} else {
+			edge.Node2Policy = policy
+		}
+		if int64(policy.LastUpdate) > edge.LastUpdate {
+			edge.LastUpdate = int64(policy.LastUpdate)
+		}
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read channels: %w", err)
+	}
+	for _, chanID := range order {
+		graph.Edges = append(graph.Edges, *channels[chanID])
+	}
+	return graph, nil
+}
+
+// exportInt converts a stored number, which snapshot imports may have kept
+// as a string, to int64. Missing values are 0.
+func exportInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	default:
+		return 0
+	}
+}
+
+// exportString formats a stored number as the decimal string used by the
+// snapshot format. Missing values are "".
+func exportString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(exportInt(value), 10)
+}
+
+// parseChannelIDString converts a BLOCKxTXxOUTPUT channel ID back to its
+// numeric form, returning 0 if it is malformed.
+func parseChannelIDString(chanID string) uint64 {
+	parts := strings.Split(chanID, "x")
+	if len(parts) != 3 {
+		return 0
+	}
+	var values [3]uint64
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return 0
+		}
+		values[i] = v
+	}
+	return values[0]<<40 | values[1]<<16 | values[2]
+}
+
+// dumpTimeLayout is the format of the timestamp in dump names.
+const dumpTimeLayout = "20060102T150405Z"
+
+// DumpPrefix returns the prefix shared by the names of all dumps of a
+// namespace.
+func DumpPrefix(namespace string) string {
+	// Namespaces are free-form, so keep only characters that are safe in a
+	// file name.
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, namespace) + "-"
+}
+
+// DumpName returns the name of a dump of namespace taken at t:
+// <namespace>-<UTC timestamp>.json.
+func DumpName(namespace string, t time.Time) string {
+	return DumpPrefix(namespace) + t.UTC().Format(dumpTimeLayout) + ".json"
+}
+
+// ParseDumpTime returns the time at which a dump of namespace was taken,
+// according to its name. ok is false if name is not such a dump.
+func ParseDumpTime(namespace, name string) (t time.Time, ok bool) {
+	stamp, found := strings.CutPrefix(name, DumpPrefix(namespace))
+	if !found || !strings.HasSuffix(stamp, ".json") {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(dumpTimeLayout, strings.TrimSuffix(stamp, ".json"))
+	return t, err == nil
+}
+
+// DumpSnapshot writes a namespace's graph as a describegraph JSON file named
+// by DumpName in dir and returns its path. The file is written under a
+// temporary name first so that a partial dump never shows up in the snapshot
+// library.
+func DumpSnapshot(driver neo4j.Driver, namespace, dir string) (string, error) {
+	graph, err := ReadGraphFromMemgraph(driver, namespace)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	path := filepath.Join(dir, DumpName(namespace, time.Now()))
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to create dump: %w", err)
+	}
+	if err := json.NewEncoder(file).Encode(graph); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write dump: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write dump: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to finish dump: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,114 @@
+package lnd
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+)
+
+// htlcResubscribeDelay is how long StreamForwards waits before subscribing
+// again after the HTLC event stream failed.
+const htlcResubscribeDelay = 30 * time.Second
+
+// Forward is the outcome of one HTLC that the local node forwarded, or
+// failed to forward, from InChannel to OutChannel. Amounts are zero for
+// forwards that failed before ln-stream saw them being offered.
+type Forward struct {
+	InChannel  string
+	OutChannel string
+	InMsat     int64
+	OutMsat    int64
+	Settled    bool
+}
+
+// htlcKey identifies a forwarded HTLC across its events.
+type htlcKey struct {
+	inChannel, inHtlc, outChannel, outHtlc uint64
+}
+
+// StreamForwards subscribes to the HTLC events of a connected LND node and
+// sends the outcome of every forward, pairing each offered forward with the
+// settle or failure that ends it. Failures on the outgoing link are reported
+// at once. Payments sent or received by the node itself are ignored. The
+// subscription is renewed when it fails; the channel is closed once ctx is
+// done.
+func StreamForwards(ctx context.Context, services *lndclient.GrpcLndServices) <-chan Forward {
+	forwards := make(chan Forward)
+	go func() {
+		defer close(forwards)
+		// pending holds the amounts of offered forwards until they resolve.
+		pending := map[htlcKey]Forward{}
+		for ctx.Err() == nil {
+			events, errs, err := services.Router.SubscribeHtlcEvents(ctx)
+			if err == nil {
+				err = relayForwards(ctx, events, errs, pending, forwards)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("HTLC event stream failed, resubscribing in %s: %v", htlcResubscribeDelay, err)
+			select {
+			case <-time.After(htlcResubscribeDelay):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return forwards
+}
+
+// relayForwards turns forward events into Forwards until the stream fails.
+func relayForwards(ctx context.Context, events <-chan *routerrpc.HtlcEvent, errs <-chan error,
+	pending map[htlcKey]Forward, forwards chan<- Forward) error {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return <-errs
+			}
+			if event.EventType != routerrpc.HtlcEvent_FORWARD {
+				continue
+			}
+			key := htlcKey{event.IncomingChannelId, event.IncomingHtlcId, event.OutgoingChannelId, event.OutgoingHtlcId}
+			forward := Forward{
+				InChannel:  convertChannelIDToString(event.IncomingChannelId),
+				OutChannel: convertChannelIDToString(event.OutgoingChannelId),
+			}
+			switch {
+			case event.GetForwardEvent() != nil:
+				if info := event.GetForwardEvent().Info; info != nil {
+					forward.InMsat, forward.OutMsat = int64(info.IncomingAmtMsat), int64(info.OutgoingAmtMsat)
+				}
+				pending[key] = forward
+				continue
+			case event.GetSettleEvent() != nil:
+				if offered, ok := pending[key]; ok {
+					forward = offered
+				}
+				forward.Settled = true
+			case event.GetForwardFailEvent() != nil:
+				if offered, ok := pending[key]; ok {
+					forward = offered
+				}
+			case event.GetLinkFailEvent() != nil:
+				if info := event.GetLinkFailEvent().Info; info != nil {
+					forward.InMsat, forward.OutMsat = int64(info.IncomingAmtMsat), int64(info.OutgoingAmtMsat)
+				}
+			default:
+				continue
+			}
+			delete(pending, key)
+			select {
+			case forwards <- forward:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -5,151 +5,280 @@ package lnd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
-	"strings"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// BatchSize is the number of rows written per UNWIND query during full-graph
+// imports. Larger batches mean fewer round trips, which matters most against
+// remote databases; smaller ones keep individual transactions short.
+var BatchSize = 100
+
+// ChunkSize is the number of nodes or channels converted into rows and
+// written at a time during full-graph imports. Rows are much larger than
+// the graph records they are built from, so converting the whole graph up
+// front would roughly triple peak memory on mainnet.
+var ChunkSize = 10000
+
+// chunks calls write for consecutive ranges [start, end) of at most
+// ChunkSize out of n items, stopping at the first error.
+func chunks(n int, write func(start, end int) error) error {
+	for start := 0; start < n; start += ChunkSize {
+		if err := write(start, min(n, start+ChunkSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // convertChannelIDToString decodes a compact channel ID (uint64) into the
-// human-readable block:index:output format used by the Lightning Network.
+// human-readable block x index x output format, using 'x' as separator for
+// Memgraph compatibility. Every writer must use this form so that the same
+// channel always maps to the same edge.
 func convertChannelIDToString(channelID uint64) string {
 	blockHeight := channelID >> 40
 	blockIndex := (channelID >> 16) & ((1 << 24) - 1)
 	outputIndex := channelID & ((1 << 16) - 1)
-	return fmt.Sprintf("%d:%d:%d", blockHeight, blockIndex, outputIndex)
+	return fmt.Sprintf("%dx%dx%d", blockHeight, blockIndex, outputIndex)
+}
+
+// Zero-conf and option_scid_alias channels are known by alias SCIDs that
+// LND allocates from this block height range rather than by their funding
+// outpoint.
+const (
+	AliasStartHeight = 16_000_000
+	AliasEndHeight   = 16_250_000
+)
+
+// BlockHeight returns the funding block height encoded in a short channel
+// ID, or nil for alias SCIDs, whose "height" is meaningless. The result is
+// meant to be stored as an edge's block_height.
+func BlockHeight(scid uint64) interface{} {
+	height := scid >> 40
+	if height >= AliasStartHeight && height < AliasEndHeight {
+		return nil
+	}
+	return int64(height)
+}
+
+// SCID returns a short channel ID as stored in an edge's scid: as int64,
+// or nil if it does not fit, as for alias SCIDs, whose "height" sets the top
+// bit.
+func SCID(scid uint64) interface{} {
+	if scid > math.MaxInt64 {
+		return nil
+	}
+	return int64(scid)
 }
 
 // ConnectToLND establishes a gRPC connection to the Lightning Network Daemon
-// using credentials from environment variables.
+// using credentials from environment variables. The macaroon is read from
+// LND_MACAROON_PATH, unless given hex-encoded in LND_MACAROON_HEX. With
+// LND_TLS_SKIP_VERIFY=true the node's certificate is not verified, which
+// suits local regtest nodes whose certificates do not name the host they are
+// reached by.
 func ConnectToLND() (*lndclient.GrpcLndServices, error) {
 	config := lndclient.LndServicesConfig{
 		LndAddress:         os.Getenv("LND_ADDRESS"),
 		Network:            lndclient.Network(os.Getenv("LND_NETWORK")),
 		CustomMacaroonPath: os.Getenv("LND_MACAROON_PATH"),
+		CustomMacaroonHex:  os.Getenv("LND_MACAROON_HEX"),
 		TLSPath:            os.Getenv("LND_TLS_CERT_PATH"),
 	}
+	if config.CustomMacaroonHex != "" {
+		config.CustomMacaroonPath = ""
+	}
+	if skip, _ := strconv.ParseBool(os.Getenv("LND_TLS_SKIP_VERIFY")); skip {
+		config.Insecure = true
+		config.TLSPath = ""
+	}
 	return lndclient.NewLndServices(&config)
 }
 
 // Node represents a Lightning Network node as serialized in the describegraph.json snapshot.
+// LastUpdate is the unix timestamp of the node's latest announcement.
+// InvalidSignature, like that of ChannelEdge and RoutingPolicy, marks
+// entries whose gossip signature failed verification but were kept; it is
+// not part of LND's output.
 type Node struct {
-	Pub_Key    string
-	LastUpdate time.Time
-	Alias      string
-	Color      string
-	Features   map[string]interface{}
-	Addresses  []interface{}
+	Pub_Key          string                 `json:"pub_key"`
+	LastUpdate       int64                  `json:"last_update"`
+	Alias            string                 `json:"alias"`
+	Color            string                 `json:"color"`
+	Features         map[string]interface{} `json:"features"`
+	Addresses        []interface{}          `json:"addresses"`
+	CustomRecords    map[string]interface{} `json:"custom_records,omitempty"`
+	InvalidSignature bool                   `json:"invalid_signature,omitempty"`
+	// malformed names the numeric fields that did not parse; see decode.go.
+	malformed []string
 }
 
 // ChannelEdge represents a payment channel between two nodes in the snapshot.
 type ChannelEdge struct {
-	ChannelId   uint64        `json:"channel_id,string"`
-	Capacity    string        `json:"capacity"`
-	Node1_Pub   string        `json:"node1_pub"`
-	Node2_Pub   string        `json:"node2_pub"`
-	Node1Policy RoutingPolicy `json:"node1_policy,omitempty"`
-	Node2Policy RoutingPolicy `json:"node2_policy,omitempty"`
+	ChannelId     string                 `json:"channel_id"`
+	ChanPoint     string                 `json:"chan_point"`
+	LastUpdate    int64                  `json:"last_update"`
+	Capacity      string                 `json:"capacity"`
+	Node1_Pub     string                 `json:"node1_pub"`
+	Node2_Pub     string                 `json:"node2_pub"`
+	Node1Policy   RoutingPolicy          `json:"node1_policy,omitempty"`
+	Node2Policy   RoutingPolicy          `json:"node2_policy,omitempty"`
+	CustomRecords map[string]interface{} `json:"custom_records,omitempty"`
+	// InvalidSignature marks a channel whose announcement failed verification.
+	InvalidSignature bool `json:"invalid_signature,omitempty"`
+	malformed        []string
 }
 
 // RoutingPolicy holds the fee and routing parameters for one direction of a channel.
 type RoutingPolicy struct {
-	TimeLockDelta    int    `json:"time_lock_delta"`
-	MinHtlc          string `json:"min_htlc"`
-	FeeBaseMsat      string `json:"fee_base_msat"`
-	FeeRateMilliMsat string `json:"fee_rate_milli_msat"`
-	Disabled         bool   `json:"disabled"`
-	MaxHtlcMsat      string `json:"max_htlc_msat"`
-	LastUpdate       int    `json:"last_update"`
-	CustomRecords    struct {
-	} `json:"custom_records"`
+	TimeLockDelta    int                    `json:"time_lock_delta"`
+	MinHtlc          string                 `json:"min_htlc"`
+	FeeBaseMsat      string                 `json:"fee_base_msat"`
+	FeeRateMilliMsat string                 `json:"fee_rate_milli_msat"`
+	Disabled         bool                   `json:"disabled"`
+	MaxHtlcMsat      string                 `json:"max_htlc_msat"`
+	LastUpdate       int                    `json:"last_update"`
+	CustomRecords    map[string]interface{} `json:"custom_records,omitempty"`
+	InvalidSignature bool                   `json:"invalid_signature,omitempty"`
+	malformed        []string
 }
 
 // Graph is the top-level structure of the describegraph.json snapshot file.
+// Nodes, channels and policies decode from both the lncli and REST variants
+// of the format; see decode.go. Network is not part of LND's output but is
+// honored when present, e.g. in snapshots exported by ln-stream.
 type Graph struct {
-	Nodes []Node
-	Edges []ChannelEdge
+	Network string        `json:"network,omitempty"`
+	Nodes   []Node        `json:"nodes"`
+	Edges   []ChannelEdge `json:"edges"`
 }
 
-// writeNodesToMemgraph batch-inserts nodes from a live LND graph into Memgraph
-// using UNWIND for efficient bulk writes.
-func writeNodesToMemgraph(session neo4j.Session, nodes []lndclient.Node) error {
-	const batchSize = 100
+// FeatureBits returns the bits set in a node's feature vector in ascending
+// order, as stored in the features property of nodes.
+func FeatureBits(features []lnwire.FeatureBit) []int64 {
+	bits := make([]int64, 0, len(features))
+	for _, bit := range features {
+		bits = append(bits, int64(bit))
+	}
+	sort.Slice(bits, func(i, j int) bool { return bits[i] < bits[j] })
+	return bits
+}
 
-	for i := 0; i < len(nodes); i += batchSize {
-		end := i + batchSize
-		if end > len(nodes) {
-			end = len(nodes)
+// featureBits returns the bits of a snapshot node's features, keyed by bit
+// number in describegraph output, in ascending order.
+func (n Node) featureBits() []int64 {
+	bits := make([]int64, 0, len(n.Features))
+	for key := range n.Features {
+		if bit, err := strconv.ParseInt(key, 10, 64); err == nil {
+			bits = append(bits, bit)
 		}
-		batch := nodes[i:end]
+	}
+	sort.Slice(bits, func(i, j int) bool { return bits[i] < bits[j] })
+	return bits
+}
 
-		records := make([]map[string]interface{}, 0, len(batch))
-		for _, node := range batch {
+// writeNodesToMemgraph batch-inserts nodes from a live LND graph into the given
+// namespace using UNWIND for efficient bulk writes. Nodes are converted and
+// written ChunkSize at a time, each chunk split evenly over Concurrency
+// sessions.
+func writeNodesToMemgraph(ctx context.Context, driver neo4j.Driver, namespace string, nodes []lndclient.Node) error {
+	query := `
+		UNWIND $rows AS row
+		MERGE (n:node {pubkey: row.pubKey, namespace: $namespace})
+		SET n.alias = row.alias, n.addresses = row.addresses, n.features = row.features, n.last_update = row.lastUpdate,
+			n += row.addressClass
+	`
+	return chunks(len(nodes), func(start, end int) error {
+		records := make([]map[string]interface{}, 0, end-start)
+		for _, node := range nodes[start:end] {
 			records = append(records, map[string]interface{}{
-				"pubKey":    node.PubKey.String(),
-				"alias":     node.Alias,
-				"addresses": node.Addresses,
+				"pubKey":       node.PubKey.String(),
+				"alias":        node.Alias,
+				"addresses":    node.Addresses,
+				"addressClass": ClassifyAddresses(node.Addresses),
+				"features":     FeatureBits(node.Features),
+				"lastUpdate":   node.LastUpdate.Unix(),
 			})
 		}
-
-		query := `
-			UNWIND $rows AS row
-			MERGE (n:node {pubkey: row.pubKey})
-			SET n.alias = row.alias, n.addresses = row.addresses
-		`
-
-		params := map[string]interface{}{"rows": records}
-
-		_, err := session.Run(query, params)
-		if err != nil {
-			return fmt.Errorf("failed to execute batch node query: %w", err)
+		if err := writePartitions(ctx, driver, query, namespace, splitEvenly(records, Concurrency)); err != nil {
+			return fmt.Errorf("failed to write nodes: %w", err)
 		}
-	}
-	return nil
-}
-
-// createNodeIndex creates a database index on node pubkeys for fast lookups.
-func createNodeIndex(session neo4j.Session) error {
-	_, err := session.Run("CREATE INDEX ON :node(pubkey)", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create node index: %w", err)
-	}
-	return nil
+		if end < len(nodes) {
+			log.Printf("Wrote %d/%d nodes", end, len(nodes))
+		}
+		return nil
+	})
 }
 
-// createIndexForChannels creates a database index on edge channel_ids for fast lookups.
-func createIndexForChannels(session neo4j.Session) error {
-	_, err := session.Run("CREATE INDEX ON :edge(channel_id)", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create channel index: %w", err)
+// createIndexes creates indexes on node pubkeys and edge channel_ids for fast
+// lookups, on edge block heights for range queries, and on the nodes of
+// journaled policy changes. The indexes are
+// shared by all namespaces and usually exist already, so failures are logged
+// rather than returned.
+func createIndexes(session neo4j.Session) {
+	for _, query := range []string{
+		"CREATE INDEX ON :node(pubkey)",
+		"CREATE INDEX ON :edge(channel_id)",
+		"CREATE INDEX ON :edge(block_height)",
+		"CREATE INDEX ON :policy_change(node)",
+	} {
+		if _, err := session.Run(query, nil); err != nil {
+			log.Printf("Failed to create index (%s): %v", query, err)
+		}
 	}
-	return nil
 }
 
-// writeChannelsToMemgraph batch-inserts channel edges from a live LND graph into Memgraph.
+// writeChannelsToMemgraph batch-inserts channel edges from a live LND graph into a namespace.
 // Each channel produces two directed edges (one per routing policy direction).
-func writeChannelsToMemgraph(session neo4j.Session, edges []lndclient.ChannelEdge) error {
-	const batchSize = 100
+// Channels are converted and written ChunkSize at a time. Within a chunk
+// they are partitioned by channel ID over Concurrency sessions, so both
+// directions of a channel are always written by the same session.
+func writeChannelsToMemgraph(ctx context.Context, driver neo4j.Driver, namespace string, edges []lndclient.ChannelEdge) error {
+	return chunks(len(edges), func(start, end int) error {
+		if err := writeChannelChunk(ctx, driver, namespace, edges[start:end]); err != nil {
+			return err
+		}
+		if end < len(edges) {
+			log.Printf("Wrote %d/%d channels", end, len(edges))
+		}
+		return nil
+	})
+}
 
-	// Flatten all channel policies into directional edge records.
-	relations := []map[string]interface{}{}
+// writeChannelChunk writes one chunk of channels for writeChannelsToMemgraph.
+func writeChannelChunk(ctx context.Context, driver neo4j.Driver, namespace string, edges []lndclient.ChannelEdge) error {
+	// Flatten the chunk's channel policies into directional edge records.
+	partitions := make([][]map[string]interface{}, Concurrency)
 
 	for _, edge := range edges {
-		// Convert the compact channel ID to block-height format, using 'x' as separator
-		// for Memgraph compatibility.
-		chanID := strings.Replace(convertChannelIDToString(edge.ChannelID), ":", "x", -1)
+		p := edge.ChannelID % uint64(Concurrency)
+		chanID := convertChannelIDToString(edge.ChannelID)
 
 		if edge.Node1Policy != nil {
-			relations = append(relations, map[string]interface{}{
+			partitions[p] = append(partitions[p], map[string]interface{}{
 				"from":          edge.Node1.String(),
 				"to":            edge.Node2.String(),
 				"chan_id":       chanID,
+				"chan_point":    edge.ChannelPoint,
+				"scid":          SCID(edge.ChannelID),
+				"block_height":  BlockHeight(edge.ChannelID),
 				"capacity":      edge.Capacity,
+				"last_update":   edge.Node1Policy.LastUpdate.Unix(),
 				"fee_base":      edge.Node1Policy.FeeBaseMsat,
 				"fee_rate":      edge.Node1Policy.FeeRateMilliMsat,
 				"time_lock":     edge.Node1Policy.TimeLockDelta,
@@ -162,11 +291,15 @@ func writeChannelsToMemgraph(session neo4j.Session, edges []lndclient.ChannelEdg
 		}
 
 		if edge.Node2Policy != nil {
-			relations = append(relations, map[string]interface{}{
+			partitions[p] = append(partitions[p], map[string]interface{}{
 				"from":          edge.Node2.String(),
 				"to":            edge.Node1.String(),
 				"chan_id":       chanID,
+				"chan_point":    edge.ChannelPoint,
+				"scid":          SCID(edge.ChannelID),
+				"block_height":  BlockHeight(edge.ChannelID),
 				"capacity":      edge.Capacity,
+				"last_update":   edge.Node2Policy.LastUpdate.Unix(),
 				"fee_base":      edge.Node2Policy.FeeBaseMsat,
 				"fee_rate":      edge.Node2Policy.FeeRateMilliMsat,
 				"time_lock":     edge.Node2Policy.TimeLockDelta,
@@ -180,118 +313,272 @@ func writeChannelsToMemgraph(session neo4j.Session, edges []lndclient.ChannelEdg
 	}
 
 	// Write edges in batches using UNWIND.
-	for i := 0; i < len(relations); i += batchSize {
-		end := i + batchSize
-		if end > len(relations) {
-			end = len(relations)
-		}
+	query := `
+		UNWIND $rows AS row
+		MATCH (a:node {pubkey: row.from, namespace: $namespace}), (b:node {pubkey: row.to, namespace: $namespace})
+		MERGE (a)-[r:edge {channel_id: row.chan_id}]->(b)
+		SET r.namespace = $namespace,
+			r.chan_point = row.chan_point,
+			r.scid = row.scid,
+			r.block_height = row.block_height,
+			r.capacity = row.capacity,
+			r.fee_base_msat = row.fee_base,
+			r.fee_rate_milli_msat = row.fee_rate,
+			r.time_lock_delta = row.time_lock,
+			r.disabled = row.disabled,
+			r.min_htlc_msat = row.min_htlc,
+			r.max_htlc_msat = row.max_htlc,
+			r.min_liquidity = row.min_liquidity,
+			r.max_liquidity = row.max_liquidity,
+			r.last_update = row.last_update
+	`
+	if err := writePartitions(ctx, driver, query, namespace, partitions); err != nil {
+		return fmt.Errorf("failed to write channels: %w", err)
+	}
+	return nil
+}
 
-		batch := relations[i:end]
-		query := `
-			UNWIND $rows AS row
-			MATCH (a:node {pubkey: row.from}), (b:node {pubkey: row.to})
-			MERGE (a)-[r:edge {channel_id: row.chan_id, capacity: row.capacity}]->(b)
-			SET r.fee_base_msat = row.fee_base,
-				r.fee_rate_milli_msat = row.fee_rate,
-				r.time_lock_delta = row.time_lock,
-				r.disabled = row.disabled,
-				r.min_htlc_msat = row.min_htlc,
-				r.max_htlc_msat = row.max_htlc,
-			    r.min_liquidity = row.min_liquidity,
-			    r.max_liquidity = row.max_liquidity
-		`
+// PullTimeout bounds each attempt to pull the graph, and PullAttempts the
+// number of attempts made when pulls time out or fail transiently.
+var (
+	PullTimeout  = 10 * time.Minute
+	PullAttempts = 3
+)
 
-		params := map[string]interface{}{"rows": batch}
-		_, err := session.Run(query, params)
-		if err != nil {
-			return fmt.Errorf("failed to execute batch channel query: %w", err)
+// pullBackoff is the wait before the second attempt; it doubles after every
+// further failure.
+const pullBackoff = 5 * time.Second
+
+// PullGraph fetches the complete channel graph from a source, usually LND.
+// Each attempt is limited to PullTimeout; attempts that time out or fail
+// with a transient gRPC error are retried, up to PullAttempts in total.
+// Cancelling ctx aborts the pull. A pulled graph is stored in CacheFile.
+func PullGraph(ctx context.Context, source GraphSource) (*lndclient.Graph, error) {
+	backoff := pullBackoff
+	for attempt := 1; ; attempt++ {
+		log.Printf("Pulling graph (attempt %d/%d)...", attempt, PullAttempts)
+		start := time.Now()
+		attemptCtx, cancel := context.WithTimeout(ctx, PullTimeout)
+		graph, err := source.DescribeGraph(attemptCtx)
+		cancel()
+		if err == nil {
+			log.Printf("Pulled %d nodes and %d channels in %s", len(graph.Nodes), len(graph.Edges),
+				time.Since(start).Round(time.Millisecond))
+			cacheGraph(graph)
+			return graph, nil
+		}
+		if attempt >= PullAttempts || ctx.Err() != nil || !transient(err) {
+			return nil, fmt.Errorf("failed to pull graph after %d attempt(s): %w", attempt, err)
 		}
+		log.Printf("Graph pull attempt %d failed after %s: %v; retrying in %s", attempt,
+			time.Since(start).Round(time.Millisecond), err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to pull graph: %w", ctx.Err())
+		}
+		backoff *= 2
 	}
-	return nil
 }
 
-// PullGraph fetches the complete channel graph from LND with a 10-minute timeout.
-func PullGraph(lndServices *lndclient.GrpcLndServices) (*lndclient.Graph, error) {
-	log.Println("Pulling graph...")
-	duration := 10 * 60 * time.Second
-	_ctx := context.WithoutCancel(context.Background())
-	ctx, cancel := context.WithTimeout(_ctx, duration)
-	defer cancel()
-	graph, err := lndServices.Client.DescribeGraph(ctx, false)
+// transient reports whether a failed RPC is worth retrying: it timed out,
+// or the node was unreachable or busy.
+func transient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// ImportGraph pulls the graph from source and writes it into a Memgraph
+// namespace. Unlike PullGraph followed by WriteGraphToMemgraph, nothing
+// holds on to the pulled nodes once they are written, so they can be
+// collected while channels are written.
+func ImportGraph(ctx context.Context, source GraphSource, neo4jDriver neo4j.Driver, namespace string) (WriteStats, error) {
+	graph, err := PullGraph(ctx, source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to pull graph: %w", err)
+		return WriteStats{}, err
 	}
-	return graph, nil
+	return writeGraph(ctx, neo4jDriver, namespace, graph.Nodes, graph.Edges)
 }
 
-// WriteGraphToMemgraph writes a live LND graph to Memgraph, creating indexes first
-// then batch-inserting nodes and channels.
-func WriteGraphToMemgraph(graph *lndclient.Graph, neo4jDriver neo4j.Driver) error {
+// WriteGraphToMemgraph writes a live LND graph into a Memgraph namespace,
+// creating indexes first then batch-inserting nodes and, once all nodes
+// exist, channels. Returns how long each phase took. Once ctx is done no
+// further batches are written.
+func WriteGraphToMemgraph(ctx context.Context, graph *lndclient.Graph, neo4jDriver neo4j.Driver, namespace string) (WriteStats, error) {
+	return writeGraph(ctx, neo4jDriver, namespace, graph.Nodes, graph.Edges)
+}
+
+// writeGraph implements WriteGraphToMemgraph. nodes is not used once
+// written.
+func writeGraph(ctx context.Context, neo4jDriver neo4j.Driver, namespace string, nodes []lndclient.Node, edges []lndclient.ChannelEdge) (WriteStats, error) {
 	session := neo4jDriver.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
-	log.Println("Writing to Memgraph...")
-	if err := createNodeIndex(session); err != nil {
-		return err
+	stats := WriteStats{Nodes: len(nodes)}
+	for _, edge := range edges {
+		if edge.Node1Policy != nil {
+			stats.Edges++
+		}
+		if edge.Node2Policy != nil {
+			stats.Edges++
+		}
 	}
-	if err := createIndexForChannels(session); err != nil {
-		return err
+
+	log.Printf("Writing to Memgraph namespace %q...", namespace)
+	createIndexes(session)
+	start := time.Now()
+	if err := writeNodesToMemgraph(ctx, neo4jDriver, namespace, nodes); err != nil {
+		return stats, err
 	}
-	if err := writeNodesToMemgraph(session, graph.Nodes); err != nil {
-		return err
+	stats.NodeTime = time.Since(start)
+	start = time.Now()
+	if err := writeChannelsToMemgraph(ctx, neo4jDriver, namespace, edges); err != nil {
+		return stats, err
 	}
-	if err := writeChannelsToMemgraph(session, graph.Edges); err != nil {
-		return err
+	stats.EdgeTime = time.Since(start)
+	log.Printf("Finished writing to Memgraph: %d nodes in %s, %d edges in %s.", stats.Nodes,
+		stats.NodeTime.Round(time.Millisecond), stats.Edges, stats.EdgeTime.Round(time.Millisecond))
+	return stats, nil
+}
+
+// WriteStats describes a full-graph write. Edges counts directed edges,
+// i.e. channel policies, not channels.
+type WriteStats struct {
+	Nodes    int
+	Edges    int
+	NodeTime time.Duration
+	EdgeTime time.Duration
+}
+
+// LndGraph converts a snapshot into the graph type LND returns, so that it
+// can be written through the batched import path. Like WriteSnapshotToMemgraph,
+// it drops policies without max_htlc_msat. The snapshot should be validated
+// first; records that fail to parse are returned as an error.
+func (g *Graph) LndGraph() (*lndclient.Graph, error) {
+	graph := &lndclient.Graph{Nodes: make([]lndclient.Node, 0, len(g.Nodes)), Edges: make([]lndclient.ChannelEdge, 0, len(g.Edges))}
+	for _, node := range g.Nodes {
+		pubKey, err := route.NewVertexFromStr(node.Pub_Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node %s: %w", node.Pub_Key, err)
+		}
+		graph.Nodes = append(graph.Nodes, lndclient.Node{PubKey: pubKey, LastUpdate: time.Unix(node.LastUpdate, 0),
+			Alias: node.Alias, Color: node.Color})
 	}
-	log.Println("Finished writing to Memgraph.")
-	return nil
+	for _, edge := range g.Edges {
+		scid, err := strconv.ParseUint(edge.ChannelId, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel %s: %w", edge.ChannelId, err)
+		}
+		node1, err := route.NewVertexFromStr(edge.Node1_Pub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel %s: %w", edge.ChannelId, err)
+		}
+		node2, err := route.NewVertexFromStr(edge.Node2_Pub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel %s: %w", edge.ChannelId, err)
+		}
+		capacity, _ := strconv.ParseInt(edge.Capacity, 10, 64)
+		graph.Edges = append(graph.Edges, lndclient.ChannelEdge{
+			ChannelID: scid, ChannelPoint: edge.ChanPoint, Capacity: btcutil.Amount(capacity),
+			Node1: node1, Node2: node2,
+			Node1Policy: edge.Node1Policy.lndPolicy(), Node2Policy: edge.Node2Policy.lndPolicy(),
+		})
+	}
+	return graph, nil
 }
 
-// WriteSnapshotToMemgraph loads a describegraph.json file and writes its contents
-// to Memgraph. Used when no LND connection is available.
-func WriteSnapshotToMemgraph(snapshotFilename string, neo4jDriver neo4j.Driver) error {
-	session := neo4jDriver.NewSession(neo4j.SessionConfig{})
-	defer session.Close()
+// lndPolicy converts a snapshot routing policy, returning nil for a missing
+// one.
+func (p RoutingPolicy) lndPolicy() *lndclient.RoutingPolicy {
+	if p.MaxHtlcMsat == "" {
+		return nil
+	}
+	minHtlc, _ := strconv.ParseInt(p.MinHtlc, 10, 64)
+	maxHtlc, _ := strconv.ParseUint(p.MaxHtlcMsat, 10, 64)
+	feeBase, _ := strconv.ParseInt(p.FeeBaseMsat, 10, 64)
+	feeRate, _ := strconv.ParseInt(p.FeeRateMilliMsat, 10, 64)
+	return &lndclient.RoutingPolicy{
+		TimeLockDelta:    uint32(p.TimeLockDelta),
+		MinHtlcMsat:      minHtlc,
+		MaxHtlcMsat:      maxHtlc,
+		FeeBaseMsat:      feeBase,
+		FeeRateMilliMsat: feeRate,
+		Disabled:         p.Disabled,
+		LastUpdate:       time.Unix(int64(p.LastUpdate), 0),
+	}
+}
 
+// ReadSnapshot loads and decodes a describegraph.json file without touching the database.
+func ReadSnapshot(snapshotFilename string) (*Graph, error) {
 	jsonFile, err := os.Open(snapshotFilename)
 	if err != nil {
-		return fmt.Errorf("failed to open snapshot: %w", err)
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
 	}
 	defer jsonFile.Close()
+	return DecodeSnapshot(jsonFile)
+}
 
-	byteValue, err := io.ReadAll(jsonFile)
+// DecodeSnapshot reads a describegraph snapshot from r.
+func DecodeSnapshot(r io.Reader) (*Graph, error) {
+	byteValue, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("failed to read snapshot: %w", err)
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
 	}
 	var graph Graph
 	if err := json.Unmarshal(byteValue, &graph); err != nil {
-		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
 	}
+	return &graph, nil
+}
 
-	log.Println("Writing snapshot to Memgraph...")
-	if err := createNodeIndex(session); err != nil {
-		return err
-	}
-	if err := createIndexForChannels(session); err != nil {
+// WriteSnapshotToMemgraph writes a decoded (and ideally validated) snapshot graph
+// into a Memgraph namespace. Used when no LND connection is available.
+// Writing stops, returning ctx's error, once ctx is done.
+func WriteSnapshotToMemgraph(ctx context.Context, graph *Graph, neo4jDriver neo4j.Driver, namespace string) error {
+	session := neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	log.Printf("Writing snapshot to Memgraph namespace %q...", namespace)
+	createIndexes(session)
+	writeSnapshotNodesToMemgraph(ctx, session, namespace, graph.Nodes)
+	writeSnapshotChannelsToMemgraph(ctx, session, namespace, graph.Edges)
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	writeSnapshotNodesToMemgraph(session, graph.Nodes)
-	writeSnapshotChannelsToMemgraph(session, graph.Edges)
 	log.Println("Finished writing snapshot to Memgraph.")
 	return nil
 }
 
 // writeSnapshotNodesToMemgraph inserts nodes from a JSON snapshot one at a time.
-// Each node is tagged with is_wumbo based on whether feature bit 19 is present.
-func writeSnapshotNodesToMemgraph(session neo4j.Session, nodes []Node) {
+// Each node is tagged with is_wumbo based on whether feature bit 19 is present,
+// and with invalid_signature if it is flagged.
+func writeSnapshotNodesToMemgraph(ctx context.Context, session neo4j.Session, namespace string, nodes []Node) {
 	for _, node := range nodes {
+		if ctx.Err() != nil {
+			return
+		}
 		_, is_wumbo := node.Features["19"]
+		addresses := node.addressList()
 
-		query := "MERGE (n:node {pubkey: $pubKey, alias: $alias, is_wumbo: $is_wumbo})"
+		query := "MERGE (n:node {pubkey: $pubKey, namespace: $namespace})\nSET n.alias = $alias, n.is_wumbo = $is_wumbo, n.features = $features, n.last_update = $lastUpdate,\n" +
+			"n.addresses = $addresses, n += $addressClass,\n" +
+			"n.invalid_signature = CASE WHEN $invalidSignature THEN true ELSE null END"
 		params := map[string]interface{}{
-			"pubKey":   node.Pub_Key,
-			"alias":    node.Alias,
-			"is_wumbo": is_wumbo,
+			"namespace":        namespace,
+			"pubKey":           node.Pub_Key,
+			"alias":            node.Alias,
+			"is_wumbo":         is_wumbo,
+			"features":         node.featureBits(),
+			"lastUpdate":       node.LastUpdate,
+			"addresses":        addresses,
+			"addressClass":     ClassifyAddresses(addresses),
+			"invalidSignature": node.InvalidSignature,
 		}
 		_, err := session.Run(query, params)
 		if err != nil {
@@ -302,35 +589,52 @@ func writeSnapshotNodesToMemgraph(session neo4j.Session, nodes []Node) {
 
 // writeSnapshotChannelsToMemgraph inserts channel edges from a JSON snapshot,
 // writing both directions (node1->node2 and node2->node1) for each channel.
-func writeSnapshotChannelsToMemgraph(session neo4j.Session, edges []ChannelEdge) {
+// Channels with unparsable IDs are skipped; ValidateSnapshot reports them.
+func writeSnapshotChannelsToMemgraph(ctx context.Context, session neo4j.Session, namespace string, edges []ChannelEdge) {
 	for _, edge := range edges {
-		chanID := convertChannelIDToString(edge.ChannelId)
-		writeChannelPolicyToMemgraphSnapshot(session, &edge, edge.Node1Policy, edge.Node1_Pub, edge.Node2_Pub, chanID)
-		writeChannelPolicyToMemgraphSnapshot(session, &edge, edge.Node2Policy, edge.Node2_Pub, edge.Node1_Pub, chanID)
+		if ctx.Err() != nil {
+			return
+		}
+		scid, err := strconv.ParseUint(edge.ChannelId, 10, 64)
+		if err != nil {
+			log.Printf("Skipping channel with invalid id %q", edge.ChannelId)
+			continue
+		}
+		writeChannelPolicyToMemgraphSnapshot(session, namespace, &edge, edge.Node1Policy, edge.Node1_Pub, edge.Node2_Pub, scid)
+		writeChannelPolicyToMemgraphSnapshot(session, namespace, &edge, edge.Node2Policy, edge.Node2_Pub, edge.Node1_Pub, scid)
 	}
 }
 
 // writeChannelPolicyToMemgraphSnapshot writes a single directional channel policy
 // to Memgraph. Skipped if the policy has no MaxHtlcMsat (indicates an empty/missing policy).
-func writeChannelPolicyToMemgraphSnapshot(session neo4j.Session, edge *ChannelEdge, policy RoutingPolicy, node1PubKey, node2PubKey, chanID string) {
+// The edge gets invalid_signature if the channel or the policy is flagged.
+func writeChannelPolicyToMemgraphSnapshot(session neo4j.Session, namespace string, edge *ChannelEdge, policy RoutingPolicy, node1PubKey, node2PubKey string, scid uint64) {
 	if policy.MaxHtlcMsat != "" {
 		query := `
-          MATCH (a:node {pubkey: $node1}), (b:node {pubkey: $node2})
-          MERGE (a)-[r:edge {channel_id: $chanID, capacity: $capacity}]->(b)
-          SET r.fee_base_msat = $feeBase, r.fee_rate_milli_msat = $feeRate, r.time_lock_delta = $timeLock,
-			r.disabled = $disabled, r.min_htlc_msat = $minHtlc, r.max_htlc_msat = $maxHtlc
+          MATCH (a:node {pubkey: $node1, namespace: $namespace}), (b:node {pubkey: $node2, namespace: $namespace})
+          MERGE (a)-[r:edge {channel_id: $chanID}]->(b)
+          SET r.namespace = $namespace, r.chan_point = $chanPoint, r.scid = $scid, r.block_height = $blockHeight, r.capacity = $capacity, r.fee_base_msat = $feeBase, r.fee_rate_milli_msat = $feeRate, r.time_lock_delta = $timeLock,
+			r.disabled = $disabled, r.min_htlc_msat = $minHtlc, r.max_htlc_msat = $maxHtlc,
+			r.last_update = $lastUpdate,
+			r.invalid_signature = CASE WHEN $invalidSignature THEN true ELSE null END
 		`
 		params := map[string]interface{}{
-			"node1":    node1PubKey,
-			"node2":    node2PubKey,
-			"chanID":   chanID,
-			"capacity": edge.Capacity,
-			"feeBase":  policy.FeeBaseMsat,
-			"feeRate":  policy.FeeRateMilliMsat,
-			"timeLock": policy.TimeLockDelta,
-			"disabled": policy.Disabled,
-			"minHtlc":  policy.MinHtlc,
-			"maxHtlc":  policy.MaxHtlcMsat,
+			"invalidSignature": edge.InvalidSignature || policy.InvalidSignature,
+			"namespace":        namespace,
+			"node1":            node1PubKey,
+			"node2":            node2PubKey,
+			"chanID":           convertChannelIDToString(scid),
+			"chanPoint":        edge.ChanPoint,
+			"scid":             int64(scid),
+			"blockHeight":      BlockHeight(scid),
+			"capacity":         edge.Capacity,
+			"feeBase":          policy.FeeBaseMsat,
+			"feeRate":          policy.FeeRateMilliMsat,
+			"timeLock":         policy.TimeLockDelta,
+			"disabled":         policy.Disabled,
+			"minHtlc":          policy.MinHtlc,
+			"maxHtlc":          policy.MaxHtlcMsat,
+			"lastUpdate":       policy.LastUpdate,
 		}
 		_, err := session.Run(query, params)
 		if err != nil {
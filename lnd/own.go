@@ -0,0 +1,168 @@
+package lnd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// OwnChannel is the local node's view of one of its channels, as reported
+// by LND's ListChannels rather than learned from gossip.
+type OwnChannel struct {
+	ChannelID     string
+	Peer          string
+	LocalBalance  int64
+	RemoteBalance int64
+	PendingHTLCs  int
+	Active        bool
+	Private       bool
+}
+
+// Peer is a node the local node is currently connected to.
+type Peer struct {
+	PubKey        string
+	Address       string
+	Inbound       bool
+	PingTime      time.Duration
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// OwnChannels returns a function listing the channels of a connected LND
+// node running on network, along with the node's pubkey. It fails for graphs
+// of other networks.
+func OwnChannels(services *lndclient.GrpcLndServices, network string) func(context.Context, string) (string, []OwnChannel, error) {
+	return func(ctx context.Context, graphNetwork string) (string, []OwnChannel, error) {
+		if graphNetwork != "" && graphNetwork != network {
+			return "", nil, fmt.Errorf("LND runs on %s, not %s", network, graphNetwork)
+		}
+		info, err := services.Client.GetInfo(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get node info from LND: %w", err)
+		}
+		infos, err := services.Client.ListChannels(ctx, false, false)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list channels from LND: %w", err)
+		}
+		channels := make([]OwnChannel, 0, len(infos))
+		for _, c := range infos {
+			channels = append(channels, OwnChannel{
+				ChannelID:     convertChannelIDToString(c.ChannelID),
+				Peer:          c.PubKeyBytes.String(),
+				LocalBalance:  int64(c.LocalBalance),
+				RemoteBalance: int64(c.RemoteBalance),
+				PendingHTLCs:  c.NumPendingHtlcs,
+				Active:        c.Active,
+				Private:       c.Private,
+			})
+		}
+		return hex.EncodeToString(info.IdentityPubkey[:]), channels, nil
+	}
+}
+
+// Peers returns a function listing the current peers of a connected LND node
+// running on network. It fails for graphs of other networks. LND's gossip
+// sync state of each peer is not available through lndclient.
+func Peers(services *lndclient.GrpcLndServices, network string) func(context.Context, string) ([]Peer, error) {
+	return func(ctx context.Context, graphNetwork string) ([]Peer, error) {
+		if graphNetwork != "" && graphNetwork != network {
+			return nil, fmt.Errorf("LND runs on %s, not %s", network, graphNetwork)
+		}
+		infos, err := services.Client.ListPeers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list peers from LND: %w", err)
+		}
+		peers := make([]Peer, 0, len(infos))
+		for _, p := range infos {
+			peers = append(peers, Peer{
+				PubKey:        p.Pubkey.String(),
+				Address:       p.Address,
+				Inbound:       p.Inbound,
+				PingTime:      p.PingTime,
+				BytesSent:     p.BytesSent,
+				BytesReceived: p.BytesReceived,
+			})
+		}
+		return peers, nil
+	}
+}
+
+// ClosedChannel is a channel of the local node that has been closed.
+type ClosedChannel struct {
+	ChannelID   string
+	CloseType   string
+	CloseHeight int64
+	ClosingTx   string
+}
+
+// ClosedChannels returns a function listing the closed channels of a
+// connected LND node, along with the network the node runs on.
+func ClosedChannels(services *lndclient.GrpcLndServices, network string) func(context.Context) (string, []ClosedChannel, error) {
+	return func(ctx context.Context) (string, []ClosedChannel, error) {
+		infos, err := services.Client.ClosedChannels(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list closed channels from LND: %w", err)
+		}
+		channels := make([]ClosedChannel, 0, len(infos))
+		for _, c := range infos {
+			if c.ChannelID == 0 {
+				// Funding never confirmed, so the channel was never in the graph.
+				continue
+			}
+			channels = append(channels, ClosedChannel{
+				ChannelID:   convertChannelIDToString(c.ChannelID),
+				CloseType:   strings.ToLower(strings.ReplaceAll(c.CloseType.String(), " ", "_")),
+				CloseHeight: int64(c.CloseHeight),
+				ClosingTx:   c.ClosingTxHash,
+			})
+		}
+		return network, channels, nil
+	}
+}
+
+// PendingChannel is a channel of the local node that is waiting to be opened
+// or closed on chain. State is opening, waiting_close or force_closing.
+type PendingChannel struct {
+	ChanPoint string
+	Peer      string
+	Capacity  int64
+	State     string
+}
+
+// PendingChannels returns a function listing the pending channels of a
+// connected LND node running on network, along with the node's pubkey. It
+// fails for graphs of other networks.
+func PendingChannels(services *lndclient.GrpcLndServices, network string) func(context.Context, string) (string, []PendingChannel, error) {
+	return func(ctx context.Context, graphNetwork string) (string, []PendingChannel, error) {
+		if graphNetwork != "" && graphNetwork != network {
+			return "", nil, fmt.Errorf("LND runs on %s, not %s", network, graphNetwork)
+		}
+		pending, err := services.Client.PendingChannels(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list pending channels from LND: %w", err)
+		}
+		var channels []PendingChannel
+		add := func(c lndclient.PendingChannel, state string) {
+			channels = append(channels, PendingChannel{
+				ChanPoint: c.ChannelPoint.String(),
+				Peer:      c.PubKeyBytes.String(),
+				Capacity:  int64(c.Capacity),
+				State:     state,
+			})
+		}
+		for _, c := range pending.PendingOpen {
+			add(c, "opening")
+		}
+		for _, c := range pending.WaitingClose {
+			add(c.PendingChannel, "waiting_close")
+		}
+		for _, c := range pending.PendingForceClose {
+			add(c.PendingChannel, "force_closing")
+		}
+		return hex.EncodeToString(services.NodePubkey[:]), channels, nil
+	}
+}
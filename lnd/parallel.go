@@ -0,0 +1,108 @@
+package lnd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Concurrency is the number of sessions that write batches in parallel during
+// full-graph imports.
+var Concurrency = 4
+
+// maxBatchAttempts bounds how often a batch is retried. Concurrent batches
+// touching the same node can conflict in Memgraph, in which case one of them
+// fails and has to be run again.
+const maxBatchAttempts = 5
+
+// runBatch runs query for one batch of rows, retrying with a short backoff
+// until ctx is done.
+func runBatch(ctx context.Context, session neo4j.Session, query, namespace string, rows []map[string]interface{}) error {
+	params := map[string]interface{}{"rows": rows, "namespace": namespace}
+	var err error
+	for attempt := 1; attempt <= maxBatchAttempts; attempt++ {
+		if _, err = session.Run(query, params); err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// writePartitions writes each partition of rows in batches of BatchSize,
+// handing partitions to up to Concurrency workers with their own sessions.
+// Rows within a partition are written in order by a single worker. Returns
+// the first error encountered; remaining partitions are skipped once a batch
+// has failed or ctx is done.
+func writePartitions(ctx context.Context, driver neo4j.Driver, query, namespace string, partitions [][]map[string]interface{}) error {
+	work := make(chan []map[string]interface{})
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if firstErr == nil {
+			firstErr = ctx.Err()
+		}
+		return firstErr != nil
+	}
+
+	for i := 0; i < Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := driver.NewSession(neo4j.SessionConfig{})
+			defer session.Close()
+			for rows := range work {
+				for start := 0; start < len(rows) && !failed(); start += BatchSize {
+					end := start + BatchSize
+					if end > len(rows) {
+						end = len(rows)
+					}
+					if err := runBatch(ctx, session, query, namespace, rows[start:end]); err != nil {
+						errMu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						errMu.Unlock()
+					}
+				}
+			}
+		}()
+	}
+
+	for _, partition := range partitions {
+		work <- partition
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("failed to execute batch query: %w", firstErr)
+	}
+	return nil
+}
+
+// splitEvenly divides rows into n contiguous partitions of similar size.
+func splitEvenly(rows []map[string]interface{}, n int) [][]map[string]interface{} {
+	partitions := make([][]map[string]interface{}, 0, n)
+	size := (len(rows) + n - 1) / n
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		partitions = append(partitions, rows[start:end])
+	}
+	return partitions
+}
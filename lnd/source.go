@@ -0,0 +1,50 @@
+package lnd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// GraphSource provides a channel graph and a stream of updates to it. A
+// connected LND node is one; the mock package provides another for demos.
+type GraphSource interface {
+	// DescribeGraph returns the complete public channel graph.
+	DescribeGraph(ctx context.Context) (*lndclient.Graph, error)
+	// SubscribeGraph streams topology updates until ctx is cancelled.
+	SubscribeGraph(ctx context.Context) (<-chan *lndclient.GraphTopologyUpdate, <-chan error, error)
+}
+
+// lndSource is the GraphSource backed by an LND node.
+type lndSource struct {
+	services *lndclient.GrpcLndServices
+}
+
+// NewLNDSource returns a GraphSource reading from a connected LND node.
+func NewLNDSource(services *lndclient.GrpcLndServices) GraphSource {
+	return lndSource{services: services}
+}
+
+func (s lndSource) DescribeGraph(ctx context.Context) (*lndclient.Graph, error) {
+	return s.services.Client.DescribeGraph(ctx, false)
+}
+
+func (s lndSource) SubscribeGraph(ctx context.Context) (<-chan *lndclient.GraphTopologyUpdate, <-chan error, error) {
+	return s.services.Client.SubscribeGraph(ctx)
+}
+
+// ChainTip returns a function reporting the block height of a connected LND
+// node running on network. It fails for graphs of other networks.
+func ChainTip(services *lndclient.GrpcLndServices, network string) func(context.Context, string) (int64, error) {
+	return func(ctx context.Context, graphNetwork string) (int64, error) {
+		if graphNetwork != "" && graphNetwork != network {
+			return 0, fmt.Errorf("LND runs on %s, not %s", network, graphNetwork)
+		}
+		info, err := services.Client.GetInfo(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get chain tip from LND: %w", err)
+		}
+		return int64(info.BlockHeight), nil
+	}
+}
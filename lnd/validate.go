@@ -0,0 +1,105 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"strconv"
+)
+
+// ValidationReport summarizes the outcome of validating a snapshot. Problems
+// counts invalid records by reason so the caller can see what was skipped.
+type ValidationReport struct {
+	ValidNodes   int            `json:"valid_nodes"`
+	InvalidNodes int            `json:"invalid_nodes"`
+	ValidEdges   int            `json:"valid_edges"`
+	InvalidEdges int            `json:"invalid_edges"`
+	Problems     map[string]int `json:"problems"`
+	// InvalidSignatures counts the kept nodes, channels and policies that
+	// are flagged with InvalidSignature.
+	InvalidSignatures int `json:"invalid_signatures,omitempty"`
+}
+
+// IsValidPubKey reports whether s is a 33-byte compressed public key in hex form.
+func IsValidPubKey(s string) bool {
+	if len(s) != 66 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// validateNode returns the reason a snapshot node is invalid, or "" if it is valid.
+func validateNode(node Node) string {
+	if !IsValidPubKey(node.Pub_Key) {
+		return "invalid node pubkey"
+	}
+	if len(node.malformed) > 0 {
+		return "unparsable node " + node.malformed[0]
+	}
+	return ""
+}
+
+// validateEdge returns the reason a snapshot channel is invalid, or "" if it is valid.
+func validateEdge(edge ChannelEdge) string {
+	if _, err := strconv.ParseUint(edge.ChannelId, 10, 64); err != nil {
+		return "unparsable channel id"
+	}
+	if !IsValidPubKey(edge.Node1_Pub) || !IsValidPubKey(edge.Node2_Pub) {
+		return "invalid channel pubkey"
+	}
+	capacity, err := strconv.ParseInt(edge.Capacity, 10, 64)
+	if err != nil {
+		return "unparsable capacity"
+	}
+	if capacity < 0 {
+		return "negative capacity"
+	}
+	if len(edge.malformed) > 0 {
+		return "unparsable channel " + edge.malformed[0]
+	}
+	for _, policy := range []RoutingPolicy{edge.Node1Policy, edge.Node2Policy} {
+		if len(policy.malformed) > 0 {
+			return "unparsable policy " + policy.malformed[0]
+		}
+	}
+	return ""
+}
+
+// ValidateSnapshot checks every node and channel in the graph and returns a copy
+// containing only the valid records, along with a report of what was dropped.
+func ValidateSnapshot(graph *Graph) (*Graph, ValidationReport) {
+	report := ValidationReport{Problems: map[string]int{}}
+	valid := &Graph{
+		Nodes: make([]Node, 0, len(graph.Nodes)),
+		Edges: make([]ChannelEdge, 0, len(graph.Edges)),
+	}
+
+	for _, node := range graph.Nodes {
+		if reason := validateNode(node); reason != "" {
+			report.InvalidNodes++
+			report.Problems[reason]++
+			continue
+		}
+		if node.InvalidSignature {
+			report.InvalidSignatures++
+		}
+		valid.Nodes = append(valid.Nodes, node)
+	}
+	report.ValidNodes = len(valid.Nodes)
+
+	for _, edge := range graph.Edges {
+		if reason := validateEdge(edge); reason != "" {
+			report.InvalidEdges++
+			report.Problems[reason]++
+			continue
+		}
+		for _, flagged := range []bool{edge.InvalidSignature, edge.Node1Policy.InvalidSignature, edge.Node2Policy.InvalidSignature} {
+			if flagged {
+				report.InvalidSignatures++
+			}
+		}
+		valid.Edges = append(valid.Edges, edge)
+	}
+	report.ValidEdges = len(valid.Edges)
+
+	return valid, report
+}
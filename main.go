@@ -3,52 +3,752 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/lightninglabs/lndclient"
+	"ln-stream/capture"
 	"ln-stream/lnd"
 	"ln-stream/memgraph"
+	"ln-stream/middleware"
+	"ln-stream/mock"
+	"ln-stream/notify"
+	"ln-stream/p2p"
 	"ln-stream/routes"
 )
 
+// regtestDefaults are the settings PROFILE=regtest applies for local
+// development against a regtest network, such as one run by Polar: the
+// node's certificate is not verified, and since such graphs are tiny,
+// derived metrics are refreshed almost immediately.
+var regtestDefaults = map[string]string{
+	"LND_TLS_SKIP_VERIFY":         "true",
+	"METRICS_REFRESH_INTERVAL":    "10s",
+	"CENTRALITY_REFRESH_INTERVAL": "1m",
+}
+
+// applyProfile fills in the defaults of the profile selected by PROFILE.
+// Variables that are already set are left alone, except LND_NETWORK, which
+// the regtest profile always sets to regtest. With POLAR_NODE_DIR, the
+// volume directory of a Polar LND node (e.g.
+// ~/.polar/networks/1/volumes/lnd/alice), the node's TLS certificate and
+// admin macaroon are taken from there.
+func applyProfile() error {
+	switch profile := os.Getenv("PROFILE"); profile {
+	case "":
+		return nil
+	case "regtest":
+	default:
+		return fmt.Errorf("unknown PROFILE %q", profile)
+	}
+
+	defaults := map[string]string{}
+	for key, value := range regtestDefaults {
+		defaults[key] = value
+	}
+	if dir := os.Getenv("POLAR_NODE_DIR"); dir != "" {
+		defaults["LND_TLS_CERT_PATH"] = filepath.Join(dir, "tls.cert")
+		defaults["LND_MACAROON_PATH"] = filepath.Join(dir, "data", "chain", "bitcoin", "regtest", "admin.macaroon")
+	}
+	for key, value := range defaults {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	if network := os.Getenv("LND_NETWORK"); network != "" && network != "regtest" {
+		log.Printf("PROFILE=regtest overrides LND_NETWORK=%s", network)
+	}
+	os.Setenv("LND_NETWORK", "regtest")
+	log.Println("Using regtest profile")
+	return nil
+}
+
+// startP2PSync starts syncing gossip from the comma-separated pubkey@host:port
+// peers in peerList, writing updates to Memgraph until ctx is done.
+// Updates go into the P2P_NAMESPACE namespace, or the default one if unset.
+func startP2PSync(ctx context.Context, peerList string) error {
+	peers, err := p2p.ParsePeers(peerList)
+	if err != nil {
+		return err
+	}
+	network := os.Getenv("LND_NETWORK")
+	if network == "" {
+		network = "mainnet"
+	}
+	chainHash, err := p2p.ChainHash(network)
+	if err != nil {
+		return err
+	}
+	namespace := os.Getenv("P2P_NAMESPACE")
+	if namespace == "" {
+		namespace = routes.DefaultNamespace()
+	}
+	if err := memgraph.EnsureNetwork(ctx, routes.Driver, namespace, network); err != nil {
+		return err
+	}
+
+	stop := ctx.Done()
+	live := memgraph.StartLive(namespace)
+	go func() {
+		<-stop
+		live()
+	}()
+	queue := memgraph.NewUpdateQueue("p2p")
+	go queue.Run(stop, func(update *lndclient.GraphTopologyUpdate) {
+		memgraph.ProcessUpdates(ctx, routes.Driver, namespace, update)
+	})
+	syncer := p2p.NewSyncer(peers, chainHash, queue.Push)
+	go syncer.Run(stop)
+	log.Printf("P2P gossip sync started with %d peers on %s (namespace %q)", len(peers), network, namespace)
+	return nil
+}
+
+// configureWrites applies write tuning from the environment. WRITE_BATCH_SIZE
+// sets the number of rows per batched write during imports (default 100), and
+// WRITE_CONCURRENCY how many sessions write in parallel (default 4).
+// WRITE_CHUNK_SIZE sets how many nodes or channels are converted and written
+// at a time (default 10000). GRAPH_PULL_TIMEOUT limits each attempt to pull
+// the graph from LND (default 10m) and GRAPH_PULL_ATTEMPTS how many attempts
+// are made (default 3). GRAPH_CACHE_FILE is where the last pulled graph is
+// cached for /reset-graph?use_cache=true (default
+// ./ln-stream-graph-cache.gob.gz, "off" disables the cache).
+// UPDATE_QUEUE_WARN sets the number of pending live updates above which a
+// falling-behind warning is logged (default 10000). BETWEENNESS_SAMPLES
+// switches post-import betweenness from exact to sampled (default 0, exact).
+func configureWrites() error {
+	if v := os.Getenv("WRITE_BATCH_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			return fmt.Errorf("WRITE_BATCH_SIZE must be a positive integer, got %q", v)
+		}
+		lnd.BatchSize = size
+	}
+	if v := os.Getenv("WRITE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("WRITE_CONCURRENCY must be a positive integer, got %q", v)
+		}
+		lnd.Concurrency = n
+	}
+	if v := os.Getenv("WRITE_CHUNK_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("WRITE_CHUNK_SIZE must be a positive integer, got %q", v)
+		}
+		lnd.ChunkSize = n
+	}
+	if v := os.Getenv("GRAPH_PULL_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("GRAPH_PULL_TIMEOUT must be a positive duration, got %q", v)
+		}
+		lnd.PullTimeout = d
+	}
+	if v := os.Getenv("GRAPH_PULL_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("GRAPH_PULL_ATTEMPTS must be a positive integer, got %q", v)
+		}
+		lnd.PullAttempts = n
+	}
+	if v := os.Getenv("GRAPH_CACHE_FILE"); v != "off" {
+		lnd.CacheFile = v
+		if v == "" {
+			lnd.CacheFile = "./ln-stream-graph-cache.gob.gz"
+		}
+	}
+	if v := os.Getenv("UPDATE_QUEUE_WARN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("UPDATE_QUEUE_WARN must be a positive integer, got %q", v)
+		}
+		memgraph.QueueWarnAt = n
+	}
+	if v := os.Getenv("BETWEENNESS_SAMPLES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("BETWEENNESS_SAMPLES must be a non-negative integer, got %q", v)
+		}
+		memgraph.BetweennessSamples = n
+	}
+	return nil
+}
+
+// startDatabaseHealthCheck waits up to NEO4J_CONNECT_TIMEOUT (default 1m)
+// for Memgraph to accept connections, then checks the connection every
+// NEO4J_HEALTH_INTERVAL (default 30s, "off" disables the check), recreating
+// the driver while Memgraph is down, until ctx is done.
+func startDatabaseHealthCheck(ctx context.Context) error {
+	timeout := time.Minute
+	if v := os.Getenv("NEO4J_CONNECT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("NEO4J_CONNECT_TIMEOUT must be a positive duration, got %q", v)
+		}
+		timeout = d
+	}
+	interval := 30 * time.Second
+	if v := os.Getenv("NEO4J_HEALTH_INTERVAL"); v == "off" {
+		interval = 0
+	} else if v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("NEO4J_HEALTH_INTERVAL must be a positive duration or off, got %q", v)
+		}
+		interval = d
+	}
+
+	if err := memgraph.WaitForDatabase(routes.Driver, timeout); err != nil {
+		return err
+	}
+	if interval > 0 {
+		go memgraph.RunHealthCheck(routes.Driver, interval, ctx.Done())
+	}
+	return nil
+}
+
+// startStalePruning starts the background task that handles gossip older than
+// ttl until ctx is done. STALE_ACTION selects "flag" (default) or "delete", and
+// STALE_CHECK_INTERVAL how often to run (default 1h).
+func startStalePruning(ctx context.Context, ttl string) error {
+	staleTTL, err := time.ParseDuration(ttl)
+	if err != nil {
+		return fmt.Errorf("STALE_TTL: %w", err)
+	}
+	interval := time.Hour
+	if v := os.Getenv("STALE_CHECK_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("STALE_CHECK_INTERVAL: %w", err)
+		}
+	}
+	var remove bool
+	switch action := os.Getenv("STALE_ACTION"); action {
+	case "", "flag":
+	case "delete":
+		remove = true
+	default:
+		return fmt.Errorf("STALE_ACTION must be flag or delete, got %q", action)
+	}
+
+	go memgraph.RunStalePruning(routes.Driver, staleTTL, interval, remove, ctx.Done())
+	log.Printf("Stale pruning enabled (ttl %s, every %s, delete=%t)", staleTTL, interval, remove)
+	return nil
+}
+
+// startReachabilityProbe starts the background task that tries to connect to
+// every node's advertised addresses each interval until ctx is done. PROBE_TIMEOUT bounds each
+// attempt (default 10s), PROBE_CONCURRENCY the attempts in flight (default
+// 50), and TOR_SOCKS_PROXY, a SOCKS5 host:port, enables probing .onion
+// addresses.
+func startReachabilityProbe(ctx context.Context, interval string) error {
+	probeInterval, err := time.ParseDuration(interval)
+	if err != nil || probeInterval <= 0 {
+		return fmt.Errorf("PROBE_INTERVAL must be a positive duration, got %q", interval)
+	}
+	config := memgraph.ProbeConfig{Timeout: 10 * time.Second, Concurrency: 50, TorProxy: os.Getenv("TOR_SOCKS_PROXY")}
+	if v := os.Getenv("PROBE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("PROBE_TIMEOUT must be a positive duration, got %q", v)
+		}
+		config.Timeout = d
+	}
+	if v := os.Getenv("PROBE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("PROBE_CONCURRENCY must be a positive integer, got %q", v)
+		}
+		config.Concurrency = n
+	}
+	if config.TorProxy != "" {
+		if _, _, err := net.SplitHostPort(config.TorProxy); err != nil {
+			return fmt.Errorf("TOR_SOCKS_PROXY must be host:port, got %q", config.TorProxy)
+		}
+	}
+
+	go memgraph.RunReachabilityProbe(routes.Driver, probeInterval, config, ctx.Done())
+	log.Printf("Reachability probe enabled (every %s, timeout %s, tor=%t)", probeInterval, config.Timeout, config.TorProxy != "")
+	return nil
+}
+
+// startMetricRefresh starts the background task that keeps total_capacity
+// and betweenness current under live updates until ctx is done. METRICS_REFRESH_INTERVAL sets
+// how often affected nodes' capacities are recomputed ("off" disables the
+// task) and CENTRALITY_REFRESH_INTERVAL how often betweenness is recomputed
+// after channel changes (default 1h, 0 for never).
+func startMetricRefresh(ctx context.Context) error {
+	capacityInterval := 5 * time.Minute
+	if v := os.Getenv("METRICS_REFRESH_INTERVAL"); v == "off" {
+		log.Println("Metric refresh disabled")
+		return nil
+	} else if v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("METRICS_REFRESH_INTERVAL must be a positive duration or off, got %q", v)
+		}
+		capacityInterval = d
+	}
+	centralityInterval := time.Hour
+	if v := os.Getenv("CENTRALITY_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("CENTRALITY_REFRESH_INTERVAL must be a non-negative duration, got %q", v)
+		}
+		centralityInterval = d
+	}
+
+	go memgraph.RunMetricRefresh(routes.Driver, capacityInterval, centralityInterval, ctx.Done())
+	log.Printf("Metric refresh enabled (capacity every %s, centrality every %s)", capacityInterval, centralityInterval)
+	return nil
+}
+
+// startOwnChannelRefresh keeps the local node's channel balances and peers
+// current on the graph until ctx is done. OWN_CHANNELS_INTERVAL sets how
+// often they are re-read from LND (default 1m, "off" disables the task; imports still
+// overlay them).
+func startOwnChannelRefresh(ctx context.Context) error {
+	interval := time.Minute
+	if v := os.Getenv("OWN_CHANNELS_INTERVAL"); v == "off" {
+		return nil
+	} else if v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("OWN_CHANNELS_INTERVAL must be a positive duration or off, got %q", v)
+		}
+		interval = d
+	}
+	go memgraph.RunOwnChannelRefresh(routes.Driver, interval, ctx.Done())
+	return nil
+}
+
+// startForwardAggregation counts the HTLCs forwarded by the connected LND
+// node per channel until ctx is done. FORWARDS_INTERVAL sets how often the
+// totals are written to the graph (default 1m, "off" disables the task).
+func startForwardAggregation(ctx context.Context) error {
+	interval := time.Minute
+	if v := os.Getenv("FORWARDS_INTERVAL"); v == "off" {
+		return nil
+	} else if v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("FORWARDS_INTERVAL must be a positive duration or off, got %q", v)
+		}
+		interval = d
+	}
+	forwards := lnd.StreamForwards(ctx, routes.LndServices)
+	go memgraph.RunForwardAggregation(routes.Driver, interval, forwards, ctx.Done())
+	return nil
+}
+
+// parseRetention reads a retention policy from <prefix>_KEEP_LAST and
+// <prefix>_MAX_AGE. Unset variables leave the policy unlimited.
+func parseRetention(prefix string) (routes.RetentionPolicy, error) {
+	var policy routes.RetentionPolicy
+	if v := os.Getenv(prefix + "_KEEP_LAST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return policy, fmt.Errorf("%s_KEEP_LAST must be a positive integer, got %q", prefix, v)
+		}
+		policy.KeepLast = n
+	}
+	if v := os.Getenv(prefix + "_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("%s_MAX_AGE must be a positive duration, got %q", prefix, v)
+		}
+		policy.MaxAge = d
+	}
+	return policy, nil
+}
+
+// startJournalRetention starts hourly pruning of the update journal, until
+// ctx is done, if JOURNAL_KEEP_LAST or JOURNAL_MAX_AGE is set.
+func startJournalRetention(ctx context.Context) error {
+	policy, err := parseRetention("JOURNAL")
+	if err != nil {
+		return err
+	}
+	routes.JournalRetention = policy
+	if policy.KeepLast == 0 && policy.MaxAge == 0 {
+		return nil
+	}
+	go memgraph.RunJournalRetention(routes.Driver, policy.KeepLast, policy.MaxAge, time.Hour, ctx.Done())
+	log.Printf("Journal retention enabled (keep last %d, max age %s; 0 is unlimited)", policy.KeepLast, policy.MaxAge)
+	return nil
+}
+
+// startMockSource makes the built-in mock the graph source, emitting an
+// update every MOCK_UPDATE_INTERVAL (default 2s).
+func startMockSource() error {
+	interval := 2 * time.Second
+	if v := os.Getenv("MOCK_UPDATE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("MOCK_UPDATE_INTERVAL must be a positive duration, got %q", v)
+		}
+		interval = d
+	}
+	source, err := mock.NewSource(interval)
+	if err != nil {
+		return err
+	}
+	routes.Source = source
+	log.Printf("MOCK_LND set, serving the bundled sample graph with an update every %s", interval)
+	return nil
+}
+
+// startCapture writes every graph update received from LND or P2P peers to
+// NDJSON files in dir until ctx is done, whether or not it reaches Memgraph.
+// CAPTURE_ROTATE sets how often a new file is started (default 1h, 0 for
+// never), CAPTURE_MAX_MB the size at which one is started early (default 0,
+// no limit) and CAPTURE_COMPRESS=true gzips the files.
+func startCapture(ctx context.Context, dir string) error {
+	config := capture.Config{Dir: dir, RotateEvery: time.Hour}
+	if v := os.Getenv("CAPTURE_ROTATE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("CAPTURE_ROTATE must be a non-negative duration, got %q", v)
+		}
+		config.RotateEvery = d
+	}
+	if v := os.Getenv("CAPTURE_MAX_MB"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("CAPTURE_MAX_MB must be a non-negative integer, got %q", v)
+		}
+		config.MaxBytes = n << 20
+	}
+	if v := os.Getenv("CAPTURE_COMPRESS"); v != "" {
+		compress, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("CAPTURE_COMPRESS must be true or false, got %q", v)
+		}
+		config.Compress = compress
+	}
+	recorder, err := capture.New(config)
+	if err != nil {
+		return err
+	}
+	memgraph.OnUpdate = recorder.Record
+	go recorder.Run(ctx.Done())
+	log.Printf("Gossip capture enabled (%s, rotating every %s)", dir, config.RotateEvery)
+	return nil
+}
+
+// startSnapshotDumps starts the background task that archives a namespace's
+// graph every interval (DUMP_INTERVAL, e.g. 24h) until ctx is done. Dumps of DUMP_NAMESPACE, or
+// the default namespace, go to the S3 bucket if one is configured, otherwise
+// to DUMP_DIR, or the snapshot library directory if unset. DUMP_KEEP_LAST and
+// DUMP_MAX_AGE limit how many old dumps are kept.
+func startSnapshotDumps(ctx context.Context, interval string) error {
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("DUMP_INTERVAL must be a positive duration, got %q", interval)
+	}
+	dir := os.Getenv("DUMP_DIR")
+	if dir == "" {
+		dir = routes.SnapshotDir()
+	}
+	namespace := os.Getenv("DUMP_NAMESPACE")
+	if namespace == "" {
+		namespace = routes.DefaultNamespace()
+	}
+	retention, err := parseRetention("DUMP")
+	if err != nil {
+		return err
+	}
+
+	location := dir
+	if archived := routes.ArchiveLocation(); archived != "" {
+		location = archived
+	}
+
+	routes.Dumps = &routes.DumpConfig{Namespace: namespace, Dir: dir, Interval: d, Retention: retention}
+	go routes.RunSnapshotDumps(ctx.Done())
+	log.Printf("Graph dumps enabled (namespace %q to %s every %s)", namespace, location, d)
+	return nil
+}
+
 func main() {
 	var err error
 
 	// Load .env if present; ignored in Docker where env vars are set via compose.
 	_ = godotenv.Load(".env")
 
+	if err := applyProfile(); err != nil {
+		log.Fatalf("Invalid profile: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+	if err := routes.ValidateConfig(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// ctx is cancelled on SIGINT or SIGTERM, which cancels the requests and
+	// background work derived from it before the server shuts down.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	routes.BaseContext = ctx
+
+	if err := configureWrites(); err != nil {
+		log.Fatalf("Invalid write configuration: %v", err)
+	}
+	if err := routes.LoadAlgorithms(); err != nil {
+		log.Fatalf("Invalid algorithms configuration: %v", err)
+	}
+	if err := routes.ConfigureArchive(); err != nil {
+		log.Fatalf("Invalid S3 configuration: %v", err)
+	}
+	if err := routes.ConfigurePrice(); err != nil {
+		log.Fatalf("Invalid price feed configuration: %v", err)
+	}
+	if v := os.Getenv("READ_ONLY"); v != "" {
+		if routes.ReadOnly, err = strconv.ParseBool(v); err != nil {
+			log.Fatalf("READ_ONLY must be true or false, got %q", v)
+		}
+		if routes.ReadOnly {
+			log.Println("Read-only mode: endpoints that modify data are disabled")
+		}
+	}
+
 	// Connect to Memgraph (required).
 	routes.Driver, err = memgraph.ConnectNeo4j()
 	if err != nil {
 		log.Fatalf("Failed to connect to Neo4j: %v", err)
 	}
 	defer memgraph.CloseDriver(routes.Driver)
+	if err := startDatabaseHealthCheck(ctx); err != nil {
+		log.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+
+	// Graphs written before namespaces existed belong to the default namespace.
+	if err := memgraph.MigrateNamespace(ctx, routes.Driver, routes.DefaultNamespace()); err != nil {
+		log.Printf("Failed to migrate existing graph: %v", err)
+	}
+	// Remove duplicate edges left behind by older versions.
+	if removed, err := memgraph.DeduplicateEdges(ctx, routes.Driver); err != nil {
+		log.Printf("Failed to deduplicate edges: %v", err)
+	} else if removed > 0 {
+		log.Printf("Removed %d duplicate edges", removed)
+	}
+	if err := memgraph.BackfillChannelIDs(ctx, routes.Driver); err != nil {
+		log.Printf("Channel ID migration failed: %v", err)
+	}
 
-	// Connect to LND if configured. Without LND, only snapshot loading is available.
-	if os.Getenv("LND_ADDRESS") != "" {
+	// Connect to LND if configured, or serve the bundled sample graph with
+	// made-up updates in demo mode. Without either, only snapshot loading is
+	// available.
+	if demo, _ := strconv.ParseBool(os.Getenv("MOCK_LND")); demo {
+		if err := startMockSource(); err != nil {
+			log.Fatalf("Failed to start mock LND: %v", err)
+		}
+	} else if os.Getenv("LND_ADDRESS") != "" {
 		routes.LndServices, err = lnd.ConnectToLND()
 		if err != nil {
 			log.Printf("Failed to connect to LND: %v (snapshot-only mode)", err)
 		} else {
 			defer routes.LndServices.Close()
+			routes.Source = lnd.NewLNDSource(routes.LndServices)
+			network := os.Getenv("LND_NETWORK")
+			if network == "" {
+				network = "mainnet"
+			}
+			memgraph.ChainTip = lnd.ChainTip(routes.LndServices, network)
+			memgraph.OwnChannels = lnd.OwnChannels(routes.LndServices, network)
+			memgraph.Peers = lnd.Peers(routes.LndServices, network)
+			memgraph.ClosedChannels = lnd.ClosedChannels(routes.LndServices, network)
+			memgraph.PendingChannels = lnd.PendingChannels(routes.LndServices, network)
+			if err := startOwnChannelRefresh(ctx); err != nil {
+				log.Fatalf("Invalid own channel refresh configuration: %v", err)
+			}
+			if err := startForwardAggregation(ctx); err != nil {
+				log.Fatalf("Invalid forward aggregation configuration: %v", err)
+			}
 		}
 	} else {
 		log.Println("LND_ADDRESS not set, running in snapshot-only mode")
 	}
 
+	// Tag the nodes of known entities listed in ENTITIES_FILE.
+	if path := os.Getenv("ENTITIES_FILE"); path != "" {
+		n, err := memgraph.LoadEntities(path)
+		if err != nil {
+			log.Fatalf("Invalid entities file: %v", err)
+		}
+		log.Printf("Loaded %d known entities from %s", n, path)
+	}
+
+	// Expose the saved queries from QUERIES_FILE and those registered earlier.
+	if err := routes.LoadSavedQueries(); err != nil {
+		log.Fatalf("Invalid saved queries: %v", err)
+	}
+
+	// Record changes to watched nodes, and send them to WATCH_WEBHOOK_URL if set.
+	if err := memgraph.LoadWatches(routes.Driver); err != nil {
+		log.Printf("Failed to restore watches: %v", err)
+	}
+	if url := os.Getenv("WATCH_WEBHOOK_URL"); url != "" {
+		send := notify.Webhook(url)
+		memgraph.OnWatchEvent = func(event memgraph.WatchEvent) { send(event) }
+	}
+
+	// Keep derived metrics from rotting between imports while updates run.
+	if err := startMetricRefresh(ctx); err != nil {
+		log.Fatalf("Invalid metric refresh configuration: %v", err)
+	}
+
+	// Fill an empty database from BOOTSTRAP_SNAPSHOT, a path or URL, in the
+	// background so that the control panel comes up meanwhile.
+	if source := os.Getenv("BOOTSTRAP_SNAPSHOT"); source != "" {
+		go func() {
+			if err := routes.Bootstrap(ctx, source); err != nil {
+				log.Printf("Bootstrap failed: %v", err)
+			}
+		}()
+	}
+
+	// Archive every received gossip update to NDJSON files if configured.
+	// Installed before any update stream starts.
+	if dir := os.Getenv("CAPTURE_DIR"); dir != "" {
+		if err := startCapture(ctx, dir); err != nil {
+			log.Fatalf("Invalid gossip capture configuration: %v", err)
+		}
+	}
+
+	// Resume live updates if they were enabled before the last shutdown.
+	if err := routes.RestoreState(ctx); err != nil {
+		log.Printf("Failed to restore state: %v", err)
+	}
+
+	// Push live counters to control panel WebSocket clients.
+	go routes.RunLiveCounters(2*time.Second, ctx.Done())
+
+	// Keep gossip-derived liveness scores decaying for nodes that go silent.
+	go memgraph.RunLivenessRefresh(routes.Driver, time.Hour, ctx.Done())
+
+	// Flag or delete entities that have not been refreshed within STALE_TTL.
+	if ttl := os.Getenv("STALE_TTL"); ttl != "" {
+		if err := startStalePruning(ctx, ttl); err != nil {
+			log.Fatalf("Invalid stale pruning configuration: %v", err)
+		}
+	}
+
+	// Periodically check whether nodes' advertised addresses accept
+	// connections if configured.
+	if interval := os.Getenv("PROBE_INTERVAL"); interval != "" {
+		if err := startReachabilityProbe(ctx, interval); err != nil {
+			log.Fatalf("Invalid reachability probe configuration: %v", err)
+		}
+	}
+
+	// Bound the update journal if configured.
+	if err := startJournalRetention(ctx); err != nil {
+		log.Fatalf("Invalid journal retention configuration: %v", err)
+	}
+
+	// Archive the graph to timestamped snapshot files if configured.
+	if interval := os.Getenv("DUMP_INTERVAL"); interval != "" {
+		if err := startSnapshotDumps(ctx, interval); err != nil {
+			log.Fatalf("Invalid graph dump configuration: %v", err)
+		}
+	}
+
+	// Sync gossip directly from Lightning peers if configured. This works with
+	// or without LND.
+	if peerList := os.Getenv("P2P_PEERS"); peerList != "" {
+		if err := startP2PSync(ctx, peerList); err != nil {
+			log.Fatalf("Failed to start P2P gossip sync: %v", err)
+		}
+	}
+
 	// Set up HTTP routes and static file serving.
-	router := gin.Default()
+	// gin.New is used instead of gin.Default so that our structured access log
+	// replaces gin's own logger.
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.RequestID(), middleware.AccessLog(), routes.Audit())
 	router.GET("/reset-graph", routes.ResetGraphHandler)
 	router.GET("/load-local-snapshot", routes.LoadLocalSnapshot)
+	router.GET("/load-cln-snapshot", routes.LoadCLNSnapshot)
+	router.GET("/load-gossip-store", routes.LoadGossipStore)
+	router.GET("/api/snapshots", routes.ListSnapshotsHandler)
+	router.POST("/api/generate", routes.GenerateGraphHandler)
+	router.GET("/api/snapshots/status", routes.ArchiveStatusHandler)
+	router.POST("/api/snapshots/:name/load", routes.LoadSnapshotHandler)
+	router.POST("/api/snapshots/dump", routes.DumpSnapshotHandler)
 	router.GET("/toggle-updates", routes.ToggleUpdatesHandler)
 	router.GET("/get-status", routes.GetStatusHandler)
+	router.GET("/api/audit", routes.AuditHandler)
+	router.GET("/api/stats/summary", routes.NetworkSummaryHandler)
+	router.GET("/api/stats/fees", routes.FeeHistogramHandler)
+	router.GET("/api/stats/degrees", routes.DegreeDistributionHandler)
+	router.GET("/api/stats/connectivity", routes.ConnectivityStatsHandler)
+	router.GET("/api/stats/critical", routes.CriticalElementsHandler)
+	router.GET("/api/embeddings/node2vec", routes.Node2VecHandler)
+	router.GET("/api/algorithms", routes.ListAlgorithmsHandler)
+	router.POST("/api/algorithms/:name", routes.RunAlgorithmHandler)
+	router.POST("/api/backup", routes.BackupHandler)
+	router.GET("/api/backups", routes.ListBackupsHandler)
+	router.GET("/api/backups/:name", routes.DownloadBackupHandler)
+	router.POST("/api/restore", routes.RestoreHandler)
+	router.GET("/api/datasets", routes.ListDatasetsHandler)
+	router.GET("/api/config", routes.ConfigHandler)
+	router.PUT("/api/datasets/active", routes.SetActiveDatasetHandler)
+	router.GET("/api/queries", routes.ListQueriesHandler)
+	router.POST("/api/queries", routes.RegisterQueryHandler)
+	router.GET("/api/queries/:name", routes.RunQueryHandler)
+	router.DELETE("/api/queries/:name", routes.DeleteQueryHandler)
+	router.GET("/api/nodes", routes.ListNodesHandler)
+	router.GET("/api/edges", routes.ListEdgesHandler)
+	router.GET("/export/delta", routes.ExportDeltaHandler)
+	router.GET("/api/nodes/:pubkey", routes.GetNodeHandler)
+	router.POST("/api/entities/reload", routes.ReloadEntitiesHandler)
+	router.GET("/api/nodes/:pubkey/changes", routes.NodeChangesHandler)
+	router.GET("/api/check", routes.ConsistencyCheckHandler)
+	router.POST("/api/simulate-payment", routes.SimulatePaymentHandler)
+	router.POST("/api/whatif", routes.WhatIfHandler)
+	router.GET("/api/maxflow", routes.MaxFlowHandler)
+	router.GET("/api/recommendations/peers", routes.RecommendPeersHandler)
+	router.GET("/api/my/fee-suggestions", routes.FeeSuggestionsHandler)
+	router.POST("/api/my/rebalance-routes", routes.RebalanceRoutesHandler)
+	router.GET("/metrics", routes.MetricsHandler)
+	router.GET("/ws/live", routes.LiveHandler)
+	router.POST("/api/watch/nodes", routes.WatchNodeHandler)
+	router.GET("/api/watch/nodes", routes.ListNodeWatchesHandler)
+	router.DELETE("/api/watch/nodes/:pubkey", routes.UnwatchNodeHandler)
+	router.POST("/api/watch/channels", routes.WatchChannelHandler)
+	router.GET("/api/watch/channels", routes.ListChannelWatchesHandler)
+	router.DELETE("/api/watch/channels/:channel_id", routes.UnwatchChannelHandler)
+	router.GET("/api/watch/events", routes.WatchEventsHandler)
 	router.StaticFile("/static/script.js", "./static/script.js")
 	router.StaticFile("/static/style.css", "./static/style.css")
 	router.StaticFile("/", "./index.html")
 
+	server := &http.Server{Addr: ":8080", Handler: router, BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
 	fmt.Println("Server started at http://localhost:8080")
-	router.Run(":8080")
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not complete: %v", err)
+	}
 }
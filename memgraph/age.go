@@ -0,0 +1,66 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// blockInterval is the average time between blocks, used to estimate when a
+// channel was opened from its age in blocks.
+const blockInterval = 10 * time.Minute
+
+// ChainTip, if set, returns the current block height of the given network,
+// e.g. from a connected LND node, or an error if it cannot tell. Without it,
+// or when it fails, the highest funding height in the namespace stands in
+// for the tip, which ages channels relative to the newest one.
+var ChainTip func(ctx context.Context, network string) (int64, error)
+
+// chainTip returns the best known block height for a namespace's network.
+func chainTip(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	highest, err := countQuery(driver, `
+		MATCH (:node {namespace: $namespace})-[r:edge]->()
+		RETURN coalesce(max(r.block_height), 0) AS count
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the highest funding height: %w", err)
+	}
+	if ChainTip == nil {
+		return highest, nil
+	}
+	network, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return 0, err
+	}
+	tip, err := ChainTip(ctx, network)
+	if err != nil {
+		log.Printf("Using the highest funding height as chain tip for namespace %q: %v", namespace, err)
+		return highest, nil
+	}
+	return max(tip, highest), nil
+}
+
+// SetChannelAges stores age_blocks, the number of blocks since the funding
+// transaction confirmed, and opened_at_estimate, the unix time that many
+// average block intervals ago, on every edge of a namespace whose short
+// channel ID encodes a funding height. Alias SCIDs carry no age.
+func SetChannelAges(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	tip, err := chainTip(ctx, driver, namespace)
+	if err != nil {
+		return err
+	}
+	_, err = CommitQuery(ctx, driver, `
+		MATCH (:node {namespace: $namespace})-[r:edge]->()
+		WHERE r.block_height IS NOT NULL
+		SET r.age_blocks = $tip - r.block_height,
+			r.opened_at_estimate = $now - ($tip - r.block_height) * $interval
+	`, map[string]interface{}{"namespace": namespace, "tip": tip, "now": time.Now().Unix(),
+		"interval": int64(blockInterval / time.Second)})
+	if err != nil {
+		return fmt.Errorf("failed to set channel ages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package memgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// AlgorithmParam is a parameter of a configured procedure. Parameters are
+// passed positionally, in configuration order, after the projected subgraph.
+type AlgorithmParam struct {
+	Name    string      `json:"name"`
+	Default interface{} `json:"default"`
+}
+
+// Algorithm is a whitelisted MAGE procedure. Results maps the procedure's
+// yielded columns to the node properties they are written to; the procedure
+// must yield the node itself as "node".
+type Algorithm struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Procedure   string            `json:"procedure"`
+	Parameters  []AlgorithmParam  `json:"parameters"`
+	Results     map[string]string `json:"results"`
+}
+
+// ErrInvalidParameter is returned when an algorithm is called with a
+// parameter it does not accept.
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// identifier matches the procedure, column and property names allowed in
+// the configuration, since they are spliced into queries.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// LoadAlgorithms reads the algorithm whitelist from a JSON file mapping
+// names to algorithms, and validates every name it will splice into queries.
+func LoadAlgorithms(path string) (map[string]Algorithm, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read algorithms file: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var algorithms map[string]Algorithm
+	if err := decoder.Decode(&algorithms); err != nil {
+		return nil, fmt.Errorf("failed to parse algorithms file: %w", err)
+	}
+
+	for name, algorithm := range algorithms {
+		if !identifier.MatchString(algorithm.Procedure) || !strings.Contains(algorithm.Procedure, ".") {
+			return nil, fmt.Errorf("algorithm %s: invalid procedure %q", name, algorithm.Procedure)
+		}
+		if len(algorithm.Results) == 0 {
+			return nil, fmt.Errorf("algorithm %s: no results configured", name)
+		}
+		for column, property := range algorithm.Results {
+			if !identifier.MatchString(column) || strings.Contains(column, ".") || column == "node" ||
+				!identifier.MatchString(property) || strings.Contains(property, ".") {
+				return nil, fmt.Errorf("algorithm %s: invalid result mapping %q -> %q", name, column, property)
+			}
+		}
+		for i, param := range algorithm.Parameters {
+			if param.Name == "" {
+				return nil, fmt.Errorf("algorithm %s: parameter %d has no name", name, i)
+			}
+			algorithm.Parameters[i].Default = normalizeNumber(param.Default)
+		}
+		algorithm.Name = name
+		algorithms[name] = algorithm
+	}
+	return algorithms, nil
+}
+
+// normalizeNumber converts a json.Number to int64 if it is integral and to
+// float64 otherwise, since Memgraph does not coerce floats to integers.
+func normalizeNumber(value interface{}) interface{} {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// RunAlgorithm invokes a configured procedure on a namespace's channel graph
+// and writes its results to node properties. values overrides parameter
+// defaults; unknown parameters are rejected. Returns the number of nodes
+// updated.
+func RunAlgorithm(driver neo4j.Driver, namespace string, algorithm Algorithm, values map[string]interface{}) (int64, error) {
+	known := map[string]bool{}
+	params := map[string]interface{}{"namespace": namespace}
+	var args []string
+	for i, param := range algorithm.Parameters {
+		known[param.Name] = true
+		key := fmt.Sprintf("p%d", i)
+		params[key] = param.Default
+		if value, ok := values[param.Name]; ok {
+			params[key] = normalizeNumber(value)
+		}
+		args = append(args, "$"+key)
+	}
+	for name := range values {
+		if !known[name] {
+			return 0, fmt.Errorf("%w: unknown parameter %q", ErrInvalidParameter, name)
+		}
+	}
+
+	columns := make([]string, 0, len(algorithm.Results))
+	for column := range algorithm.Results {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	var sets []string
+	for _, column := range columns {
+		sets = append(sets, fmt.Sprintf("node.%s = %s", algorithm.Results[column], column))
+	}
+
+	query := fmt.Sprintf(`
+		MATCH p=(n:node {namespace: $namespace})-[r:edge]->(m:node)
+		WHERE %s
+		WITH project(p) AS subgraph
+		CALL %s(%s)
+		YIELD node, %s
+		SET %s
+		RETURN count(node) AS updated
+	`, openChannel("r"), algorithm.Procedure, strings.Join(append([]string{"subgraph"}, args...), ", "),
+		strings.Join(columns, ", "), strings.Join(sets, ", "))
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	result, err := session.Run(query, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s: %w", algorithm.Procedure, err)
+	}
+	record, err := result.Single()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s: %w", algorithm.Procedure, err)
+	}
+	updated, _ := record.Get("updated")
+	count, _ := updated.(int64)
+	return count, nil
+}
@@ -0,0 +1,395 @@
+package memgraph
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// backupFormat and backupVersion identify backup files in their header line.
+const (
+	backupFormat  = "ln-stream-backup"
+	backupVersion = 1
+)
+
+// restoreBatchSize is the number of nodes or relationships created per query
+// during a restore.
+const restoreBatchSize = 1000
+
+// ErrInvalidBackup is returned when a restore is given a file that is not a
+// readable backup.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// ErrPartialRestore wraps errors that stop a restore after the database was
+// cleared, leaving it partially restored.
+var ErrPartialRestore = errors.New("database partially restored")
+
+// BackupStats counts the contents of a backup.
+type BackupStats struct {
+	CreatedAt     time.Time `json:"created_at"`
+	Nodes         int64     `json:"nodes"`
+	Relationships int64     `json:"relationships"`
+}
+
+// backupHeader is the first line of a backup.
+type backupHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupEntry is one node or relationship of a backup. Nodes have ID and
+// Labels, relationships Start, End and RelType, all referring to node IDs
+// of the backed up database.
+type backupEntry struct {
+	Kind       string                 `json:"kind"`
+	ID         int64                  `json:"id,omitempty"`
+	Labels     []string               `json:"labels,omitempty"`
+	Start      int64                  `json:"start,omitempty"`
+	End        int64                  `json:"end,omitempty"`
+	RelType    string                 `json:"type,omitempty"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// encodeValue prepares a property value for JSON. Floats are wrapped as
+// {"$float": v} since JSON would not tell 1.0 from 1 and Memgraph does not
+// coerce between them.
+func encodeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int64, string:
+		return v, nil
+	case float64:
+		return map[string]interface{}{"$float": v}, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded, err := encodeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = encoded
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			encoded, err := encodeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = encoded
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported property type %T", value)
+	}
+}
+
+// decodeValue reverses encodeValue on a value decoded with UseNumber.
+func decodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, item := range v {
+			v[i] = decodeValue(item)
+		}
+		return v
+	case map[string]interface{}:
+		if f, ok := v["$float"].(json.Number); ok && len(v) == 1 {
+			float, _ := f.Float64()
+			return float
+		}
+		for key, item := range v {
+			v[key] = decodeValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// encodeProperties applies encodeValue to every property.
+func encodeProperties(props map[string]interface{}) (map[string]interface{}, error) {
+	encoded, err := encodeValue(props)
+	if err != nil {
+		return nil, err
+	}
+	return encoded.(map[string]interface{}), nil
+}
+
+// WriteBackup writes the complete database contents, every node and
+// relationship with all their properties including derived ones, to w as
+// gzipped JSON lines: a header, then all nodes, then all relationships.
+func WriteBackup(ctx context.Context, driver neo4j.Driver, w io.Writer) (BackupStats, error) {
+	stats := BackupStats{CreatedAt: time.Now().UTC()}
+	zw := gzip.NewWriter(w)
+	encoder := json.NewEncoder(zw)
+	if err := encoder.Encode(backupHeader{Format: backupFormat, Version: backupVersion, CreatedAt: stats.CreatedAt}); err != nil {
+		return stats, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+	for _, query := range []string{"MATCH (n) RETURN n AS item", "MATCH ()-[r]->() RETURN r AS item"} {
+		result, err := session.Run(query, nil)
+		if err != nil {
+			return stats, fmt.Errorf("failed to read database: %w", err)
+		}
+		for result.Next() {
+			if err := ctx.Err(); err != nil {
+				return stats, err
+			}
+			item, _ := result.Record().Get("item")
+			var entry backupEntry
+			switch v := item.(type) {
+			case neo4j.Node:
+				entry = backupEntry{Kind: "node", ID: v.Id, Labels: v.Labels}
+				entry.Properties, err = encodeProperties(v.Props)
+				stats.Nodes++
+			case neo4j.Relationship:
+				entry = backupEntry{Kind: "relationship", Start: v.StartId, End: v.EndId, RelType: v.Type}
+				entry.Properties, err = encodeProperties(v.Props)
+				stats.Relationships++
+			default:
+				return stats, fmt.Errorf("unexpected backup item %T", item)
+			}
+			if err != nil {
+				return stats, err
+			}
+			if err := encoder.Encode(entry); err != nil {
+				return stats, fmt.Errorf("failed to write backup: %w", err)
+			}
+		}
+		if err := result.Err(); err != nil {
+			return stats, fmt.Errorf("failed to read database: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return stats, fmt.Errorf("failed to write backup: %w", err)
+	}
+	return stats, nil
+}
+
+// labelPattern returns the Cypher label expression for labels, e.g. ":a:b",
+// validating each label since they are spliced into queries.
+func labelPattern(labels []string) (string, error) {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+	var pattern strings.Builder
+	for _, label := range sorted {
+		if !identifier.MatchString(label) || strings.Contains(label, ".") {
+			return "", fmt.Errorf("%w: invalid label %q", ErrInvalidBackup, label)
+		}
+		pattern.WriteString(":" + label)
+	}
+	return pattern.String(), nil
+}
+
+// restorer creates the nodes and relationships of a backup in batches,
+// mapping the backed up node IDs to those of the new nodes.
+type restorer struct {
+	ctx     context.Context
+	session neo4j.Session
+	ids     map[int64]int64
+	nodes   map[string][]map[string]interface{}
+	rels    map[string][]map[string]interface{}
+	stats   BackupStats
+}
+
+// addNode queues a node, creating its batch once full.
+func (r *restorer) addNode(entry backupEntry) error {
+	pattern, err := labelPattern(entry.Labels)
+	if err != nil {
+		return err
+	}
+	r.nodes[pattern] = append(r.nodes[pattern], map[string]interface{}{"id": entry.ID, "props": entry.Properties})
+	if len(r.nodes[pattern]) >= restoreBatchSize {
+		return r.flushNodes(pattern)
+	}
+	return nil
+}
+
+// flushNodes creates the queued nodes with the given labels.
+func (r *restorer) flushNodes(pattern string) error {
+	rows := r.nodes[pattern]
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	result, err := r.session.Run(fmt.Sprintf(`
+		UNWIND $rows AS row
+		CREATE (n%s)
+		SET n = row.props
+		RETURN row.id AS old, id(n) AS new
+	`, pattern), map[string]interface{}{"rows": rows})
+	if err != nil {
+		return fmt.Errorf("failed to restore nodes: %w", err)
+	}
+	for result.Next() {
+		values := recordMap(result.Record())
+		old, _ := values["old"].(int64)
+		r.ids[old], _ = values["new"].(int64)
+	}
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("failed to restore nodes: %w", err)
+	}
+	r.stats.Nodes += int64(len(rows))
+	r.nodes[pattern] = nil
+	return nil
+}
+
+// addRelationship queues a relationship, creating its batch once full. All
+// nodes must have been created.
+func (r *restorer) addRelationship(entry backupEntry) error {
+	if !identifier.MatchString(entry.RelType) || strings.Contains(entry.RelType, ".") {
+		return fmt.Errorf("%w: invalid relationship type %q", ErrInvalidBackup, entry.RelType)
+	}
+	start, ok := r.ids[entry.Start]
+	end, ok2 := r.ids[entry.End]
+	if !ok || !ok2 {
+		return fmt.Errorf("%w: relationship between unknown nodes %d and %d", ErrInvalidBackup, entry.Start, entry.End)
+	}
+	r.rels[entry.RelType] = append(r.rels[entry.RelType],
+		map[string]interface{}{"start": start, "end": end, "props": entry.Properties})
+	if len(r.rels[entry.RelType]) >= restoreBatchSize {
+		return r.flushRelationships(entry.RelType)
+	}
+	return nil
+}
+
+// flushRelationships creates the queued relationships of a type.
+func (r *restorer) flushRelationships(relType string) error {
+	rows := r.rels[relType]
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	result, err := r.session.Run(fmt.Sprintf(`
+		UNWIND $rows AS row
+		MATCH (a) WHERE id(a) = row.start
+		MATCH (b) WHERE id(b) = row.end
+		CREATE (a)-[r:%s]->(b)
+		SET r = row.props
+	`, relType), map[string]interface{}{"rows": rows})
+	if err == nil {
+		_, err = result.Consume()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore relationships: %w", err)
+	}
+	r.stats.Relationships += int64(len(rows))
+	r.rels[relType] = nil
+	return nil
+}
+
+// RestoreBackup replaces the complete database contents with a backup
+// written by WriteBackup. Only the header is checked before everything is
+// deleted, so errors after that, e.g. from a corrupt backup, are wrapped in
+// ErrPartialRestore. Indexes are left as they are.
+func RestoreBackup(ctx context.Context, driver neo4j.Driver, r io.Reader) (BackupStats, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return BackupStats{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer zr.Close()
+	decoder := json.NewDecoder(bufio.NewReader(zr))
+	decoder.UseNumber()
+	var header backupHeader
+	if err := decoder.Decode(&header); err != nil || header.Format != backupFormat {
+		return BackupStats{}, fmt.Errorf("%w: missing backup header", ErrInvalidBackup)
+	}
+	if header.Version != backupVersion {
+		return BackupStats{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, header.Version)
+	}
+
+	log.Printf("Restoring backup from %s, deleting all data...", header.CreatedAt.Format(time.RFC3339))
+	if _, err := CommitQuery(ctx, driver, "MATCH (n) DETACH DELETE n", nil); err != nil {
+		return BackupStats{}, fmt.Errorf("failed to clear database: %w", err)
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	restore := &restorer{
+		ctx:     ctx,
+		session: session,
+		ids:     map[int64]int64{},
+		nodes:   map[string][]map[string]interface{}{},
+		rels:    map[string][]map[string]interface{}{},
+		stats:   BackupStats{CreatedAt: header.CreatedAt},
+	}
+	if err := restore.run(decoder); err != nil {
+		return restore.stats, fmt.Errorf("%w: %w", ErrPartialRestore, err)
+	}
+	return restore.stats, nil
+}
+
+// run creates the entries following a backup's header.
+func (r *restorer) run(decoder *json.Decoder) error {
+	nodesDone := false
+	for {
+		var entry backupEntry
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		entry.Properties, _ = decodeValue(entry.Properties).(map[string]interface{})
+		if entry.Properties == nil {
+			entry.Properties = map[string]interface{}{}
+		}
+		switch entry.Kind {
+		case "node":
+			if nodesDone {
+				return fmt.Errorf("%w: node after relationships", ErrInvalidBackup)
+			}
+			err = r.addNode(entry)
+		case "relationship":
+			if !nodesDone {
+				for pattern := range r.nodes {
+					if err := r.flushNodes(pattern); err != nil {
+						return err
+					}
+				}
+				nodesDone = true
+			}
+			err = r.addRelationship(entry)
+		default:
+			err = fmt.Errorf("%w: unknown entry kind %q", ErrInvalidBackup, entry.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for pattern := range r.nodes {
+		if err := r.flushNodes(pattern); err != nil {
+			return err
+		}
+	}
+	for relType := range r.rels {
+		if err := r.flushRelationships(relType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
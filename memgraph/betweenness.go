@@ -0,0 +1,171 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// BetweennessSamples selects how SetupAfterImport computes node betweenness.
+// Zero runs MAGE's exact betweenness_centrality; a positive value estimates
+// it from shortest paths out of that many randomly sampled source nodes.
+var BetweennessSamples = 0
+
+// exactBetweennessQuery runs MAGE's betweenness_centrality on a namespace's
+// subgraph only.
+var exactBetweennessQuery = "MATCH p=(n:node {namespace: $namespace})-[r:edge]->(m:node)\nWHERE " + openChannel("r") + "\nwith project(p) as subgraph\n" +
+	"call betweenness_centrality.get(subgraph) YIELD betweenness_centrality, node \nwith betweenness_centrality,node\nset node.betweenness_centrality = betweenness_centrality;"
+
+// edgeBetweennessQuery averages the betweenness of each edge's endpoints onto
+// the edge.
+const edgeBetweennessQuery = "MATCH (n {namespace: $namespace})-[r]-(m)\nset r.betweenness_centrality = (n.betweenness_centrality+m.betweenness_centrality)/2;"
+
+// setBetweenness stores node betweenness_centrality for a namespace, exactly
+// or sampled depending on BetweennessSamples.
+func setBetweenness(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	// A fresh regtest network may have no channels yet, leaving nothing to
+	// project.
+	channels, err := countQuery(driver, "MATCH (:node {namespace: $namespace})-[r:edge]->() WHERE "+openChannel("r")+" RETURN count(r) AS count",
+		map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return err
+	}
+	if channels == 0 {
+		return nil
+	}
+	if BetweennessSamples > 0 {
+		log.Printf("Estimating betweenness from %d sampled sources...", BetweennessSamples)
+		return setSampledBetweenness(ctx, driver, namespace, BetweennessSamples)
+	}
+	_, err = CommitQuery(ctx, driver, exactBetweennessQuery, map[string]interface{}{"namespace": namespace})
+	return err
+}
+
+// sampledBetweenness estimates directed, normalized betweenness centrality
+// with Brandes' algorithm run from samples random sources, scaling the
+// accumulated dependencies by n/samples. Like MAGE's procedure it ignores
+// weights and policies. With samples >= n the result is exact.
+func sampledBetweenness(g *channelGraph, samples int) map[string]float64 {
+	return betweennessFrom(g, g.sampleNodes(samples))
+}
+
+// sampleNodes returns up to samples random pubkeys of the graph's nodes.
+func (g *channelGraph) sampleNodes(samples int) []string {
+	pubKeys := g.nodes()
+	rand.Shuffle(len(pubKeys), func(i, j int) { pubKeys[i], pubKeys[j] = pubKeys[j], pubKeys[i] })
+	if samples < len(pubKeys) {
+		pubKeys = pubKeys[:samples]
+	}
+	return pubKeys
+}
+
+// nodes returns the pubkeys of every node with channels in the graph.
+func (g *channelGraph) nodes() []string {
+	var pubKeys []string
+	for pubKey := range g.outgoing {
+		pubKeys = append(pubKeys, pubKey)
+	}
+	for pubKey := range g.incoming {
+		if len(g.outgoing[pubKey]) == 0 {
+			pubKeys = append(pubKeys, pubKey)
+		}
+	}
+	return pubKeys
+}
+
+// betweennessFrom accumulates Brandes' dependencies from the given source
+// nodes, skipping sources not in the graph, and scales them like
+// sampledBetweenness.
+func betweennessFrom(g *channelGraph, sourceKeys []string) map[string]float64 {
+	pubKeys := g.nodes()
+	index := make(map[string]int, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		index[pubKey] = i
+	}
+	n := len(pubKeys)
+	adj := make([][]int, n)
+	for from, edges := range g.outgoing {
+		for _, e := range edges {
+			adj[index[from]] = append(adj[index[from]], index[e.To])
+		}
+	}
+
+	var sources []int
+	for _, pubKey := range sourceKeys {
+		if i, ok := index[pubKey]; ok {
+			sources = append(sources, i)
+		}
+	}
+	if len(sources) == 0 {
+		return map[string]float64{}
+	}
+	centrality := make([]float64, n)
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	preds := make([][]int, n)
+	for _, s := range sources {
+		for i := range dist {
+			dist[i], sigma[i], delta[i] = -1, 0, 0
+			preds[i] = preds[i][:0]
+		}
+		dist[s], sigma[s] = 0, 1
+		order := []int{s}
+		for i := 0; i < len(order); i++ {
+			v := order[i]
+			for _, w := range adj[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					order = append(order, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+		for i := len(order) - 1; i > 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			centrality[w] += delta[w]
+		}
+	}
+
+	scale := float64(n) / float64(len(sources))
+	if n > 2 {
+		scale /= float64((n - 1) * (n - 2))
+	}
+	result := make(map[string]float64, n)
+	for i, pubKey := range pubKeys {
+		result[pubKey] = centrality[i] * scale
+	}
+	return result
+}
+
+// setSampledBetweenness estimates node betweenness for a namespace and stores
+// it as betweenness_centrality.
+func setSampledBetweenness(ctx context.Context, driver neo4j.Driver, namespace string, samples int) error {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return err
+	}
+	centrality := sampledBetweenness(g, samples)
+	rows := make([]map[string]interface{}, 0, len(centrality))
+	for pubKey, value := range centrality {
+		rows = append(rows, map[string]interface{}{"pubkey": pubKey, "value": value})
+	}
+	_, err = CommitQuery(ctx, driver, `
+		UNWIND $rows AS row
+		MATCH (n:node {pubkey: row.pubkey, namespace: $namespace})
+		SET n.betweenness_centrality = row.value
+	`, map[string]interface{}{"rows": rows, "namespace": namespace})
+	if err != nil {
+		return fmt.Errorf("failed to store sampled betweenness: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package memgraph
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// maxCapacity is the largest plausible channel capacity in satoshis: the
+// total bitcoin supply.
+const maxCapacity = 21_000_000 * 100_000_000
+
+// maxExamples limits how many offending channel IDs a check reports.
+const maxExamples = 10
+
+// CheckResult is the outcome of a single consistency check. Fixed is only
+// set when the check has an automatic fix and fixing was requested.
+type CheckResult struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int64    `json:"count"`
+	Examples    []string `json:"examples"`
+	Fixable     bool     `json:"fixable"`
+	Fixed       int64    `json:"fixed"`
+}
+
+// ConsistencyReport lists the results of all consistency checks run against
+// a namespace.
+type ConsistencyReport struct {
+	Namespace string        `json:"namespace"`
+	Checks    []CheckResult `json:"checks"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// consistencyCheck pairs a detection query, returning count and examples
+// columns, with an optional fix query returning a count column.
+type consistencyCheck struct {
+	name        string
+	description string
+	detect      string
+	fix         string
+}
+
+var consistencyChecks = []consistencyCheck{
+	{
+		name:        "dangling_edges",
+		description: "edges whose endpoints have no pubkey or belong to another namespace",
+		detect: `
+			MATCH (a)-[r:edge {namespace: $namespace}]->(b)
+			WHERE a.pubkey IS NULL OR b.pubkey IS NULL OR a.namespace <> $namespace OR b.namespace <> $namespace
+			RETURN count(r) AS count, collect(r.channel_id)[..$maxExamples] AS examples
+		`,
+		fix: `
+			MATCH (a)-[r:edge {namespace: $namespace}]->(b)
+			WHERE a.pubkey IS NULL OR b.pubkey IS NULL OR a.namespace <> $namespace OR b.namespace <> $namespace
+			DELETE r
+			RETURN count(*) AS count
+		`,
+	},
+	{
+		name:        "duplicate_channels",
+		description: "channel directions stored as more than one edge",
+		detect: `
+			MATCH (a)-[r:edge {namespace: $namespace}]->()
+			WITH a, r.channel_id AS channel_id, count(r) AS edges
+			WHERE edges > 1
+			RETURN count(*) AS count, collect(channel_id)[..$maxExamples] AS examples
+		`,
+		// Keep the most recently updated edge of each direction.
+		fix: `
+			MATCH (a)-[r:edge {namespace: $namespace}]->()
+			WITH a, r ORDER BY coalesce(r.last_update, 0) DESC
+			WITH a, r.channel_id AS channel_id, collect(r) AS edges
+			WHERE size(edges) > 1
+			UNWIND edges[1..] AS duplicate
+			DELETE duplicate
+			RETURN count(*) AS count
+		`,
+	},
+	{
+		name:        "one_direction_channels",
+		description: "channels with a policy in only one direction",
+		detect: `
+			MATCH (a)-[r:edge {namespace: $namespace}]->()
+			WITH r.channel_id AS channel_id, count(DISTINCT a) AS directions
+			WHERE directions = 1
+			RETURN count(*) AS count, collect(channel_id)[..$maxExamples] AS examples
+		`,
+	},
+	{
+		name:        "invalid_capacities",
+		description: "edges with a missing, non-positive or larger than 21M BTC capacity",
+		detect: `
+			MATCH ()-[r:edge {namespace: $namespace}]->()
+			WITH r, toInteger(r.capacity) AS capacity
+			WHERE capacity IS NULL OR capacity <= 0 OR capacity > $maxCapacity
+			RETURN count(r) AS count, collect(DISTINCT r.channel_id)[..$maxExamples] AS examples
+		`,
+	},
+}
+
+// CheckConsistency scans a namespace for integrity problems. If fix is set,
+// checks that have an automatic fix apply it after detection, so Count
+// reflects the state before fixing.
+func CheckConsistency(driver neo4j.Driver, namespace string, fix bool) (*ConsistencyReport, error) {
+	params := map[string]interface{}{
+		"namespace":   namespace,
+		"maxExamples": maxExamples,
+		"maxCapacity": maxCapacity,
+	}
+	report := &ConsistencyReport{Namespace: namespace, Checks: []CheckResult{}}
+
+	for _, check := range consistencyChecks {
+		records, err := collectRecords(driver, check.detect, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", check.name, err)
+		}
+		result := CheckResult{
+			Name:        check.name,
+			Description: check.description,
+			Examples:    []string{},
+			Fixable:     check.fix != "",
+		}
+		if len(records) > 0 {
+			count, _ := records[0].Get("count")
+			result.Count, _ = count.(int64)
+			examples, _ := records[0].Get("examples")
+			list, _ := examples.([]interface{})
+			for _, example := range list {
+				if s, ok := example.(string); ok {
+					result.Examples = append(result.Examples, s)
+				}
+			}
+		}
+
+		if fix && result.Fixable && result.Count > 0 {
+			result.Fixed, err = countQuery(driver, check.fix, params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fix %s: %w", check.name, err)
+			}
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	report.CheckedAt = time.Now()
+	return report, nil
+}
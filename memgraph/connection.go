@@ -0,0 +1,219 @@
+package memgraph
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// reconnectInterval is the minimum time between two driver recreations, so
+// that queries failing together after a restart replace the driver once.
+const reconnectInterval = time.Second
+
+// databaseDown is whether the last connectivity check failed.
+var databaseDown atomic.Bool
+
+// DatabaseUp reports whether Memgraph was reachable at the last check.
+func DatabaseUp() bool {
+	return !databaseDown.Load()
+}
+
+// resilientDriver is the driver returned by ConnectNeo4j. It delegates to an
+// underlying driver that is recreated when Memgraph becomes unreachable, so
+// the references held by handlers and background tasks keep working once
+// the database is back.
+type resilientDriver struct {
+	mu            sync.RWMutex
+	current       *trackedDriver
+	connect       func() (neo4j.Driver, error)
+	reconnectedAt time.Time
+}
+
+// trackedDriver is an underlying driver and the sessions open on it, so
+// that a replaced driver is closed only once they are done.
+type trackedDriver struct {
+	neo4j.Driver
+	sessions sync.WaitGroup
+}
+
+// trackedSession is a session that marks itself done on its driver when
+// closed.
+type trackedSession struct {
+	neo4j.Session
+	done sync.Once
+	wg   *sync.WaitGroup
+}
+
+// Close closes the session and releases it from its driver.
+func (s *trackedSession) Close() error {
+	err := s.Session.Close()
+	s.done.Do(s.wg.Done)
+	return err
+}
+
+// driver returns the current underlying driver, which the neo4j.Driver
+// methods below delegate to.
+func (d *resilientDriver) driver() neo4j.Driver {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current
+}
+
+// open registers a session on the current driver and returns the driver.
+// The session must be released with Done on the returned WaitGroup, which
+// trackedSession does.
+func (d *resilientDriver) open() (*trackedDriver, *sync.WaitGroup) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	d.current.sessions.Add(1)
+	return d.current, &d.current.sessions
+}
+
+// Target returns the URL of the current driver.
+func (d *resilientDriver) Target() url.URL {
+	return d.driver().Target()
+}
+
+// NewSession opens a session on the current driver.
+func (d *resilientDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	driver, wg := d.open()
+	return &trackedSession{Session: driver.NewSession(config), wg: wg}
+}
+
+// Session opens a session with the given access mode and bookmarks on the
+// current driver.
+func (d *resilientDriver) Session(accessMode neo4j.AccessMode, bookmarks ...string) (neo4j.Session, error) {
+	driver, wg := d.open()
+	session, err := driver.Session(accessMode, bookmarks...)
+	if err != nil {
+		wg.Done()
+		return nil, err
+	}
+	return &trackedSession{Session: session, wg: wg}, nil
+}
+
+// VerifyConnectivity checks that the current driver can reach Memgraph.
+func (d *resilientDriver) VerifyConnectivity() error {
+	return d.driver().VerifyConnectivity()
+}
+
+// Close closes the current driver. Drivers replaced by reconnect close
+// themselves once their sessions are done.
+func (d *resilientDriver) Close() error {
+	return d.driver().Close()
+}
+
+// reconnect replaces the underlying driver with a new one, so that new
+// sessions get fresh connections. It does nothing if the driver was just
+// replaced. The old driver is closed once the sessions still running on it
+// are closed.
+func (d *resilientDriver) reconnect() error {
+	d.mu.Lock()
+	if time.Since(d.reconnectedAt) < reconnectInterval {
+		d.mu.Unlock()
+		return nil
+	}
+	driver, err := d.connect()
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	old := d.current
+	d.current, d.reconnectedAt = &trackedDriver{Driver: driver}, time.Now()
+	d.mu.Unlock()
+	go func() {
+		old.sessions.Wait()
+		old.Close()
+	}()
+	return nil
+}
+
+// reconnectOnError recreates the driver if err is a connectivity error and
+// reports whether the failed query should be retried.
+func reconnectOnError(driver neo4j.Driver, err error) bool {
+	d, ok := driver.(*resilientDriver)
+	if !ok || !neo4j.IsConnectivityError(err) {
+		return false
+	}
+	if err := d.reconnect(); err != nil {
+		log.Printf("Failed to reconnect to Memgraph: %v", err)
+		return false
+	}
+	return true
+}
+
+// CheckDatabase verifies that Memgraph is reachable. If it is not, the
+// driver is recreated so that queries succeed again as soon as the database
+// is back. Changes between up and down are logged.
+func CheckDatabase(driver neo4j.Driver) error {
+	err := driver.VerifyConnectivity()
+	if err == nil {
+		if databaseDown.Swap(false) {
+			log.Println("Memgraph connection restored")
+		}
+		return nil
+	}
+	if !databaseDown.Swap(true) {
+		log.Printf("Memgraph is unreachable: %v", err)
+	}
+	if d, ok := driver.(*resilientDriver); ok {
+		if err := d.reconnect(); err != nil {
+			log.Printf("Failed to reconnect to Memgraph: %v", err)
+		}
+	}
+	return fmt.Errorf("memgraph is unreachable: %w", err)
+}
+
+// WaitForDatabase checks the connection until Memgraph is reachable,
+// backing off up to 10s between attempts, and fails after timeout. Meant
+// for startup, when Memgraph may still be starting as well.
+func WaitForDatabase(driver neo4j.Driver, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	wait := time.Second
+	for {
+		err := driver.VerifyConnectivity()
+		if err == nil {
+			databaseDown.Store(false)
+			return nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("memgraph not reachable within %s: %w", timeout, err)
+		}
+		log.Printf("Waiting for Memgraph: %v", err)
+		time.Sleep(wait)
+		wait = min(2*wait, 10*time.Second)
+	}
+}
+
+// RunHealthCheck calls CheckDatabase every interval until stop is closed.
+func RunHealthCheck(driver neo4j.Driver, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			CheckDatabase(driver)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// waitDatabaseUp blocks while Memgraph is known to be down, so that queued
+// updates are kept until it is back instead of failing. Returns false if
+// stop was closed first.
+func waitDatabaseUp(stop <-chan struct{}) bool {
+	for databaseDown.Load() {
+		select {
+		case <-time.After(time.Second):
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
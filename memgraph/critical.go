@@ -0,0 +1,209 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// CriticalNode is a node whose removal would split the channel graph.
+type CriticalNode struct {
+	PubKey        string `json:"pubkey"`
+	Alias         string `json:"alias"`
+	TotalCapacity int64  `json:"total_capacity"`
+}
+
+// BridgeChannel is a channel whose closure would split the channel graph.
+type BridgeChannel struct {
+	ChannelID string `json:"channel_id"`
+	Node1     string `json:"node1"`
+	Node2     string `json:"node2"`
+	Capacity  int64  `json:"capacity"`
+}
+
+// CriticalElements lists the articulation points and bridges of a namespace.
+type CriticalElements struct {
+	ArticulationPoints []CriticalNode  `json:"articulation_points"`
+	Bridges            []BridgeChannel `json:"bridges"`
+}
+
+// findCriticalElements returns the articulation points (as pubkeys) and
+// bridges (as channel IDs) of the undirected channel graph, using an
+// iterative version of Tarjan's low-link algorithm. Open channels are
+// treated as undirected edges regardless of their policies, so parallel channels
+// between two nodes are never bridges.
+func findCriticalElements(g *channelGraph) (points []string, bridges []string) {
+	type neighbor struct{ node, channel int }
+	index := map[string]int{}
+	var pubKeys []string
+	nodeIndex := func(pubKey string) int {
+		i, ok := index[pubKey]
+		if !ok {
+			i = len(pubKeys)
+			index[pubKey] = i
+			pubKeys = append(pubKeys, pubKey)
+		}
+		return i
+	}
+	channels := map[string]int{}
+	var channelIDs []string
+	var adj [][]neighbor
+	for _, edges := range g.outgoing {
+		for _, e := range edges {
+			if _, ok := channels[e.ChannelID]; ok {
+				continue
+			}
+			channels[e.ChannelID] = len(channelIDs)
+			channelIDs = append(channelIDs, e.ChannelID)
+			a, b := nodeIndex(e.From), nodeIndex(e.To)
+			for len(adj) < len(pubKeys) {
+				adj = append(adj, nil)
+			}
+			adj[a] = append(adj[a], neighbor{b, channels[e.ChannelID]})
+			adj[b] = append(adj[b], neighbor{a, channels[e.ChannelID]})
+		}
+	}
+
+	type frame struct{ node, parentChannel, next int }
+	disc := make([]int, len(pubKeys))
+	low := make([]int, len(pubKeys))
+	for i := range disc {
+		disc[i] = -1
+	}
+	isPoint := make([]bool, len(pubKeys))
+	clock := 0
+	for root := range pubKeys {
+		if disc[root] >= 0 {
+			continue
+		}
+		disc[root], low[root] = clock, clock
+		clock++
+		rootChildren := 0
+		stack := []frame{{node: root, parentChannel: -1}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			v := top.node
+			if top.next < len(adj[v]) {
+				n := adj[v][top.next]
+				top.next++
+				if n.channel == top.parentChannel {
+					continue
+				}
+				if disc[n.node] < 0 {
+					disc[n.node], low[n.node] = clock, clock
+					clock++
+					if v == root {
+						rootChildren++
+					}
+					stack = append(stack, frame{node: n.node, parentChannel: n.channel})
+				} else {
+					low[v] = min(low[v], disc[n.node])
+				}
+				continue
+			}
+
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				break
+			}
+			p := stack[len(stack)-1].node
+			low[p] = min(low[p], low[v])
+			if low[v] > disc[p] {
+				bridges = append(bridges, channelIDs[top.parentChannel])
+			}
+			if p != root && low[v] >= disc[p] {
+				isPoint[p] = true
+			}
+		}
+		if rootChildren > 1 {
+			isPoint[root] = true
+		}
+	}
+
+	for i, point := range isPoint {
+		if point {
+			points = append(points, pubKeys[i])
+		}
+	}
+	return points, bridges
+}
+
+// TagCriticalElements computes the articulation points and bridges of a
+// namespace and stores them as is_articulation_point on nodes and is_bridge
+// on both directions of each channel.
+func TagCriticalElements(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return err
+	}
+	points, bridges := findCriticalElements(g)
+
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	params := map[string]interface{}{"namespace": namespace, "points": points, "bridges": bridges}
+	for _, q := range []struct {
+		desc  string
+		query string
+	}{
+		{"reset articulation points", "MATCH (n:node {namespace: $namespace}) SET n.is_articulation_point = false"},
+		{"reset bridges", "MATCH ()-[r:edge {namespace: $namespace}]->() SET r.is_bridge = false"},
+		{"tag articulation points", "UNWIND $points AS pubkey MATCH (n:node {pubkey: pubkey, namespace: $namespace}) SET n.is_articulation_point = true"},
+		{"tag bridges", "UNWIND $bridges AS id MATCH ()-[r:edge {channel_id: id, namespace: $namespace}]->() SET r.is_bridge = true"},
+	} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := session.Run(q.query, params); err != nil {
+			return fmt.Errorf("failed to %s: %w", q.desc, err)
+		}
+	}
+	return nil
+}
+
+// GetCriticalElements returns the articulation points and bridges tagged by
+// the last TagCriticalElements run, largest first.
+func GetCriticalElements(driver neo4j.Driver, namespace string) (*CriticalElements, error) {
+	params := map[string]interface{}{"namespace": namespace}
+	nodeRecords, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace, is_articulation_point: true})
+		RETURN n.pubkey AS pubkey, n.alias AS alias, toInteger(n.total_capacity) AS total_capacity
+		ORDER BY total_capacity DESC
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read articulation points: %w", err)
+	}
+	bridgeRecords, err := collectRecords(driver, `
+		MATCH (a:node)-[r:edge {namespace: $namespace, is_bridge: true}]->(b:node)
+		RETURN r.channel_id AS channel_id, a.pubkey AS node1, b.pubkey AS node2, toInteger(r.capacity) AS capacity
+		ORDER BY capacity DESC
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bridges: %w", err)
+	}
+
+	result := &CriticalElements{ArticulationPoints: []CriticalNode{}, Bridges: []BridgeChannel{}}
+	for _, record := range nodeRecords {
+		values := recordMap(record)
+		n := CriticalNode{}
+		n.PubKey, _ = values["pubkey"].(string)
+		n.Alias, _ = values["alias"].(string)
+		n.TotalCapacity, _ = values["total_capacity"].(int64)
+		result.ArticulationPoints = append(result.ArticulationPoints, n)
+	}
+	seen := map[string]bool{}
+	for _, record := range bridgeRecords {
+		values := recordMap(record)
+		b := BridgeChannel{}
+		b.ChannelID, _ = values["channel_id"].(string)
+		if seen[b.ChannelID] {
+			continue
+		}
+		seen[b.ChannelID] = true
+		b.Node1, _ = values["node1"].(string)
+		b.Node2, _ = values["node2"].(string)
+		b.Capacity, _ = values["capacity"].(int64)
+		result.Bridges = append(result.Bridges, b)
+	}
+	return result, nil
+}
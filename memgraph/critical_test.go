@@ -0,0 +1,88 @@
+package memgraph
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFindCriticalElements(t *testing.T) {
+	join := func(channels ...[]channelEdge) []channelEdge {
+		var edges []channelEdge
+		for _, c := range channels {
+			edges = append(edges, c...)
+		}
+		return edges
+	}
+	tests := []struct {
+		name    string
+		edges   []channelEdge
+		points  []string
+		bridges []string
+	}{
+		{
+			name:    "path",
+			edges:   join(channel("ab", "a", "b", 1), channel("bc", "b", "c", 1)),
+			points:  []string{"b"},
+			bridges: []string{"ab", "bc"},
+		},
+		{
+			name:  "cycle",
+			edges: join(channel("ab", "a", "b", 1), channel("bc", "b", "c", 1), channel("ca", "c", "a", 1)),
+		},
+		{
+			name:  "parallel channels are not bridges",
+			edges: join(channel("ab1", "a", "b", 1), channel("ab2", "a", "b", 1)),
+		},
+		{
+			name:    "parallel channels next to a bridge",
+			edges:   join(channel("ab1", "a", "b", 1), channel("ab2", "a", "b", 1), channel("bc", "b", "c", 1)),
+			points:  []string{"b"},
+			bridges: []string{"bc"},
+		},
+		{
+			// Whichever node the search starts from, the hub must be found
+			// and the leaves must not.
+			name:    "star",
+			edges:   join(channel("ha", "h", "a", 1), channel("hb", "h", "b", 1), channel("hc", "h", "c", 1)),
+			points:  []string{"h"},
+			bridges: []string{"ha", "hb", "hc"},
+		},
+		{
+			name: "two cycles sharing a node",
+			edges: join(
+				channel("ab", "a", "b", 1), channel("bc", "b", "c", 1), channel("ca", "c", "a", 1),
+				channel("cd", "c", "d", 1), channel("de", "d", "e", 1), channel("ec", "e", "c", 1),
+			),
+			points: []string{"c"},
+		},
+		{
+			name:    "disconnected components",
+			edges:   join(channel("ab", "a", "b", 1), channel("xy", "x", "y", 1), channel("yz", "y", "z", 1)),
+			points:  []string{"y"},
+			bridges: []string{"ab", "xy", "yz"},
+		},
+		{
+			name:    "one direction only",
+			edges:   []channelEdge{arc("ab", "a", "b", 1), arc("bc", "b", "c", 1)},
+			points:  []string{"b"},
+			bridges: []string{"ab", "bc"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Map iteration changes the search root, so run a few times.
+			for i := 0; i < 20; i++ {
+				points, bridges := findCriticalElements(newTestGraph(test.edges...))
+				sort.Strings(points)
+				sort.Strings(bridges)
+				if strings.Join(points, ",") != strings.Join(test.points, ",") {
+					t.Fatalf("articulation points = %v, want %v", points, test.points)
+				}
+				if strings.Join(bridges, ",") != strings.Join(test.bridges, ",") {
+					t.Fatalf("bridges = %v, want %v", bridges, test.bridges)
+				}
+			}
+		})
+	}
+}
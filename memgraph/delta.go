@@ -0,0 +1,87 @@
+package memgraph
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Delta is the part of a namespace's graph that changed in a time window.
+// Nodes and Edges hold the same items as ListNodes and ListEdges.
+// ClosedChannels lists the IDs of channels closed while updates were
+// running. Until is when the delta was read; pass it as the next since.
+type Delta struct {
+	Since          time.Time                `json:"since"`
+	Until          time.Time                `json:"until"`
+	Nodes          []map[string]interface{} `json:"nodes"`
+	Edges          []map[string]interface{} `json:"edges"`
+	ClosedChannels []string                 `json:"closed_channels"`
+}
+
+// ExportDelta returns the nodes and channel directions of a namespace whose
+// last_update is at or after since, and the channels journaled as closed
+// since then. last_update is the gossip timestamp, which may lag behind the
+// time an update arrives, so consumers should let consecutive windows
+// overlap a little.
+func ExportDelta(driver neo4j.Driver, namespace string, since time.Time) (*Delta, error) {
+	delta := &Delta{
+		Since:          since,
+		Until:          time.Now().UTC().Truncate(time.Second),
+		Nodes:          []map[string]interface{}{},
+		Edges:          []map[string]interface{}{},
+		ClosedChannels: []string{},
+	}
+	params := map[string]interface{}{"namespace": namespace, "since": since.Unix()}
+
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.last_update >= $since
+		RETURN properties(n) AS props
+		ORDER BY n.pubkey
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changed nodes: %w", err)
+	}
+	for _, record := range records {
+		props, _ := record.Get("props")
+		if node, ok := props.(map[string]interface{}); ok {
+			delta.Nodes = append(delta.Nodes, node)
+		}
+	}
+
+	records, err = collectRecords(driver, `
+		MATCH (a:node {namespace: $namespace})-[r:edge]->(b:node)
+		WHERE r.last_update >= $since
+		RETURN a.pubkey AS from, b.pubkey AS to, properties(r) AS props
+		ORDER BY r.channel_id, a.pubkey
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changed edges: %w", err)
+	}
+	for _, record := range records {
+		values := recordMap(record)
+		edge, _ := values["props"].(map[string]interface{})
+		if edge == nil {
+			edge = map[string]interface{}{}
+		}
+		edge["from"], edge["to"] = values["from"], values["to"]
+		delta.Edges = append(delta.Edges, edge)
+	}
+
+	records, err = collectRecords(driver, `
+		MATCH (c:channel_close {namespace: $namespace})
+		WHERE c.at >= $since
+		RETURN DISTINCT c.channel_id AS channel_id
+		ORDER BY channel_id
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read closed channels: %w", err)
+	}
+	for _, record := range records {
+		if id, ok := recordMap(record)["channel_id"].(string); ok {
+			delta.ClosedChannels = append(delta.ClosedChannels, id)
+		}
+	}
+	return delta, nil
+}
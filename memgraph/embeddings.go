@@ -0,0 +1,185 @@
+package memgraph
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Node2VecParams are the parameters passed to MAGE's node2vec. P and Q bias
+// the random walks towards returning (P) or exploring outwards (Q).
+type Node2VecParams struct {
+	Directed   bool
+	P, Q       float64
+	NumWalks   int
+	WalkLength int
+	Dimensions int
+}
+
+// DefaultNode2VecParams returns the parameters used when none are given.
+func DefaultNode2VecParams() Node2VecParams {
+	return Node2VecParams{P: 1, Q: 1, NumWalks: 4, WalkLength: 5, Dimensions: 64}
+}
+
+// Embedding is a node's learned vector.
+type Embedding struct {
+	PubKey string
+	Vector []float64
+}
+
+// ComputeEmbeddings runs MAGE's node2vec on a namespace's channel graph and
+// returns one embedding per node with channels, in pubkey order.
+func ComputeEmbeddings(driver neo4j.Driver, namespace string, params Node2VecParams) ([]Embedding, error) {
+	records, err := collectRecords(driver, `
+		MATCH p=(n:node {namespace: $namespace})-[r:edge]->(m:node)
+		WHERE `+openChannel("r")+`
+		WITH project(p) AS subgraph
+		CALL node2vec.get_embeddings(subgraph, $directed, $p, $q, $numWalks, $walkLength, $dimensions)
+		YIELD node, embedding
+		RETURN node.pubkey AS pubkey, embedding
+		ORDER BY pubkey
+	`, map[string]interface{}{
+		"namespace":  namespace,
+		"directed":   params.Directed,
+		"p":          params.P,
+		"q":          params.Q,
+		"numWalks":   params.NumWalks,
+		"walkLength": params.WalkLength,
+		"dimensions": params.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute node2vec embeddings: %w", err)
+	}
+
+	embeddings := make([]Embedding, 0, len(records))
+	for _, record := range records {
+		values := recordMap(record)
+		e := Embedding{}
+		e.PubKey, _ = values["pubkey"].(string)
+		raw, _ := values["embedding"].([]interface{})
+		e.Vector = make([]float64, 0, len(raw))
+		for _, v := range raw {
+			f, _ := toFloat(v)
+			e.Vector = append(e.Vector, f)
+		}
+		embeddings = append(embeddings, e)
+	}
+	return embeddings, nil
+}
+
+// WriteEmbeddingsCSV writes embeddings as CSV with a pubkey column followed
+// by one column per dimension.
+func WriteEmbeddingsCSV(w io.Writer, embeddings []Embedding) error {
+	out := csv.NewWriter(w)
+	dimensions := 0
+	if len(embeddings) > 0 {
+		dimensions = len(embeddings[0].Vector)
+	}
+	header := []string{"pubkey"}
+	for i := 0; i < dimensions; i++ {
+		header = append(header, "e"+strconv.Itoa(i))
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, e := range embeddings {
+		row := []string{e.PubKey}
+		for _, v := range e.Vector {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 32))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteEmbeddingsNPZ writes embeddings as a NumPy .npz archive holding two
+// arrays: embeddings (float32, nodes x dimensions) and pubkeys (the matching
+// row keys), so that numpy.load(f)["pubkeys"][i] names row i.
+func WriteEmbeddingsNPZ(w io.Writer, embeddings []Embedding) error {
+	dimensions := 0
+	if len(embeddings) > 0 {
+		dimensions = len(embeddings[0].Vector)
+	}
+	archive := zip.NewWriter(w)
+
+	f, err := archive.Create("embeddings.npy")
+	if err != nil {
+		return err
+	}
+	if err := writeNPYHeader(f, "<f4", fmt.Sprintf("(%d, %d)", len(embeddings), dimensions)); err != nil {
+		return err
+	}
+	row := make([]byte, 4*dimensions)
+	for _, e := range embeddings {
+		for i := 0; i < dimensions; i++ {
+			var v float64
+			if i < len(e.Vector) {
+				v = e.Vector[i]
+			}
+			binary.LittleEndian.PutUint32(row[4*i:], math.Float32bits(float32(v)))
+		}
+		if _, err := f.Write(row); err != nil {
+			return err
+		}
+	}
+
+	// Pubkeys are stored as fixed-width UTF-32 strings, NumPy's native
+	// unicode dtype.
+	width := 66
+	f, err = archive.Create("pubkeys.npy")
+	if err != nil {
+		return err
+	}
+	if err := writeNPYHeader(f, fmt.Sprintf("<U%d", width), fmt.Sprintf("(%d,)", len(embeddings))); err != nil {
+		return err
+	}
+	key := make([]byte, 4*width)
+	for _, e := range embeddings {
+		for i := range key {
+			key[i] = 0
+		}
+		for i, r := range []rune(e.PubKey) {
+			if i == width {
+				break
+			}
+			binary.LittleEndian.PutUint32(key[4*i:], uint32(r))
+		}
+		if _, err := f.Write(key); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// writeNPYHeader writes a version 1.0 .npy header for a C-ordered array of
+// the given dtype and shape (as a Python tuple literal). The header is padded
+// so that the data starts on a 64-byte boundary.
+func writeNPYHeader(w io.Writer, dtype, shape string) error {
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", dtype, shape)
+	// magic (6) + version (2) + header length (2) + header + newline
+	padding := 64 - (10+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	for i := 0; i < padding; i++ {
+		header += " "
+	}
+	header += "\n"
+
+	prefix := []byte("\x93NUMPY\x01\x00")
+	prefix = binary.LittleEndian.AppendUint16(prefix, uint16(len(header)))
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, header)
+	return err
+}
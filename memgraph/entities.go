@@ -0,0 +1,105 @@
+package memgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/lnd"
+)
+
+// Entity describes who operates a node, as given in the entities file.
+type Entity struct {
+	Organization string   `json:"organization"`
+	Tags         []string `json:"tags"`
+}
+
+var (
+	// entitiesMu protects entities, the known entities by pubkey.
+	entitiesMu sync.RWMutex
+	entities   = map[string]Entity{}
+)
+
+// LoadEntities reads a JSON object mapping node pubkeys to entities, e.g.
+//
+//	{"03abc...": {"organization": "Example Exchange", "tags": ["exchange"]}}
+//
+// and replaces the known entities with it. Tags are lowercased. Returns the
+// number of entities loaded; on error the known entities are unchanged.
+func LoadEntities(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read entities file: %w", err)
+	}
+	var loaded map[string]Entity
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return 0, fmt.Errorf("failed to decode entities file: %w", err)
+	}
+	for pubKey, entity := range loaded {
+		if !lnd.IsValidPubKey(pubKey) {
+			return 0, fmt.Errorf("invalid pubkey %q in entities file", pubKey)
+		}
+		for i, tag := range entity.Tags {
+			entity.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+		}
+		if entity.Tags == nil {
+			entity.Tags = []string{}
+		}
+		loaded[pubKey] = entity
+	}
+
+	entitiesMu.Lock()
+	entities = loaded
+	entitiesMu.Unlock()
+	return len(loaded), nil
+}
+
+// lookupEntity returns the known entity operating a node, if any.
+func lookupEntity(pubKey string) (Entity, bool) {
+	entitiesMu.RLock()
+	defer entitiesMu.RUnlock()
+	entity, ok := entities[pubKey]
+	return entity, ok
+}
+
+// TagEntities stores the organization and tags of every known entity on its
+// node, and removes them from nodes no longer known. An empty namespace tags
+// every namespace. Returns the number of nodes tagged.
+func TagEntities(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	entitiesMu.RLock()
+	rows := make([]map[string]interface{}, 0, len(entities))
+	pubKeys := make([]string, 0, len(entities))
+	for pubKey, entity := range entities {
+		rows = append(rows, map[string]interface{}{"pubkey": pubKey, "organization": entity.Organization, "tags": entity.Tags})
+		pubKeys = append(pubKeys, pubKey)
+	}
+	entitiesMu.RUnlock()
+
+	params := map[string]interface{}{"namespace": namespace, "rows": rows, "pubkeys": pubKeys}
+	_, err := CommitQuery(ctx, driver, `
+		MATCH (n:node)
+		WHERE ($namespace = '' OR n.namespace = $namespace) AND n.tags IS NOT NULL AND NOT n.pubkey IN $pubkeys
+		REMOVE n.organization, n.tags
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear entity tags: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	tagged, err := countQuery(driver, `
+		UNWIND $rows AS row
+		MATCH (n:node {pubkey: row.pubkey})
+		WHERE $namespace = '' OR n.namespace = $namespace
+		SET n.organization = row.organization, n.tags = row.tags
+		RETURN count(n) AS count
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to tag entities: %w", err)
+	}
+	return tagged, nil
+}
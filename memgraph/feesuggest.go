@@ -0,0 +1,131 @@
+package memgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// minCompetitors is the number of competing channels below which a peer's
+// fee range is considered too thin to judge a channel's fees against.
+const minCompetitors = 3
+
+// FeeSuggestion compares the fees of one of the local node's channels with
+// the competing channels into the same peer, which payments to and through
+// the peer could take instead. Status is over or under when the fee rate is
+// more than twice the competitors' p75 or less than half their p25,
+// competitive when it lies in between, and unknown with fewer than
+// minCompetitors competitors. Suggested fees differ from the current ones
+// only for over and under: the competitors' median, or their p75 or p25
+// when the channel's outbound liquidity is known to be scarce or plentiful.
+type FeeSuggestion struct {
+	ChannelID          string  `json:"channel_id"`
+	Peer               string  `json:"peer"`
+	PeerAlias          string  `json:"peer_alias,omitempty"`
+	Status             string  `json:"status"`
+	Competitors        int     `json:"competitors"`
+	FeeBaseMsat        int64   `json:"fee_base_msat"`
+	FeeRateMilliMsat   int64   `json:"fee_rate_milli_msat"`
+	CompetitorRateP25  float64 `json:"competitor_fee_rate_p25"`
+	CompetitorRateP50  float64 `json:"competitor_fee_rate_p50"`
+	CompetitorRateP75  float64 `json:"competitor_fee_rate_p75"`
+	CompetitorBaseP50  float64 `json:"competitor_fee_base_p50"`
+	SuggestedBaseMsat  int64   `json:"suggested_fee_base_msat"`
+	SuggestedRateMilli int64   `json:"suggested_fee_rate_milli_msat"`
+	// OutboundRatio is own_local_balance over capacity, if known.
+	OutboundRatio *float64 `json:"outbound_ratio,omitempty"`
+}
+
+// SuggestFees returns a FeeSuggestion for every open channel of the local
+// node pubKey in a namespace, the most mispriced first.
+func SuggestFees(driver neo4j.Driver, namespace, pubKey string) ([]FeeSuggestion, error) {
+	records, err := collectRecords(driver, `
+		MATCH (me:node {pubkey: $pubkey, namespace: $namespace})-[r:edge]->(peer:node)
+		WHERE r.pending IS NULL AND NOT coalesce(r.closed, false)
+		OPTIONAL MATCH (other:node)-[c:edge]->(peer)
+		WHERE other <> me AND NOT coalesce(c.disabled, false)
+		RETURN r.channel_id AS channel_id, peer.pubkey AS peer, peer.alias AS alias,
+			toInteger(r.fee_base_msat) AS fee_base, toInteger(r.fee_rate_milli_msat) AS fee_rate,
+			toInteger(r.capacity) AS capacity, r.own_local_balance AS local_balance,
+			collect(toInteger(c.fee_rate_milli_msat)) AS rates, collect(toInteger(c.fee_base_msat)) AS bases
+	`, map[string]interface{}{"namespace": namespace, "pubkey": pubKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	suggestions := make([]FeeSuggestion, 0, len(records))
+	for _, record := range records {
+		values := recordMap(record)
+		s := FeeSuggestion{Status: "unknown"}
+		s.ChannelID, _ = values["channel_id"].(string)
+		s.Peer, _ = values["peer"].(string)
+		s.PeerAlias, _ = values["alias"].(string)
+		s.FeeBaseMsat, _ = values["fee_base"].(int64)
+		s.FeeRateMilliMsat, _ = values["fee_rate"].(int64)
+		s.SuggestedBaseMsat, s.SuggestedRateMilli = s.FeeBaseMsat, s.FeeRateMilliMsat
+		capacity, _ := values["capacity"].(int64)
+		if local, ok := values["local_balance"].(int64); ok && capacity > 0 {
+			ratio := float64(local) / float64(capacity)
+			s.OutboundRatio = &ratio
+		}
+
+		rates, bases := collectFloats(values["rates"]), collectFloats(values["bases"])
+		s.Competitors = len(rates)
+		if s.Competitors < minCompetitors {
+			suggestions = append(suggestions, s)
+			continue
+		}
+		sort.Float64s(rates)
+		sort.Float64s(bases)
+		s.CompetitorRateP25 = percentile(rates, 25)
+		s.CompetitorRateP50 = percentile(rates, 50)
+		s.CompetitorRateP75 = percentile(rates, 75)
+		s.CompetitorBaseP50 = percentile(bases, 50)
+
+		rate := float64(s.FeeRateMilliMsat)
+		switch {
+		case rate > 2*s.CompetitorRateP75 && rate > s.CompetitorRateP75+1:
+			s.Status = "over"
+		case rate < s.CompetitorRateP25/2:
+			s.Status = "under"
+		default:
+			s.Status = "competitive"
+			suggestions = append(suggestions, s)
+			continue
+		}
+		target := s.CompetitorRateP50
+		if s.OutboundRatio != nil && *s.OutboundRatio < 0.2 {
+			target = s.CompetitorRateP75
+		} else if s.OutboundRatio != nil && *s.OutboundRatio > 0.8 {
+			target = s.CompetitorRateP25
+		}
+		s.SuggestedRateMilli = int64(target)
+		s.SuggestedBaseMsat = int64(s.CompetitorBaseP50)
+		suggestions = append(suggestions, s)
+	}
+
+	// Order by how far each fee rate is from its suggestion, relative to the
+	// competitors' median.
+	deviation := func(s FeeSuggestion) float64 {
+		diff := float64(s.FeeRateMilliMsat - s.SuggestedRateMilli)
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff / max(s.CompetitorRateP50, 1)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool { return deviation(suggestions[i]) > deviation(suggestions[j]) })
+	return suggestions, nil
+}
+
+// collectFloats converts a list record value to float64s, skipping nulls.
+func collectFloats(value interface{}) []float64 {
+	list, _ := value.([]interface{})
+	values := make([]float64, 0, len(list))
+	for _, v := range list {
+		if f, ok := toFloat(v); ok {
+			values = append(values, f)
+		}
+	}
+	return values
+}
@@ -0,0 +1,129 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/lnd"
+)
+
+// activityKey is one direction of one of the local node's channels:
+// outgoing is the edge from the local node to the peer, incoming the edge
+// from the peer to the local node.
+type activityKey struct {
+	channelID string
+	outgoing  bool
+}
+
+// channelActivity counts the forwards over one channel direction.
+type channelActivity struct {
+	forwards    int64
+	forwardMsat int64
+	fails       int64
+	failMsat    int64
+	feesMsat    int64
+}
+
+// recordForward adds a forward's outcome to the activity of its incoming and
+// outgoing channel. Fees are earned on the outgoing side.
+func recordForward(activity map[activityKey]*channelActivity, f lnd.Forward) {
+	for _, side := range []struct {
+		key  activityKey
+		msat int64
+	}{
+		{activityKey{f.InChannel, false}, f.InMsat},
+		{activityKey{f.OutChannel, true}, f.OutMsat},
+	} {
+		a := activity[side.key]
+		if a == nil {
+			a = &channelActivity{}
+			activity[side.key] = a
+		}
+		if !f.Settled {
+			a.fails++
+			a.failMsat += side.msat
+			continue
+		}
+		a.forwards++
+		a.forwardMsat += side.msat
+		if side.key.outgoing {
+			a.feesMsat += f.InMsat - f.OutMsat
+		}
+	}
+}
+
+// writeActivity adds the activity counted since the last write to the
+// matching edges of the local node, marked is_self, in the given namespaces.
+// htlc_since is set on an edge's first write.
+func writeActivity(ctx context.Context, driver neo4j.Driver, namespaces []string, activity map[activityKey]*channelActivity) error {
+	rows := make([]map[string]interface{}, 0, len(activity))
+	for key, a := range activity {
+		rows = append(rows, map[string]interface{}{
+			"channel_id":   key.channelID,
+			"outgoing":     key.outgoing,
+			"forwards":     a.forwards,
+			"forward_msat": a.forwardMsat,
+			"fails":        a.fails,
+			"fail_msat":    a.failMsat,
+			"fees_msat":    a.feesMsat,
+		})
+	}
+	_, err := commitOnce(ctx, driver, `
+		UNWIND $rows AS row
+		MATCH (a:node)-[r:edge {channel_id: row.channel_id}]->(b:node)
+		WHERE r.namespace IN $namespaces
+			AND ((row.outgoing AND a.is_self) OR (NOT row.outgoing AND b.is_self))
+		SET r.htlc_forwards = coalesce(r.htlc_forwards, 0) + row.forwards,
+			r.htlc_forward_msat = coalesce(r.htlc_forward_msat, 0) + row.forward_msat,
+			r.htlc_fails = coalesce(r.htlc_fails, 0) + row.fails,
+			r.htlc_fail_msat = coalesce(r.htlc_fail_msat, 0) + row.fail_msat,
+			r.htlc_fees_msat = coalesce(r.htlc_fees_msat, 0) + row.fees_msat,
+			r.htlc_since = coalesce(r.htlc_since, $now)
+	`, map[string]interface{}{"rows": rows, "namespaces": namespaces, "now": time.Now().Unix()})
+	if err != nil {
+		return fmt.Errorf("failed to write channel activity: %w", err)
+	}
+	return nil
+}
+
+// RunForwardAggregation counts the forwards received on forwards per channel
+// direction of the local node and, every interval, adds them to the edges in
+// the live namespaces: htlc_forwards and htlc_forward_msat for settled
+// forwards, htlc_fails and htlc_fail_msat for failed ones, htlc_fees_msat
+// for the fees earned on outgoing edges, and htlc_since, when counting on
+// the edge started. The totals are stored on the edges, so they survive
+// restarts; an import that recreates an edge starts it over. Forwards are
+// kept in memory until a write succeeds. Returns when stop is closed or
+// forwards is closed.
+func RunForwardAggregation(driver neo4j.Driver, interval time.Duration, forwards <-chan lnd.Forward, stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	activity := map[activityKey]*channelActivity{}
+	for {
+		select {
+		case f, ok := <-forwards:
+			if !ok {
+				return
+			}
+			recordForward(activity, f)
+		case <-ticker.C:
+			namespaces := LiveNamespaces()
+			if len(activity) == 0 || len(namespaces) == 0 {
+				continue
+			}
+			if err := writeActivity(ctx, driver, namespaces, activity); err != nil {
+				log.Printf("Forward aggregation failed: %v", err)
+				continue
+			}
+			activity = map[activityKey]*channelActivity{}
+		case <-stop:
+			return
+		}
+	}
+}
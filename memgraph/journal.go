@@ -0,0 +1,243 @@
+package memgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// PolicyChange is a journal entry for a change to the fees or disabled flag
+// of one direction of a channel. Node is the node that announced the change
+// and Peer the other end of the channel. At is the gossip timestamp of the
+// announcing update.
+type PolicyChange struct {
+	ChannelID string            `json:"channel_id"`
+	Node      string            `json:"node"`
+	Peer      string            `json:"peer"`
+	Changes   map[string]Change `json:"changes"`
+	At        time.Time         `json:"at"`
+}
+
+// recordPolicyChanges compares the channel updates in a batch with the stored
+// policies and journals every change to fees or the disabled flag as a
+// :policy_change node. Channels seen for the first time are not journaled.
+// Must run before the update is applied.
+func recordPolicyChanges(ctx context.Context, driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) {
+	if len(update.ChannelEdgeUpdates) == 0 {
+		return
+	}
+
+	rows := make([]map[string]interface{}, 0, len(update.ChannelEdgeUpdates))
+	for i, edgeUpdate := range update.ChannelEdgeUpdates {
+		rows = append(rows, map[string]interface{}{
+			"i":         i,
+			"node":      edgeUpdate.AdvertisingNode.String(),
+			"channelID": channelID(edgeUpdate.ChannelID),
+		})
+	}
+	records, err := collectRecords(driver, `
+		UNWIND $rows AS row
+		MATCH (:node {pubkey: row.node, namespace: $namespace})-[r:edge {channel_id: row.channelID}]->()
+		RETURN row.i AS i, r.fee_base_msat AS fee_base_msat, r.fee_rate_milli_msat AS fee_rate_milli_msat,
+			r.disabled AS disabled
+	`, map[string]interface{}{"rows": rows, "namespace": namespace})
+	if err != nil {
+		log.Printf("Failed to read stored policies for the journal: %v", err)
+		return
+	}
+
+	var entries []map[string]interface{}
+	for _, record := range records {
+		old := recordMap(record)
+		i, ok := old["i"].(int64)
+		if !ok || int(i) >= len(update.ChannelEdgeUpdates) {
+			continue
+		}
+		edgeUpdate := update.ChannelEdgeUpdates[i]
+
+		// Disabling updates only touch the disabled flag; see ProcessEdgeUpdate.
+		policy := map[string]interface{}{"disabled": edgeUpdate.RoutingPolicy.Disabled}
+		if !edgeUpdate.RoutingPolicy.Disabled {
+			policy["fee_base_msat"] = edgeUpdate.RoutingPolicy.FeeBaseMsat
+			policy["fee_rate_milli_msat"] = edgeUpdate.RoutingPolicy.FeeRateMilliMsat
+		}
+		changes := diff(old, policy)
+		for field, change := range changes {
+			// A property that was never stored is not a change.
+			if change.Old == nil {
+				delete(changes, field)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		data, err := json.Marshal(changes)
+		if err != nil {
+			log.Printf("Failed to encode policy changes: %v", err)
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"channelID": channelID(edgeUpdate.ChannelID),
+			"node":      edgeUpdate.AdvertisingNode.String(),
+			"peer":      edgeUpdate.ConnectingNode.String(),
+			"changes":   string(data),
+			"at":        edgeUpdate.RoutingPolicy.LastUpdate.Unix(),
+		})
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	_, err = commitOnce(ctx, driver, `
+		UNWIND $entries AS entry
+		CREATE (:policy_change {namespace: $namespace, channel_id: entry.channelID, node: entry.node,
+			peer: entry.peer, changes: entry.changes, at: entry.at})
+	`, map[string]interface{}{"entries": entries, "namespace": namespace})
+	if err != nil {
+		log.Printf("Failed to journal policy changes: %v", err)
+	}
+}
+
+// recordChannelCloses journals the channels closed in a batch as
+// :channel_close nodes, so that delta exports can report them after their
+// edges are gone. At is when the close was seen.
+func recordChannelCloses(ctx context.Context, driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) {
+	if len(update.ChannelCloseUpdates) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(update.ChannelCloseUpdates))
+	for _, closeUpdate := range update.ChannelCloseUpdates {
+		ids = append(ids, channelID(closeUpdate.ChannelID))
+	}
+	_, err := commitOnce(ctx, driver, `
+		UNWIND $ids AS id
+		CREATE (:channel_close {namespace: $namespace, channel_id: id, at: $now})
+	`, map[string]interface{}{"ids": ids, "namespace": namespace, "now": time.Now().Unix()})
+	if err != nil {
+		log.Printf("Failed to journal channel closes: %v", err)
+	}
+}
+
+// ListPolicyChanges returns the journaled fee and disabled changes that a
+// node announced for its channels at or after since, oldest first. At most
+// limit entries are returned.
+func ListPolicyChanges(driver neo4j.Driver, namespace, pubKey string, since time.Time, limit int) ([]PolicyChange, error) {
+	records, err := collectRecords(driver, `
+		MATCH (p:policy_change {node: $pubKey, namespace: $namespace})
+		WHERE p.at >= $since
+		RETURN p.channel_id AS channel_id, p.peer AS peer, p.changes AS changes, p.at AS at
+		ORDER BY p.at
+		LIMIT $limit
+	`, map[string]interface{}{"pubKey": pubKey, "namespace": namespace, "since": since.Unix(), "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policy changes: %w", err)
+	}
+	changes := make([]PolicyChange, 0, len(records))
+	for _, record := range records {
+		values := recordMap(record)
+		p := PolicyChange{Node: pubKey}
+		p.ChannelID, _ = values["channel_id"].(string)
+		p.Peer, _ = values["peer"].(string)
+		if data, ok := values["changes"].(string); ok && data != "" {
+			if err := json.Unmarshal([]byte(data), &p.Changes); err != nil {
+				log.Printf("Failed to decode policy changes: %v", err)
+			}
+		}
+		if at, ok := values["at"].(int64); ok {
+			p.At = time.Unix(at, 0)
+		}
+		changes = append(changes, p)
+	}
+	return changes, nil
+}
+
+// PruneJournal deletes journal entries, policy changes and channel closes,
+// older than maxAge and, per namespace and kind, all but the newest keepLast
+// entries. A zero limit is not applied. Returns
+// the number of entries deleted.
+func PruneJournal(driver neo4j.Driver, keepLast int, maxAge time.Duration) (int64, error) {
+	var deleted int64
+	if maxAge > 0 {
+		n, err := countQuery(driver, `
+			MATCH (p)
+			WHERE (p:policy_change OR p:channel_close) AND p.at < $cutoff
+			DELETE p
+			RETURN count(*) AS count
+		`, map[string]interface{}{"cutoff": time.Now().Add(-maxAge).Unix()})
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete old journal entries: %w", err)
+		}
+		deleted += n
+	}
+	if keepLast > 0 {
+		n, err := countQuery(driver, `
+			MATCH (p)
+			WHERE p:policy_change OR p:channel_close
+			WITH p ORDER BY p.at DESC
+			WITH p.namespace AS namespace, labels(p) AS kind, collect(p) AS entries
+			UNWIND entries[$keep..] AS p
+			DELETE p
+			RETURN count(*) AS count
+		`, map[string]interface{}{"keep": keepLast})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete excess journal entries: %w", err)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// JournalStats describes the size of the update journal.
+type JournalStats struct {
+	Entries int64      `json:"entries"`
+	Oldest  *time.Time `json:"oldest,omitempty"`
+}
+
+// GetJournalStats counts the journal entries, policy changes and channel
+// closes, in all namespaces.
+func GetJournalStats(driver neo4j.Driver) (JournalStats, error) {
+	records, err := collectRecords(driver, `
+		MATCH (p)
+		WHERE p:policy_change OR p:channel_close
+		RETURN count(p) AS entries, min(p.at) AS oldest
+	`, nil)
+	if err != nil {
+		return JournalStats{}, fmt.Errorf("failed to read journal stats: %w", err)
+	}
+	var stats JournalStats
+	if len(records) == 1 {
+		values := recordMap(records[0])
+		stats.Entries, _ = values["entries"].(int64)
+		if oldest, ok := values["oldest"].(int64); ok {
+			t := time.Unix(oldest, 0)
+			stats.Oldest = &t
+		}
+	}
+	return stats, nil
+}
+
+// RunJournalRetention calls PruneJournal every interval until stop is closed.
+func RunJournalRetention(driver neo4j.Driver, keepLast int, maxAge, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := PruneJournal(driver, keepLast, maxAge)
+			if err != nil {
+				log.Printf("Journal pruning failed: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Pruned %d journal entries", deleted)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package memgraph
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// ErrInvalidCursor is returned when a listing cursor was not produced by a
+// previous page of the same listing.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter selects the nodes or channel directions returned by ListNodes
+// and ListEdges. Zero values do not filter.
+type ListFilter struct {
+	// MinCapacity is the minimum total_capacity of nodes, or capacity of
+	// channels, in sats.
+	MinCapacity int64
+	// EnabledOnly skips disabled channel directions. It does not apply to
+	// nodes.
+	EnabledOnly bool
+	// UpdatedSince is the earliest last_update, in unix seconds.
+	UpdatedSince int64
+	// Features are feature bits that must all be set: on the node itself,
+	// or on the advertising node of a channel direction.
+	Features []int64
+	// PeersOnly keeps only nodes that are current peers of the local node
+	// (see MarkPeers). It does not apply to channels.
+	PeersOnly bool
+	// Connectivity keeps only nodes of this connectivity class (see
+	// lnd.ClassifyAddresses). It does not apply to channels.
+	Connectivity string
+	// Cursor continues a listing after the last item of a previous page.
+	Cursor string
+	Limit  int
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page struct {
+	Items      []map[string]interface{} `json:"items"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// encodeCursor makes an opaque cursor from the sort key of the last item.
+func encodeCursor(key ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(key, "\x00")))
+}
+
+// decodeCursor returns the parts of a cursor's sort key, or an empty key of
+// the given length for an empty cursor.
+func decodeCursor(cursor string, parts int) ([]string, error) {
+	if cursor == "" {
+		return make([]string, parts), nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	key := strings.Split(string(data), "\x00")
+	if len(key) != parts {
+		return nil, ErrInvalidCursor
+	}
+	return key, nil
+}
+
+// filterParams returns the query parameters shared by both listings.
+func filterParams(namespace string, filter ListFilter) map[string]interface{} {
+	features := filter.Features
+	if features == nil {
+		features = []int64{}
+	}
+	return map[string]interface{}{
+		"namespace":   namespace,
+		"minCapacity": filter.MinCapacity,
+		"since":       filter.UpdatedSince,
+		"features":    features,
+		"limit":       filter.Limit + 1,
+	}
+}
+
+// ListNodes returns a page of a namespace's nodes matching filter, ordered by
+// pubkey. Each item holds all stored properties of the node.
+func ListNodes(driver neo4j.Driver, namespace string, filter ListFilter) (*Page, error) {
+	key, err := decodeCursor(filter.Cursor, 1)
+	if err != nil {
+		return nil, err
+	}
+	params := filterParams(namespace, filter)
+	params["after"] = key[0]
+	params["peersOnly"] = filter.PeersOnly
+	params["connectivity"] = filter.Connectivity
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.pubkey > $after
+			AND coalesce(n.total_capacity, 0) >= $minCapacity
+			AND coalesce(n.last_update, 0) >= $since
+			AND all(bit IN $features WHERE bit IN coalesce(n.features, []))
+			AND (NOT $peersOnly OR coalesce(n.is_peer, false))
+			AND ($connectivity = '' OR n.connectivity = $connectivity)
+		RETURN properties(n) AS props
+		ORDER BY n.pubkey
+		LIMIT $limit
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	page := &Page{Items: make([]map[string]interface{}, 0, min(len(records), filter.Limit))}
+	for _, record := range records[:min(len(records), filter.Limit)] {
+		props, _ := record.Get("props")
+		node, _ := props.(map[string]interface{})
+		page.Items = append(page.Items, node)
+	}
+	if len(records) > filter.Limit {
+		last, _ := page.Items[len(page.Items)-1]["pubkey"].(string)
+		page.NextCursor = encodeCursor(last)
+	}
+	return page, nil
+}
+
+// ListEdges returns a page of a namespace's channel directions matching
+// filter, ordered by channel ID and then advertising node. Each item holds
+// all stored properties of the edge plus from and to, the pubkeys of the
+// advertising and the receiving node. Channels still being opened are left
+// out, since gossip has not confirmed them.
+func ListEdges(driver neo4j.Driver, namespace string, filter ListFilter) (*Page, error) {
+	key, err := decodeCursor(filter.Cursor, 2)
+	if err != nil {
+		return nil, err
+	}
+	params := filterParams(namespace, filter)
+	params["afterChannel"], params["afterFrom"] = key[0], key[1]
+	params["enabledOnly"] = filter.EnabledOnly
+	records, err := collectRecords(driver, `
+		MATCH (a:node {namespace: $namespace})-[r:edge]->(b:node)
+		WHERE (r.channel_id > $afterChannel OR (r.channel_id = $afterChannel AND a.pubkey > $afterFrom))
+			AND coalesce(r.capacity, 0) >= $minCapacity
+			AND coalesce(r.last_update, 0) >= $since
+			AND coalesce(r.pending, '') <> 'opening'
+			AND (NOT $enabledOnly OR NOT coalesce(r.disabled, false))
+			AND all(bit IN $features WHERE bit IN coalesce(a.features, []))
+		RETURN a.pubkey AS from, b.pubkey AS to, properties(r) AS props
+		ORDER BY r.channel_id, a.pubkey
+		LIMIT $limit
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edges: %w", err)
+	}
+
+	page := &Page{Items: make([]map[string]interface{}, 0, min(len(records), filter.Limit))}
+	for _, record := range records[:min(len(records), filter.Limit)] {
+		values := recordMap(record)
+		edge, _ := values["props"].(map[string]interface{})
+		if edge == nil {
+			edge = map[string]interface{}{}
+		}
+		edge["from"], edge["to"] = values["from"], values["to"]
+		page.Items = append(page.Items, edge)
+	}
+	if len(records) > filter.Limit {
+		last := page.Items[len(page.Items)-1]
+		channelID, _ := last["channel_id"].(string)
+		from, _ := last["from"].(string)
+		page.NextCursor = encodeCursor(channelID, from)
+	}
+	return page, nil
+}
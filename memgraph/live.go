@@ -0,0 +1,46 @@
+package memgraph
+
+import (
+	"sort"
+	"sync"
+)
+
+// liveNamespaces counts the update streams currently writing into each
+// namespace. Maintenance that only makes sense for a graph kept current by
+// gossip, such as liveness decay and stale pruning, is limited to these, so
+// that archived datasets and snapshots are left as they were loaded.
+var (
+	liveMu         sync.Mutex
+	liveNamespaces = map[string]int{}
+)
+
+// StartLive records that an update stream writes into namespace until the
+// returned function is called.
+func StartLive(namespace string) (done func()) {
+	liveMu.Lock()
+	liveNamespaces[namespace]++
+	liveMu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			liveMu.Lock()
+			defer liveMu.Unlock()
+			if liveNamespaces[namespace]--; liveNamespaces[namespace] <= 0 {
+				delete(liveNamespaces, namespace)
+			}
+		})
+	}
+}
+
+// LiveNamespaces returns the namespaces update streams currently write
+// into, sorted.
+func LiveNamespaces() []string {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	namespaces := make([]string, 0, len(liveNamespaces))
+	for namespace := range liveNamespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
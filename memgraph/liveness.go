@@ -0,0 +1,89 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// livenessWindow is the decay constant, in seconds, for gossip-based liveness.
+// Nodes are expected to re-announce themselves or their channels at least
+// every two weeks, so a node silent for that long has its score reduced by ~63%.
+const livenessWindow = 14 * 24 * 60 * 60
+
+// decayedRate returns the Cypher expression for the gossip rate of the node
+// bound to variable v decayed to $now: an exponentially decayed count of its
+// announcements.
+func decayedRate(v string) string {
+	return "coalesce(" + v + ".gossip_rate, 0.0) * exp(-($now - coalesce(" + v + ".last_seen, $now)) / toFloat($livenessWindow))"
+}
+
+// livenessScore is the Cypher expression for the liveness score of a node
+// with the given gossip rate: 1 - e^-rate, in [0, 1).
+func livenessScore(rate string) string {
+	return "1 - exp(-(" + rate + "))"
+}
+
+// touchClause returns a SET clause recording that gossip from the node bound
+// to variable v was just seen. It maintains:
+//   - last_seen: unix time of the most recent announcement or channel update
+//   - gossip_count: total announcements seen
+//   - gossip_rate: an exponentially decayed announcement count
+//   - liveness_score: the score of gossip_rate
+//
+// Every item is computed from the values before the update. The query needs
+// the $now and $livenessWindow parameters set by livenessParams.
+func touchClause(v string) string {
+	rate := decayedRate(v)
+	return "SET " + v + ".liveness_score = " + livenessScore(rate+" + 1") + ",\n" +
+		v + ".gossip_rate = " + rate + " + 1,\n" +
+		v + ".gossip_count = coalesce(" + v + ".gossip_count, 0) + 1,\n" +
+		v + ".last_seen = $now"
+}
+
+// livenessParams adds the parameters of touchClause to params.
+func livenessParams(params map[string]interface{}) map[string]interface{} {
+	params["now"] = time.Now().Unix()
+	params["livenessWindow"] = livenessWindow
+	return params
+}
+
+// RefreshLiveness decays the liveness_score of every node of a namespace by
+// the time since it was last seen, so silent nodes drift towards zero even
+// without new gossip. It uses the same decay as touchClause, so a node's
+// score only changes with its gossip, not with how often this runs.
+func RefreshLiveness(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	query := `
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.last_seen IS NOT NULL
+		SET n.liveness_score = ` + livenessScore(decayedRate("n"))
+	params := livenessParams(map[string]interface{}{"namespace": namespace})
+	if _, err := CommitQuery(ctx, driver, query, params); err != nil {
+		return fmt.Errorf("failed to refresh liveness: %w", err)
+	}
+	return nil
+}
+
+// RunLivenessRefresh calls RefreshLiveness for every live namespace every
+// interval until stop is closed.
+func RunLivenessRefresh(driver neo4j.Driver, interval time.Duration, stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, namespace := range LiveNamespaces() {
+				if err := RefreshLiveness(ctx, driver, namespace); err != nil {
+					log.Printf("Liveness refresh of %q failed: %v", namespace, err)
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
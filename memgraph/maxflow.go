@@ -0,0 +1,175 @@
+package memgraph
+
+import (
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// CutChannel is a channel direction in the minimum cut of a max-flow result.
+type CutChannel struct {
+	ChannelID string `json:"channel_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Capacity  int64  `json:"capacity"`
+}
+
+// MaxFlowResult is the result of MaxFlow. MinCut lists the saturated channel
+// directions that together limit the flow.
+type MaxFlowResult struct {
+	Source      string       `json:"source"`
+	Destination string       `json:"destination"`
+	MaxFlowSat  int64        `json:"max_flow_sat"`
+	MinCut      []CutChannel `json:"min_cut"`
+}
+
+// flowArc is an arc of the residual network. Each arc's reverse is stored at
+// index arc^1 of the same slice.
+type flowArc struct {
+	to       int
+	residual int64
+	edge     *channelEdge
+}
+
+// flowNetwork is the residual network used by Dinic's algorithm.
+type flowNetwork struct {
+	arcs  []flowArc
+	adj   [][]int
+	level []int
+	next  []int
+}
+
+// addArc adds an arc and its zero-capacity reverse.
+func (n *flowNetwork) addArc(from, to int, capacity int64, edge *channelEdge) {
+	n.adj[from] = append(n.adj[from], len(n.arcs))
+	n.arcs = append(n.arcs, flowArc{to: to, residual: capacity, edge: edge})
+	n.adj[to] = append(n.adj[to], len(n.arcs))
+	n.arcs = append(n.arcs, flowArc{to: from})
+}
+
+// levels computes BFS levels over arcs with residual capacity and reports
+// whether sink is reachable.
+func (n *flowNetwork) levels(source, sink int) bool {
+	for i := range n.level {
+		n.level[i] = -1
+	}
+	n.level[source] = 0
+	queue := []int{source}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, a := range n.adj[v] {
+			arc := n.arcs[a]
+			if arc.residual > 0 && n.level[arc.to] < 0 {
+				n.level[arc.to] = n.level[v] + 1
+				queue = append(queue, arc.to)
+			}
+		}
+	}
+	return n.level[sink] >= 0
+}
+
+// push sends up to limit along level-increasing arcs from v to sink and
+// returns how much was sent.
+func (n *flowNetwork) push(v, sink int, limit int64) int64 {
+	if v == sink {
+		return limit
+	}
+	for ; n.next[v] < len(n.adj[v]); n.next[v]++ {
+		a := n.adj[v][n.next[v]]
+		arc := &n.arcs[a]
+		if arc.residual <= 0 || n.level[arc.to] != n.level[v]+1 {
+			continue
+		}
+		if sent := n.push(arc.to, sink, min(limit, arc.residual)); sent > 0 {
+			arc.residual -= sent
+			n.arcs[a^1].residual += sent
+			return sent
+		}
+	}
+	return 0
+}
+
+// MaxFlow computes the maximum flow in sats from source to destination over
+// the directed channel graph of a namespace, using each enabled direction's
+// capacity as its bound. Both directions of a channel are bounded by the full
+// capacity, so the result is an upper bound on what could be pushed between
+// the nodes, not an estimate of available liquidity. Returns ErrNotFound if
+// either node has no channels.
+func MaxFlow(driver neo4j.Driver, namespace, source, destination string) (*MaxFlowResult, error) {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !g.hasNode(source) || !g.hasNode(destination) {
+		return nil, fmt.Errorf("%w: source or destination has no channels", ErrNotFound)
+	}
+	return maxFlow(g, source, destination), nil
+}
+
+// maxFlow runs Dinic's algorithm from source to destination, both nodes of
+// g, and finds the minimum cut.
+func maxFlow(g *channelGraph, source, destination string) *MaxFlowResult {
+	index := map[string]int{}
+	nodeIndex := func(pubKey string) int {
+		i, ok := index[pubKey]
+		if !ok {
+			i = len(index)
+			index[pubKey] = i
+		}
+		return i
+	}
+	for pubKey := range g.outgoing {
+		nodeIndex(pubKey)
+	}
+	for pubKey := range g.incoming {
+		nodeIndex(pubKey)
+	}
+	network := &flowNetwork{
+		adj:   make([][]int, len(index)),
+		level: make([]int, len(index)),
+		next:  make([]int, len(index)),
+	}
+	for from, edges := range g.outgoing {
+		for _, e := range edges {
+			if !e.Disabled && e.Capacity > 0 {
+				network.addArc(index[from], index[e.To], e.Capacity, e)
+			}
+		}
+	}
+
+	s, t := index[source], index[destination]
+	result := &MaxFlowResult{Source: source, Destination: destination, MinCut: []CutChannel{}}
+	for network.levels(s, t) {
+		for i := range network.next {
+			network.next[i] = 0
+		}
+		for {
+			sent := network.push(s, t, 1<<62)
+			if sent == 0 {
+				break
+			}
+			result.MaxFlowSat += sent
+		}
+	}
+
+	// After the last BFS, level marks the nodes still reachable from the
+	// source; saturated arcs leaving that set form the minimum cut.
+	for v, arcs := range network.adj {
+		if network.level[v] < 0 {
+			continue
+		}
+		for _, a := range arcs {
+			arc := network.arcs[a]
+			if arc.edge != nil && network.level[arc.to] < 0 {
+				result.MinCut = append(result.MinCut, CutChannel{
+					ChannelID: arc.edge.ChannelID,
+					From:      arc.edge.From,
+					To:        arc.edge.To,
+					Capacity:  arc.edge.Capacity,
+				})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package memgraph
+
+import (
+	"sort"
+	"testing"
+)
+
+// newTestGraph builds a channelGraph from channel directions.
+func newTestGraph(edges ...channelEdge) *channelGraph {
+	g := &channelGraph{incoming: map[string][]*channelEdge{}, outgoing: map[string][]*channelEdge{}}
+	for i := range edges {
+		e := &edges[i]
+		g.incoming[e.To] = append(g.incoming[e.To], e)
+		g.outgoing[e.From] = append(g.outgoing[e.From], e)
+	}
+	return g
+}
+
+// arc returns an enabled channel direction.
+func arc(id, from, to string, capacity int64) channelEdge {
+	return channelEdge{ChannelID: id, From: from, To: to, Capacity: capacity}
+}
+
+// channel returns both directions of a channel.
+func channel(id, a, b string, capacity int64) []channelEdge {
+	return []channelEdge{arc(id, a, b, capacity), arc(id, b, a, capacity)}
+}
+
+func TestMaxFlow(t *testing.T) {
+	disabled := arc("d", "s", "t", 100)
+	disabled.Disabled = true
+	tests := []struct {
+		name  string
+		edges []channelEdge
+		flow  int64
+		cut   []string
+	}{
+		{
+			name:  "single path limited by its smallest hop",
+			edges: []channelEdge{arc("sa", "s", "a", 5), arc("at", "a", "t", 3)},
+			flow:  3,
+			cut:   []string{"at"},
+		},
+		{
+			name:  "parallel paths add up",
+			edges: []channelEdge{arc("sa", "s", "a", 4), arc("at", "a", "t", 5), arc("sb", "s", "b", 6), arc("bt", "b", "t", 9)},
+			flow:  10,
+			cut:   []string{"sa", "sb"},
+		},
+		{
+			// The textbook network whose maximum needs flow to be pushed
+			// back over an arc already used.
+			name: "needs reverse arcs",
+			edges: []channelEdge{
+				arc("s1", "s", "v1", 16), arc("s2", "s", "v2", 13), arc("21", "v2", "v1", 4),
+				arc("13", "v1", "v3", 12), arc("32", "v3", "v2", 9), arc("24", "v2", "v4", 14),
+				arc("43", "v4", "v3", 7), arc("3t", "v3", "t", 20), arc("4t", "v4", "t", 4),
+			},
+			flow: 23,
+			cut:  []string{"13", "43", "4t"},
+		},
+		{
+			name:  "disabled directions carry nothing",
+			edges: []channelEdge{disabled, arc("sa", "s", "a", 5), arc("at", "a", "t", 2)},
+			flow:  2,
+			cut:   []string{"at"},
+		},
+		{
+			name:  "direction matters",
+			edges: []channelEdge{arc("ts", "t", "s", 10)},
+			flow:  0,
+			cut:   []string{},
+		},
+		{
+			name:  "parallel channels between two nodes",
+			edges: append(append(channel("c1", "s", "t", 3), channel("c2", "s", "t", 4)...), channel("c3", "t", "x", 1)...),
+			flow:  7,
+			cut:   []string{"c1", "c2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := maxFlow(newTestGraph(test.edges...), "s", "t")
+			if result.MaxFlowSat != test.flow {
+				t.Errorf("max flow = %d, want %d", result.MaxFlowSat, test.flow)
+			}
+			var cut []string
+			var cutCapacity int64
+			for _, c := range result.MinCut {
+				cut = append(cut, c.ChannelID)
+				cutCapacity += c.Capacity
+			}
+			sort.Strings(cut)
+			if len(cut) != len(test.cut) {
+				t.Fatalf("min cut = %v, want %v", cut, test.cut)
+			}
+			for i := range cut {
+				if cut[i] != test.cut[i] {
+					t.Fatalf("min cut = %v, want %v", cut, test.cut)
+				}
+			}
+			if cutCapacity != result.MaxFlowSat {
+				t.Errorf("min cut capacity %d differs from max flow %d", cutCapacity, result.MaxFlowSat)
+			}
+		})
+	}
+}
@@ -3,17 +3,36 @@
 package memgraph
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
+	"time"
 
+	"github.com/btcsuite/btcd/wire"
 	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/lnd"
 )
 
 // ConnectNeo4j creates a Neo4j driver using connection details from environment variables.
 // Uses TLS (bolt+ssc) for remote hosts and plain bolt for local/Docker connections.
+// The driver does not connect until used; see WaitForDatabase. It is
+// recreated by CheckDatabase, and by queries failing to connect, so that it
+// recovers from Memgraph restarts.
 func ConnectNeo4j() (neo4j.Driver, error) {
+	driver, err := newDriver()
+	if err != nil {
+		return nil, err
+	}
+	return &resilientDriver{current: &trackedDriver{Driver: driver}, connect: newDriver}, nil
+}
+
+// newDriver creates a Neo4j driver from the environment.
+func newDriver() (neo4j.Driver, error) {
 	host := os.Getenv("NEO4J_HOST")
 	port := os.Getenv("NEO4J_PORT")
 	scheme := "bolt://"
@@ -33,155 +52,510 @@ func ConnectNeo4j() (neo4j.Driver, error) {
 	return driver, nil
 }
 
+// stopContext returns a context that is cancelled when stop is closed, so
+// that loops driven by a stop channel can pass it to the queries they run.
+func stopContext(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // CloseDriver closes the Neo4j driver connection.
 func CloseDriver(driver neo4j.Driver) {
 	driver.Close()
 }
 
-// DropDatabase removes all nodes, relationships, and indexes from the database.
-// Index drop failures are logged but not returned since the indexes may not exist.
-func DropDatabase(neo4jDriver neo4j.Driver) error {
-	log.Println("Dropping database...")
+// DropNamespace removes the graph of a namespace: its nodes, their channels
+// and its graph_meta node. Other namespaces, the shared indexes, and
+// bookkeeping such as watches are left untouched.
+func DropNamespace(ctx context.Context, neo4jDriver neo4j.Driver, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("Dropping namespace %q...", namespace)
 	session := neo4jDriver.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
-	_, err := session.Run("MATCH (n) DETACH DELETE n", nil)
+	_, err := session.Run("MATCH (n {namespace: $namespace}) WHERE n:node OR n:graph_meta DETACH DELETE n",
+		map[string]interface{}{"namespace": namespace})
 	if err != nil {
-		return fmt.Errorf("failed to drop database: %w", err)
+		return fmt.Errorf("failed to drop namespace: %w", err)
 	}
+	return nil
+}
 
-	_, err = session.Run("DROP INDEX ON :node(pubkey)", nil)
-	if err != nil {
-		log.Printf("Failed to drop index on pubkey property: %v", err)
+// MigrateNamespace assigns namespace to nodes and edges written before
+// namespaces existed, so that existing databases keep working after upgrading.
+func MigrateNamespace(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	queries := []string{
+		"MATCH (n) WHERE (n:node OR n:graph_meta) AND n.namespace IS NULL SET n.namespace = $namespace",
+		"MATCH ()-[r]->() WHERE r.namespace IS NULL SET r.namespace = $namespace",
 	}
-
-	_, err = session.Run("DROP INDEX ON :edge(channel_id)", nil)
-	if err != nil {
-		log.Printf("Failed to drop index on channel_id property: %v", err)
+	for _, query := range queries {
+		if _, err := CommitQuery(ctx, driver, query, map[string]interface{}{"namespace": namespace}); err != nil {
+			return fmt.Errorf("failed to migrate to namespace %q: %w", namespace, err)
+		}
 	}
-
 	return nil
 }
 
 // CommitQuery executes a single parameterized Cypher query against Memgraph.
-func CommitQuery(driver neo4j.Driver, query string, params map[string]interface{}) (neo4j.Result, error) {
-	session := driver.NewSession(neo4j.SessionConfig{})
-	defer session.Close()
-	result, err := session.Run(query, params)
+// The query is not started if ctx is done. The driver cannot interrupt a
+// query once it is sent, so callers running several queries stop at the next
+// one after cancellation. A query that fails to connect, e.g. after Memgraph
+// restarted, is retried once on a recreated driver, so it must be safe to
+// run twice; use commitOnce for queries that create nodes or add to
+// counters.
+func CommitQuery(ctx context.Context, driver neo4j.Driver, query string, params map[string]interface{}) (neo4j.Result, error) {
+	return commitQuery(ctx, driver, query, params, true)
+}
+
+// commitOnce is CommitQuery without the retry, for queries that are not
+// idempotent. A connectivity error may leave it unknown whether they ran.
+func commitOnce(ctx context.Context, driver neo4j.Driver, query string, params map[string]interface{}) (neo4j.Result, error) {
+	return commitQuery(ctx, driver, query, params, false)
+}
+
+// commitQuery runs a write query, retrying it on a recreated driver after a
+// connectivity error if retry is set.
+func commitQuery(ctx context.Context, driver neo4j.Driver, query string, params map[string]interface{}, retry bool) (neo4j.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := runQuery(driver, query, params)
+	if err != nil && reconnectOnError(driver, err) && retry {
+		result, err = runQuery(driver, query, params)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	return result, nil
 }
 
+// runQuery runs a write query in a session of its own.
+func runQuery(driver neo4j.Driver, query string, params map[string]interface{}) (neo4j.Result, error) {
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	return session.Run(query, params)
+}
+
+// channelID formats a short channel ID the same way the bulk writers do
+// (block x index x output), so that updates land on the imported edges.
+func channelID(id lnwire.ShortChannelID) string {
+	return fmt.Sprintf("%dx%dx%d", id.BlockHeight, id.TxIndex, id.TxPosition)
+}
+
+// chanPoint formats a channel's funding outpoint as txid:index, or returns ""
+// if it is unknown, as in updates learned over P2P.
+func chanPoint(outpoint wire.OutPoint) string {
+	if outpoint == (wire.OutPoint{}) {
+		return ""
+	}
+	return outpoint.String()
+}
+
 // ProcessNodeUpdate converts an LND node update into a Cypher MERGE query
-// that creates or updates the node in Memgraph.
-func ProcessNodeUpdate(nodeUpdate lndclient.NodeUpdate) (string, map[string]interface{}) {
-	nodeQuery := "MERGE (n:node {pubkey: $pubKey})\nSET n.alias = $alias"
-	params := map[string]interface{}{
-		"pubKey": nodeUpdate.IdentityKey.String(),
-		"alias":  nodeUpdate.Alias,
+// that creates or updates the node in the given namespace. Node updates carry
+// no timestamp, so last_update is set to the time the update was received.
+// Nodes of known entities are tagged as well, so that nodes first seen in
+// gossip carry their tags without waiting for the next import. The same
+// query counts the announcement towards the node's liveness.
+func ProcessNodeUpdate(namespace string, nodeUpdate lndclient.NodeUpdate) (string, map[string]interface{}) {
+	nodeQuery := "MERGE (n:node {pubkey: $pubKey, namespace: $namespace})\n" + touchClause("n") + ",\n" +
+		"n.alias = $alias, n.color = $color, n.addresses = $addresses, n += $addressClass, n.features = $features, n.last_update = $lastUpdate"
+	params := livenessParams(map[string]interface{}{
+		"namespace":    namespace,
+		"pubKey":       nodeUpdate.IdentityKey.String(),
+		"alias":        nodeUpdate.Alias,
+		"color":        nodeUpdate.Color,
+		"addresses":    nodeUpdate.Addresses,
+		"addressClass": lnd.ClassifyAddresses(nodeUpdate.Addresses),
+		"features":     lnd.FeatureBits(nodeUpdate.Features),
+		"lastUpdate":   time.Now().Unix(),
+	})
+	if entity, ok := lookupEntity(nodeUpdate.IdentityKey.String()); ok {
+		nodeQuery += ", n.organization = $organization, n.tags = $tags"
+		params["organization"] = entity.Organization
+		params["tags"] = entity.Tags
 	}
 	return nodeQuery, params
 }
 
 // ProcessEdgeUpdate converts an LND channel edge update into a Cypher query.
-// If the channel is disabled, only the disabled flag is updated. Otherwise,
-// the full edge is created/updated with routing policy details.
-func ProcessEdgeUpdate(edgeUpdate lndclient.ChannelEdgeUpdate) (string, map[string]interface{}) {
+// If the channel is disabled, only the disabled flag of the advertising node's
+// direction is updated. Otherwise, the edge for that direction is created or
+// updated with routing policy details. Edges are keyed on channel_id and
+// direction only, so property changes never create duplicates. An update
+// older than the stored last_update of its direction changes nothing, but
+// still counts towards the advertising node's liveness, like any other.
+func ProcessEdgeUpdate(namespace string, edgeUpdate lndclient.ChannelEdgeUpdate) (string, map[string]interface{}) {
 	var (
 		edgeQuery string
 		params    map[string]interface{}
 	)
 	if edgeUpdate.RoutingPolicy.Disabled {
-		edgeQuery = "MATCH ()-[r:edge {channel_id: $channelID}]->()\nset r.disabled = true"
-		params = map[string]interface{}{
-			"channelID": edgeUpdate.ChannelID.String(),
-		}
+		edgeQuery = "MERGE (n1:node {pubkey: $advertisingNode, namespace: $namespace})\n" + touchClause("n1") + "\n" +
+			"WITH n1 MATCH (n1)-[r:edge {channel_id: $channelID}]->()\n" +
+			"WHERE coalesce(r.last_update, 0) <= $last_update\nSET r.disabled = true, r.last_update = $last_update"
+		params = livenessParams(map[string]interface{}{
+			"namespace":       namespace,
+			"advertisingNode": edgeUpdate.AdvertisingNode.String(),
+			"channelID":       channelID(edgeUpdate.ChannelID),
+			"last_update":     edgeUpdate.RoutingPolicy.LastUpdate.Unix(),
+		})
 	} else {
-		edgeQuery = "MERGE (n1:node {pubkey: $advertisingNode})\nMERGE (n2:node {pubkey: $connectingNode})\n" +
-			"MERGE (n1)-[r:edge {channel_id: $channelID}]->(n2)\n" +
-			"SET r.fee_base_msat = $fee_base_msat, r.fee_rate_milli_msat = $fee_rate_milli_msat, r.time_lock_delta = $time_lock_delta, r.disabled = $disabled"
-		params = map[string]interface{}{
+		edgeQuery = "MERGE (n1:node {pubkey: $advertisingNode, namespace: $namespace})\nMERGE (n2:node {pubkey: $connectingNode, namespace: $namespace})\n" +
+			"MERGE (n1)-[r:edge {channel_id: $channelID}]->(n2)\n" + touchClause("n1") + "\n" +
+			"WITH r WHERE coalesce(r.last_update, 0) <= $last_update\n" +
+			"SET r.namespace = $namespace, r.scid = $scid, r.block_height = $block_height,\n" +
+			"r.chan_point = CASE WHEN $chan_point <> '' THEN $chan_point ELSE r.chan_point END,\n" +
+			"r.capacity = CASE WHEN $capacity > 0 THEN $capacity ELSE r.capacity END,\n" +
+			"r.fee_base_msat = $fee_base_msat, r.fee_rate_milli_msat = $fee_rate_milli_msat, r.time_lock_delta = $time_lock_delta, r.disabled = $disabled, r.last_update = $last_update"
+		params = livenessParams(map[string]interface{}{
+			"namespace":           namespace,
 			"advertisingNode":     edgeUpdate.AdvertisingNode.String(),
 			"connectingNode":      edgeUpdate.ConnectingNode.String(),
-			"channelID":           edgeUpdate.ChannelID.String(),
-			"capacity":            edgeUpdate.Capacity,
+			"channelID":           channelID(edgeUpdate.ChannelID),
+			"chan_point":          chanPoint(edgeUpdate.ChannelPoint),
+			"scid":                lnd.SCID(edgeUpdate.ChannelID.ToUint64()),
+			"block_height":        lnd.BlockHeight(edgeUpdate.ChannelID.ToUint64()),
+			"capacity":            int64(edgeUpdate.Capacity),
 			"fee_base_msat":       edgeUpdate.RoutingPolicy.FeeBaseMsat,
 			"fee_rate_milli_msat": edgeUpdate.RoutingPolicy.FeeRateMilliMsat,
 			"time_lock_delta":     edgeUpdate.RoutingPolicy.TimeLockDelta,
 			"disabled":            edgeUpdate.RoutingPolicy.Disabled,
-		}
+			"last_update":         edgeUpdate.RoutingPolicy.LastUpdate.Unix(),
+		})
 	}
 	return edgeQuery, params
 }
 
 // ProcessCloseUpdate converts an LND channel close event into a Cypher DELETE query
-// that removes the channel edge from Memgraph.
-func ProcessCloseUpdate(closeUpdate lndclient.ChannelCloseUpdate) (string, map[string]interface{}) {
-	closeQuery := "MATCH ()-[r:edge {channel_id: $channelID}]->()\nDELETE r"
+// that removes the channel edge from the given namespace.
+func ProcessCloseUpdate(namespace string, closeUpdate lndclient.ChannelCloseUpdate) (string, map[string]interface{}) {
+	closeQuery := "MATCH ()-[r:edge {channel_id: $channelID, namespace: $namespace}]->()\nDELETE r"
 	params := map[string]interface{}{
-		"channelID": closeUpdate.ChannelID.String(),
+		"namespace": namespace,
+		"channelID": channelID(closeUpdate.ChannelID),
 	}
 	return closeQuery, params
 }
 
+// DeduplicateEdges repairs graphs written before edges were keyed on
+// channel_id and direction alone. It rewrites channel IDs stored in the
+// block:index:output form to the block x index x output form, then keeps only
+// the most recently updated edge per channel direction. Returns the number of
+// duplicates removed.
+func DeduplicateEdges(ctx context.Context, driver neo4j.Driver) (int64, error) {
+	_, err := CommitQuery(ctx, driver, `
+		MATCH ()-[r:edge]->()
+		WHERE r.channel_id CONTAINS ':'
+		SET r.channel_id = replace(r.channel_id, ':', 'x')
+	`, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to normalize channel ids: %w", err)
+	}
+	removed, err := countQuery(driver, `
+		MATCH (a)-[r:edge]->(b)
+		WITH a, b, r ORDER BY coalesce(r.last_update, 0) DESC
+		WITH a, b, r.channel_id AS channel_id, collect(r) AS edges
+		WHERE size(edges) > 1
+		UNWIND edges[1..] AS duplicate
+		DELETE duplicate
+		RETURN count(*) AS count
+	`, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove duplicate edges: %w", err)
+	}
+	return removed, nil
+}
+
+// BackfillChannelIDs derives the numeric scid and block_height properties
+// from channel_id for edges written before they were stored. Like
+// lnd.SCID and lnd.BlockHeight, it leaves scid unset for heights that would
+// overflow it and block_height unset for alias SCIDs.
+func BackfillChannelIDs(ctx context.Context, driver neo4j.Driver) error {
+	_, err := CommitQuery(ctx, driver, `
+		MATCH ()-[r:edge]->()
+		WHERE r.scid IS NULL AND r.channel_id IS NOT NULL
+		WITH r, split(r.channel_id, 'x') AS parts
+		WHERE size(parts) = 3
+		WITH r, toInteger(parts[0]) AS height, parts
+		SET r.block_height = CASE WHEN height >= $aliasStart AND height < $aliasEnd THEN null ELSE height END,
+			r.scid = CASE WHEN height < $scidHeights
+				THEN height * 1099511627776 + toInteger(parts[1]) * 65536 + toInteger(parts[2]) END
+	`, map[string]interface{}{
+		"aliasStart": lnd.AliasStartHeight,
+		"aliasEnd":   lnd.AliasEndHeight,
+		// Heights from 2^23 on put the scid above the int64 range.
+		"scidHeights": 1 << 23,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill channel ids: %w", err)
+	}
+	return nil
+}
+
+var (
+	// appliedCount and appliedAt (unix nanoseconds) track every update
+	// applied by ProcessUpdates since the process started, whatever its source.
+	appliedCount atomic.Int64
+	appliedAt    atomic.Int64
+
+	// staleCount counts the channel updates skipped because the stored
+	// policy was newer.
+	staleCount atomic.Int64
+)
+
+// StaleUpdates returns how many channel updates have been skipped since
+// startup because they were older than the stored policy.
+func StaleUpdates() int64 {
+	return staleCount.Load()
+}
+
+// dropStaleUpdates removes the channel updates from a batch whose gossip
+// timestamp is older than the stored last_update of their direction, e.g.
+// replayed or out-of-order gossip, and counts them. Node updates carry no
+// timestamp and are always kept. On a read error the batch is returned
+// unchanged; ProcessEdgeUpdate still refuses to overwrite newer policies.
+func dropStaleUpdates(driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) *lndclient.GraphTopologyUpdate {
+	if len(update.ChannelEdgeUpdates) == 0 {
+		return update
+	}
+	rows := make([]map[string]interface{}, 0, len(update.ChannelEdgeUpdates))
+	for i, edgeUpdate := range update.ChannelEdgeUpdates {
+		rows = append(rows, map[string]interface{}{
+			"i":          i,
+			"node":       edgeUpdate.AdvertisingNode.String(),
+			"channelID":  channelID(edgeUpdate.ChannelID),
+			"lastUpdate": edgeUpdate.RoutingPolicy.LastUpdate.Unix(),
+		})
+	}
+	records, err := collectRecords(driver, `
+		UNWIND $rows AS row
+		MATCH (:node {pubkey: row.node, namespace: $namespace})-[r:edge {channel_id: row.channelID}]->()
+		WHERE r.last_update > row.lastUpdate
+		RETURN row.i AS i
+	`, map[string]interface{}{"rows": rows, "namespace": namespace})
+	if err != nil {
+		log.Printf("Failed to check for stale updates: %v", err)
+		return update
+	}
+	if len(records) == 0 {
+		return update
+	}
+
+	stale := map[int64]bool{}
+	for _, record := range records {
+		if i, ok := recordMap(record)["i"].(int64); ok {
+			stale[i] = true
+		}
+	}
+	fresh := *update
+	fresh.ChannelEdgeUpdates = make([]lndclient.ChannelEdgeUpdate, 0, len(update.ChannelEdgeUpdates)-len(stale))
+	for i, edgeUpdate := range update.ChannelEdgeUpdates {
+		if !stale[int64(i)] {
+			fresh.ChannelEdgeUpdates = append(fresh.ChannelEdgeUpdates, edgeUpdate)
+		}
+	}
+	staleCount.Add(int64(len(stale)))
+	return &fresh
+}
+
+// AppliedUpdates returns how many updates ProcessUpdates has applied since
+// startup and when the last one was applied (zero if none).
+func AppliedUpdates() (int64, time.Time) {
+	var last time.Time
+	if at := appliedAt.Load(); at != 0 {
+		last = time.Unix(0, at)
+	}
+	return appliedCount.Load(), last
+}
+
+// CountGraph returns the number of nodes and distinct channels in a namespace.
+func CountGraph(driver neo4j.Driver, namespace string) (nodes, channels int64, err error) {
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})
+		WITH count(n) AS nodes
+		OPTIONAL MATCH ()-[r:edge {namespace: $namespace}]->()
+		WHERE `+openChannel("r")+`
+		RETURN nodes, count(DISTINCT r.channel_id) AS channels
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count graph: %w", err)
+	}
+	if len(records) == 0 {
+		return 0, 0, nil
+	}
+	n, _ := records[0].Get("nodes")
+	c, _ := records[0].Get("channels")
+	nodes, _ = n.(int64)
+	channels, _ = c.(int64)
+	return nodes, channels, nil
+}
+
 // ProcessUpdates applies a batch of graph topology updates (node changes,
-// channel opens/updates, and channel closes) to Memgraph.
-func ProcessUpdates(driver neo4j.Driver, update *lndclient.GraphTopologyUpdate) {
+// channel opens/updates, and channel closes) to a namespace. Node announcements
+// and channel updates also count towards the announcing node's liveness.
+// Policy changes and channel closes are journaled, changes affecting watched
+// nodes are recorded, and nodes whose derived metrics go stale are marked
+// first. Channel updates older than the stored policy are skipped (see
+// StaleUpdates). Once ctx is done the rest of the batch is dropped.
+func ProcessUpdates(ctx context.Context, driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) {
+	update = dropStaleUpdates(driver, namespace, update)
+	recordPolicyChanges(ctx, driver, namespace, update)
+	recordChannelCloses(ctx, driver, namespace, update)
+	recordWatchEvents(ctx, driver, namespace, update)
+	markAffected(driver, namespace, update)
+
 	for _, nodeUpdate := range update.NodeUpdates {
-		nodeQuery, nodeParams := ProcessNodeUpdate(nodeUpdate)
-		_, err := CommitQuery(driver, nodeQuery, nodeParams)
+		if ctx.Err() != nil {
+			return
+		}
+		// Not retried, since the query adds to the node's gossip counts.
+		nodeQuery, nodeParams := ProcessNodeUpdate(namespace, nodeUpdate)
+		_, err := commitOnce(ctx, driver, nodeQuery, nodeParams)
 		if err != nil {
 			log.Printf("Failed to commit node query: %v", err)
 		}
 	}
 
 	for _, edgeUpdate := range update.ChannelEdgeUpdates {
-		edgeQuery, edgeParams := ProcessEdgeUpdate(edgeUpdate)
-		_, err := CommitQuery(driver, edgeQuery, edgeParams)
+		if ctx.Err() != nil {
+			return
+		}
+		// Not retried, like node updates.
+		edgeQuery, edgeParams := ProcessEdgeUpdate(namespace, edgeUpdate)
+		_, err := commitOnce(ctx, driver, edgeQuery, edgeParams)
 		if err != nil {
 			log.Printf("Failed to commit edge query: %v", err)
 		}
 	}
 
 	for _, closeUpdate := range update.ChannelCloseUpdates {
-		closeQuery, closeParams := ProcessCloseUpdate(closeUpdate)
-		_, err := CommitQuery(driver, closeQuery, closeParams)
+		if ctx.Err() != nil {
+			return
+		}
+		closeQuery, closeParams := ProcessCloseUpdate(namespace, closeUpdate)
+		_, err := CommitQuery(ctx, driver, closeQuery, closeParams)
 		if err != nil {
 			log.Printf("Failed to commit close query: %v", err)
 		}
 	}
+
+	appliedCount.Add(int64(len(update.NodeUpdates) + len(update.ChannelEdgeUpdates) + len(update.ChannelCloseUpdates)))
+	appliedAt.Store(time.Now().UnixNano())
 }
 
-// SetupAfterImport runs post-import computations on the graph:
+// SetupAfterImport runs post-import computations on one namespace's graph:
+//   - Marks the local node's closed channels (see MarkClosedChannels), which
+//     the computations below skip
 //   - Converts fee_base_msat to milli-msat denomination
 //   - Calculates total capacity per node
-//   - Computes betweenness centrality for nodes (via Memgraph MAGE)
+//   - Computes betweenness centrality for nodes (via Memgraph MAGE, on the
+//     namespace's subgraph only, or estimated from BetweennessSamples sources)
 //   - Averages node centrality onto edges
-func SetupAfterImport(neo4jDriver neo4j.Driver) error {
+//   - Tags articulation points and bridges (see TagCriticalElements)
+//   - Tags the nodes of known entities (see LoadEntities)
+//   - Stores channel ages (see SetChannelAges)
+//   - Stores BTC and fiat equivalents of capacities (see SetCapacityValues)
+//   - Overlays the local node's channel balances (see OverlayOwnChannels)
+//   - Marks the local node's current peers (see MarkPeers)
+//   - Shows the local node's pending channels (see SyncPendingChannels)
+//
+// Steps are not started once ctx is done.
+func SetupAfterImport(ctx context.Context, neo4jDriver neo4j.Driver, namespace string) error {
 	log.Println("Running post-import setup...")
 	session := neo4jDriver.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
+	// Closed channels are marked first, so that the metrics below skip them.
+	if n, err := MarkClosedChannels(ctx, neo4jDriver, namespace); err != nil {
+		log.Printf("Skipping closed channels: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d closed channels", n)
+	}
+
+	// Steps with run set are computed outside a single query.
 	queries := []struct {
 		desc  string
 		query string
+		run   func() error
 	}{
-		{"fix fee denominations", "match (n)-[r]->(m)\nset r.fee_base_milli_msat = r.fee_base_msat*1000"},
-		{"initialize node capacity", "match (n)\nset n.total_capacity = 0;\n"},
-		{"calculate node capacity", "MATCH (n)-[r]-(m)\nWITH n,sum(r.capacity) as total_capacity\nSET n.total_capacity = total_capacity/2;"},
-		{"calculate node betweenness centrality", "call betweenness_centrality.get() YIELD betweenness_centrality, node \nwith betweenness_centrality,node\nset node.betweenness_centrality = betweenness_centrality;"},
-		{"calculate edge betweenness centrality", "MATCH (n)-[r]-(m)\nset r.betweenness_centrality = (n.betweenness_centrality+m.betweenness_centrality)/2;"},
+		{desc: "fix fee denominations", query: "match (n {namespace: $namespace})-[r]->(m)\nset r.fee_base_milli_msat = r.fee_base_msat*1000"},
+		{desc: "initialize node capacity", query: "match (n:node {namespace: $namespace})\nset n.total_capacity = 0;\n"},
+		{desc: "calculate node capacity", query: "MATCH (n:node {namespace: $namespace})-[r:edge]-(m)\nWHERE " + openChannel("r") + "\nWITH n,sum(r.capacity) as total_capacity\nSET n.total_capacity = total_capacity/2;"},
+		{desc: "calculate node betweenness centrality", run: func() error { return setBetweenness(ctx, neo4jDriver, namespace) }},
+		{desc: "calculate edge betweenness centrality", query: edgeBetweennessQuery},
 	}
 
+	params := map[string]interface{}{"namespace": namespace}
 	for _, q := range queries {
-		_, err := session.Run(q.query, nil)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if q.run != nil {
+			err = q.run()
+		} else {
+			_, err = session.Run(q.query, params)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to %s: %w", q.desc, err)
 		}
 	}
+	if err := TagCriticalElements(ctx, neo4jDriver, namespace); err != nil {
+		return fmt.Errorf("failed to tag critical elements: %w", err)
+	}
+	if _, err := TagEntities(ctx, neo4jDriver, namespace); err != nil {
+		return err
+	}
+	if err := SetChannelAges(ctx, neo4jDriver, namespace); err != nil {
+		return err
+	}
+	if err := SetCapacityValues(ctx, neo4jDriver, namespace); err != nil {
+		return err
+	}
+	if n, err := OverlayOwnChannels(ctx, neo4jDriver, namespace); err != nil {
+		log.Printf("Skipping own channel balances: %v", err)
+	} else if n > 0 {
+		log.Printf("Overlaid balances of %d own channels", n)
+	}
+	if n, err := MarkPeers(ctx, neo4jDriver, namespace); err != nil {
+		log.Printf("Skipping peers: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d connected peers", n)
+	}
+	if n, err := SyncPendingChannels(ctx, neo4jDriver, namespace); err != nil {
+		log.Printf("Skipping pending channels: %v", err)
+	} else if n > 0 {
+		log.Printf("Showed %d pending channels", n)
+	}
 
 	log.Println("Post-import setup complete.")
 	return nil
 }
+
+// ErrNotFound is returned when a requested node or channel does not exist.
+var ErrNotFound = errors.New("not found")
+
+// GetNode returns all properties of the node with the given pubkey in a namespace.
+func GetNode(driver neo4j.Driver, namespace, pubKey string) (map[string]interface{}, error) {
+	records, err := collectRecords(driver, "MATCH (n:node {pubkey: $pubKey, namespace: $namespace}) RETURN properties(n) AS props",
+		map[string]interface{}{"pubKey": pubKey, "namespace": namespace})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+	props, _ := records[0].Get("props")
+	node, _ := props.(map[string]interface{})
+	return node, nil
+}
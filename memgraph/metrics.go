@@ -0,0 +1,175 @@
+package memgraph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// lagSamples is how many recent channel updates the lag distribution covers.
+const lagSamples = 1000
+
+// rateWindows are the windows over which applied updates per second are
+// reported.
+var rateWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+}
+
+// Throughput describes how quickly applied updates are reaching Memgraph.
+// Lag is the time between a channel update's gossip timestamp and its commit,
+// in seconds; node announcements carry no timestamp and are not included.
+type Throughput struct {
+	LastLagSeconds float64            `json:"last_lag_seconds"`
+	Lag            Distribution       `json:"lag_seconds"`
+	PerSecond      map[string]float64 `json:"per_second"`
+	LastAppliedAt  time.Time          `json:"last_applied_at"`
+}
+
+// appliedBatch records how many updates were committed at a point in time.
+type appliedBatch struct {
+	at    time.Time
+	count int
+}
+
+// throughputTracker accumulates lag samples and commit times.
+type throughputTracker struct {
+	mu      sync.Mutex
+	lags    []float64
+	next    int
+	lastLag float64
+	batches []appliedBatch
+}
+
+// record notes that update was committed at the given time.
+func (t *throughputTracker) record(update *lndclient.GraphTopologyUpdate, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		lag := at.Sub(edgeUpdate.RoutingPolicy.LastUpdate).Seconds()
+		t.lastLag = lag
+		if len(t.lags) < lagSamples {
+			t.lags = append(t.lags, lag)
+		} else {
+			t.lags[t.next] = lag
+			t.next = (t.next + 1) % lagSamples
+		}
+	}
+
+	count := len(update.NodeUpdates) + len(update.ChannelEdgeUpdates) + len(update.ChannelCloseUpdates)
+	t.batches = append(t.batches, appliedBatch{at: at, count: count})
+	// Forget batches older than the longest window.
+	cutoff := at.Add(-rateWindows[len(rateWindows)-1].duration)
+	i := 0
+	for i < len(t.batches) && t.batches[i].at.Before(cutoff) {
+		i++
+	}
+	t.batches = t.batches[i:]
+}
+
+// snapshot computes the current Throughput.
+func (t *throughputTracker) snapshot(now time.Time) Throughput {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := Throughput{
+		LastLagSeconds: t.lastLag,
+		Lag:            distribution(append([]float64(nil), t.lags...)),
+		PerSecond:      map[string]float64{},
+	}
+	if len(t.batches) > 0 {
+		result.LastAppliedAt = t.batches[len(t.batches)-1].at
+	}
+	for _, window := range rateWindows {
+		cutoff := now.Add(-window.duration)
+		total := 0
+		for _, batch := range t.batches {
+			if !batch.at.Before(cutoff) {
+				total += batch.count
+			}
+		}
+		result.PerSecond[window.name] = float64(total) / window.duration.Seconds()
+	}
+	return result
+}
+
+var (
+	// queuesMu protects queues, the running update queues by source name.
+	queuesMu sync.Mutex
+	queues   = map[string]*UpdateQueue{}
+)
+
+// registerQueue makes a queue visible to WriteMetrics, replacing any earlier
+// queue of the same source.
+func registerQueue(q *UpdateQueue) {
+	queuesMu.Lock()
+	queues[q.source] = q
+	queuesMu.Unlock()
+}
+
+// WriteMetrics writes the counters of every update queue in the Prometheus
+// text exposition format.
+func WriteMetrics(w io.Writer) {
+	queuesMu.Lock()
+	sources := make([]string, 0, len(queues))
+	for source := range queues {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	stats := make([]QueueStats, 0, len(sources))
+	for _, source := range sources {
+		stats = append(stats, queues[source].Stats())
+	}
+	queuesMu.Unlock()
+
+	metric := func(name, kind, help string, value func(s QueueStats) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for i, s := range stats {
+			fmt.Fprintf(w, "%s{source=%q} %g\n", name, sources[i], value(s))
+		}
+	}
+	metric("ln_stream_updates_pending", "gauge", "Updates waiting to be written.",
+		func(s QueueStats) float64 { return float64(s.Pending) })
+	metric("ln_stream_updates_enqueued_total", "counter", "Updates received.",
+		func(s QueueStats) float64 { return float64(s.Enqueued) })
+	metric("ln_stream_updates_coalesced_total", "counter", "Updates replaced by newer ones before being written.",
+		func(s QueueStats) float64 { return float64(s.Coalesced) })
+	metric("ln_stream_updates_applied_total", "counter", "Updates written to Memgraph.",
+		func(s QueueStats) float64 { return float64(s.Applied) })
+
+	up := 0
+	if DatabaseUp() {
+		up = 1
+	}
+	fmt.Fprintf(w, "# HELP ln_stream_database_up Whether Memgraph was reachable at the last health check.\n# TYPE ln_stream_database_up gauge\nln_stream_database_up %d\n", up)
+
+	fmt.Fprintf(w, "# HELP ln_stream_updates_stale_total Channel updates skipped because the stored policy was newer.\n# TYPE ln_stream_updates_stale_total counter\nln_stream_updates_stale_total %d\n", StaleUpdates())
+
+	fmt.Fprintf(w, "# HELP ln_stream_updates_per_second Updates written per second.\n# TYPE ln_stream_updates_per_second gauge\n")
+	for i, s := range stats {
+		for _, window := range rateWindows {
+			fmt.Fprintf(w, "ln_stream_updates_per_second{source=%q,window=%q} %g\n", sources[i], window.name,
+				s.Throughput.PerSecond[window.name])
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP ln_stream_update_lag_seconds Time from gossip timestamp to commit of recent channel updates.\n# TYPE ln_stream_update_lag_seconds summary\n")
+	for i, s := range stats {
+		lag := s.Throughput.Lag
+		for _, q := range []struct {
+			quantile string
+			value    float64
+		}{{"0.5", lag.P50}, {"0.9", lag.P90}, {"0.99", lag.P99}} {
+			fmt.Fprintf(w, "ln_stream_update_lag_seconds{source=%q,quantile=%q} %g\n", sources[i], q.quantile, q.value)
+		}
+		fmt.Fprintf(w, "ln_stream_update_lag_seconds_count{source=%q} %d\n", sources[i], lag.Count)
+	}
+}
@@ -0,0 +1,123 @@
+package memgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// ErrNetworkMismatch is returned when data from one network (e.g. testnet) is
+// about to be written into a graph that belongs to another.
+var ErrNetworkMismatch = errors.New("network mismatch")
+
+// GetNetwork returns the network recorded for a namespace's graph, or "" if
+// none has been recorded yet. The network is stored on one graph_meta node
+// per namespace, which DropNamespace removes along with everything else.
+func GetNetwork(driver neo4j.Driver, namespace string) (string, error) {
+	records, err := collectRecords(driver, "MATCH (m:graph_meta {namespace: $namespace}) RETURN m.network AS network",
+		map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+	network, _ := records[0].Get("network")
+	s, _ := network.(string)
+	return s, nil
+}
+
+// SetNetwork records the network a namespace's graph belongs to.
+func SetNetwork(ctx context.Context, driver neo4j.Driver, namespace, network string) error {
+	_, err := CommitQuery(ctx, driver, "MERGE (m:graph_meta {namespace: $namespace})\nSET m.network = $network",
+		map[string]interface{}{"namespace": namespace, "network": network})
+	if err != nil {
+		return fmt.Errorf("failed to record network: %w", err)
+	}
+	return nil
+}
+
+// EnsureNetwork records network for an unlabeled namespace, or returns
+// ErrNetworkMismatch if the namespace already belongs to a different network.
+func EnsureNetwork(ctx context.Context, driver neo4j.Driver, namespace, network string) error {
+	current, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return SetNetwork(ctx, driver, namespace, network)
+	}
+	if current != network {
+		return fmt.Errorf("%w: namespace %q holds a %s graph, refusing to add %s data", ErrNetworkMismatch, namespace, current, network)
+	}
+	return nil
+}
+
+// Dataset is the graph of one namespace. Network is "" if none has been
+// recorded.
+type Dataset struct {
+	Namespace string `json:"namespace"`
+	Network   string `json:"network"`
+	Nodes     int64  `json:"nodes"`
+	Channels  int64  `json:"channels"`
+}
+
+// ListDatasets returns every namespace that holds nodes or has a recorded
+// network, sorted by name.
+func ListDatasets(driver neo4j.Driver) ([]Dataset, error) {
+	datasets := map[string]*Dataset{}
+	dataset := func(namespace string) *Dataset {
+		if datasets[namespace] == nil {
+			datasets[namespace] = &Dataset{Namespace: namespace}
+		}
+		return datasets[namespace]
+	}
+
+	records, err := collectRecords(driver, "MATCH (m:graph_meta) RETURN m.namespace AS namespace, m.network AS network", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, record := range records {
+		values := recordMap(record)
+		namespace, _ := values["namespace"].(string)
+		dataset(namespace).Network, _ = values["network"].(string)
+	}
+	records, err = collectRecords(driver, "MATCH (n:node) RETURN n.namespace AS namespace, count(n) AS count", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count nodes: %w", err)
+	}
+	for _, record := range records {
+		values := recordMap(record)
+		namespace, _ := values["namespace"].(string)
+		dataset(namespace).Nodes, _ = values["count"].(int64)
+	}
+	records, err = collectRecords(driver,
+		"MATCH (:node)-[r:edge]->(:node) WHERE "+openChannel("r")+" RETURN r.namespace AS namespace, count(DISTINCT r.channel_id) AS count", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count channels: %w", err)
+	}
+	for _, record := range records {
+		values := recordMap(record)
+		namespace, _ := values["namespace"].(string)
+		dataset(namespace).Channels, _ = values["count"].(int64)
+	}
+
+	list := make([]Dataset, 0, len(datasets))
+	for _, d := range datasets {
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Namespace < list[j].Namespace })
+	return list, nil
+}
+
+// HasGraph reports whether any namespace holds graph nodes.
+func HasGraph(driver neo4j.Driver) (bool, error) {
+	records, err := collectRecords(driver, "MATCH (n:node) RETURN n LIMIT 1", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for a graph: %w", err)
+	}
+	return len(records) > 0, nil
+}
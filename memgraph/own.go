@@ -0,0 +1,317 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/lnd"
+)
+
+// OwnChannels, if set, returns the pubkey of the local node and its channels
+// as the node itself reports them, for graphs of the given network. It is
+// set when LND is connected.
+var OwnChannels func(ctx context.Context, network string) (string, []lnd.OwnChannel, error)
+
+// Peers, if set, returns the nodes the local node is currently connected
+// to, for graphs of the given network. It is set when LND is connected.
+var Peers func(ctx context.Context, network string) ([]lnd.Peer, error)
+
+// ClosedChannels, if set, returns the network of the local node and the
+// channels it has closed. It is set when LND is connected.
+var ClosedChannels func(ctx context.Context) (string, []lnd.ClosedChannel, error)
+
+// PendingChannels, if set, returns the pubkey of the local node and its
+// channels waiting to be opened or closed, for graphs of the given network.
+// It is set when LND is connected.
+var PendingChannels func(ctx context.Context, network string) (string, []lnd.PendingChannel, error)
+
+// OverlayOwnChannels stores the local node's view of its channels on both
+// edges of each channel in a namespace: own_local_balance and
+// own_remote_balance (sats), own_pending_htlcs, own_active and
+// own_updated_at. The own_ prefix keeps them apart from gossip-derived
+// properties, which they never overwrite. The local node is marked is_self.
+// Channels the node no longer has lose their own_ properties, and private
+// channels, which have no edges, are skipped. Returns the number of channels
+// overlaid; a namespace of another network is left alone.
+func OverlayOwnChannels(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	if OwnChannels == nil {
+		return 0, nil
+	}
+	network, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return 0, err
+	}
+	self, channels, err := OwnChannels(ctx, network)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(channels))
+	for _, c := range channels {
+		if c.Private {
+			continue
+		}
+		rows = append(rows, map[string]interface{}{
+			"channel_id":     c.ChannelID,
+			"peer":           c.Peer,
+			"local_balance":  c.LocalBalance,
+			"remote_balance": c.RemoteBalance,
+			"pending_htlcs":  c.PendingHTLCs,
+			"active":         c.Active,
+		})
+	}
+	params := map[string]interface{}{"namespace": namespace, "self": self, "rows": rows, "now": time.Now().Unix()}
+
+	_, err = CommitQuery(ctx, driver, `
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.is_self AND n.pubkey <> $self
+		REMOVE n.is_self
+		WITH count(*) AS ignored
+		MATCH (n:node {pubkey: $self, namespace: $namespace})
+		SET n.is_self = true
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark the local node: %w", err)
+	}
+	overlaid, err := countQuery(driver, `
+		UNWIND $rows AS row
+		MATCH (:node {pubkey: $self, namespace: $namespace})-[r:edge {channel_id: row.channel_id}]-(:node {pubkey: row.peer})
+		SET r.own_local_balance = row.local_balance, r.own_remote_balance = row.remote_balance,
+			r.own_pending_htlcs = row.pending_htlcs, r.own_active = row.active, r.own_updated_at = $now
+		RETURN count(DISTINCT row.channel_id) AS count
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to overlay own channels: %w", err)
+	}
+	_, err = CommitQuery(ctx, driver, `
+		MATCH (:node {namespace: $namespace})-[r:edge]->()
+		WHERE r.own_updated_at IS NOT NULL AND r.own_updated_at < $now
+		REMOVE r.own_local_balance, r.own_remote_balance, r.own_pending_htlcs, r.own_active, r.own_updated_at
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear closed own channels: %w", err)
+	}
+	return overlaid, nil
+}
+
+// MarkPeers marks the current peers of the local node in a namespace with
+// is_peer, peer_address, peer_inbound, peer_ping_ms, peer_bytes_sent,
+// peer_bytes_received and peer_updated_at. Nodes that are no longer peers
+// lose these properties. Returns the number of peers marked; a namespace of
+// another network is left alone.
+func MarkPeers(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	if Peers == nil {
+		return 0, nil
+	}
+	network, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return 0, err
+	}
+	peers, err := Peers(ctx, network)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(peers))
+	for _, p := range peers {
+		rows = append(rows, map[string]interface{}{
+			"pubkey":         p.PubKey,
+			"address":        p.Address,
+			"inbound":        p.Inbound,
+			"ping_ms":        float64(p.PingTime.Microseconds()) / 1000,
+			"bytes_sent":     int64(p.BytesSent),
+			"bytes_received": int64(p.BytesReceived),
+		})
+	}
+	params := map[string]interface{}{"namespace": namespace, "rows": rows, "now": time.Now().Unix()}
+	marked, err := countQuery(driver, `
+		UNWIND $rows AS row
+		MATCH (n:node {pubkey: row.pubkey, namespace: $namespace})
+		SET n.is_peer = true, n.peer_address = row.address, n.peer_inbound = row.inbound,
+			n.peer_ping_ms = row.ping_ms, n.peer_bytes_sent = row.bytes_sent,
+			n.peer_bytes_received = row.bytes_received, n.peer_updated_at = $now
+		RETURN count(n) AS count
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark peers: %w", err)
+	}
+	_, err = CommitQuery(ctx, driver, `
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.is_peer AND n.peer_updated_at < $now
+		REMOVE n.is_peer, n.peer_address, n.peer_inbound, n.peer_ping_ms, n.peer_bytes_sent,
+			n.peer_bytes_received, n.peer_updated_at
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear former peers: %w", err)
+	}
+	return marked, nil
+}
+
+// RunOwnChannelRefresh overlays the local node's channels, marks its peers
+// and shows its pending channels every interval on each namespace that has
+// been overlaid before, so that balances follow payments and peers follow
+// connections, until stop is closed.
+func RunOwnChannelRefresh(driver neo4j.Driver, interval time.Duration, stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			records, err := collectRecords(driver, "MATCH (n:node) WHERE n.is_self RETURN DISTINCT n.namespace AS namespace", nil)
+			if err != nil {
+				log.Printf("Own channel refresh failed: %v", err)
+				continue
+			}
+			for _, record := range records {
+				namespace, _ := record.Get("namespace")
+				ns, _ := namespace.(string)
+				if _, err := OverlayOwnChannels(ctx, driver, ns); err != nil {
+					log.Printf("Failed to refresh own channels in namespace %q: %v", ns, err)
+				}
+				if _, err := MarkPeers(ctx, driver, ns); err != nil {
+					log.Printf("Failed to refresh peers in namespace %q: %v", ns, err)
+				}
+				if _, err := SyncPendingChannels(ctx, driver, ns); err != nil {
+					log.Printf("Failed to refresh pending channels in namespace %q: %v", ns, err)
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// openChannel returns a Cypher condition that holds unless the edge bound
+// to variable v belongs to a channel marked closed by MarkClosedChannels or
+// still being opened (see SyncPendingChannels). Such edges stay in the graph
+// for inspection, but analytics skip them.
+func openChannel(v string) string {
+	return "(" + v + ".closed IS NULL AND coalesce(" + v + ".pending, '') <> 'opening')"
+}
+
+// MarkClosedChannels marks the edges of channels the local node has closed
+// as closed, with close_type (e.g. cooperative or remote_force),
+// close_height and closing_tx, and disables them so that path finding skips
+// them. Live updates delete closed channels, but closes that happened while
+// ln-stream was not running, or that postdate a snapshot, would otherwise go
+// unnoticed. A namespace of another network is left alone. Returns the
+// number of channels marked.
+func MarkClosedChannels(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	if ClosedChannels == nil {
+		return 0, nil
+	}
+	network, channels, err := ClosedChannels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows := make([]map[string]interface{}, 0, len(channels))
+	for _, c := range channels {
+		rows = append(rows, map[string]interface{}{
+			"channel_id":   c.ChannelID,
+			"close_type":   c.CloseType,
+			"close_height": c.CloseHeight,
+			"closing_tx":   c.ClosingTx,
+		})
+	}
+	marked, err := countQuery(driver, `
+		UNWIND $rows AS row
+		MATCH ()-[r:edge {channel_id: row.channel_id, namespace: $namespace}]->()
+		WHERE r.closed IS NULL
+		OPTIONAL MATCH (m:graph_meta {namespace: $namespace})
+		WITH row, r, m
+		WHERE coalesce(m.network, '') IN ['', $network]
+		SET r.closed = true, r.disabled = true, r.close_type = row.close_type,
+			r.close_height = row.close_height, r.closing_tx = row.closing_tx
+		RETURN count(DISTINCT row.channel_id) AS count
+	`, map[string]interface{}{"namespace": namespace, "network": network, "rows": rows})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark closed channels: %w", err)
+	}
+	return marked, nil
+}
+
+// SyncPendingChannels shows the local node's pending channels in a
+// namespace before gossip confirms them. Channels being opened get a pair
+// of disabled edges to the peer with pending set to opening and a
+// channel_id of pending- followed by the funding outpoint, since they have
+// no short channel ID yet. Edges of channels being closed, matched by
+// chan_point, get pending set to waiting_close or force_closing. Once LND
+// no longer lists a channel as pending, its opening edges are deleted (gossip
+// brings the real ones) and the pending state of closing edges is removed.
+// Returns the number of pending channels shown; a namespace of another
+// network is left alone.
+func SyncPendingChannels(ctx context.Context, driver neo4j.Driver, namespace string) (int64, error) {
+	if PendingChannels == nil {
+		return 0, nil
+	}
+	network, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return 0, err
+	}
+	self, channels, err := PendingChannels(ctx, network)
+	if err != nil {
+		return 0, err
+	}
+
+	var opening, closing []map[string]interface{}
+	for _, c := range channels {
+		row := map[string]interface{}{
+			"channel_id": "pending-" + strings.ReplaceAll(c.ChanPoint, ":", "-"),
+			"chan_point": c.ChanPoint,
+			"peer":       c.Peer,
+			"capacity":   c.Capacity,
+			"state":      c.State,
+		}
+		if c.State == "opening" {
+			opening = append(opening, row)
+		} else {
+			closing = append(closing, row)
+		}
+	}
+	params := map[string]interface{}{"namespace": namespace, "self": self, "now": time.Now().Unix(),
+		"opening": opening, "closing": closing}
+
+	opened, err := countQuery(driver, `
+		UNWIND $opening AS row
+		MERGE (a:node {pubkey: $self, namespace: $namespace})
+		MERGE (b:node {pubkey: row.peer, namespace: $namespace})
+		MERGE (a)-[r1:edge {channel_id: row.channel_id}]->(b)
+		MERGE (b)-[r2:edge {channel_id: row.channel_id}]->(a)
+		FOREACH (r IN [r1, r2] |
+			SET r.namespace = $namespace, r.pending = row.state, r.chan_point = row.chan_point,
+				r.capacity = row.capacity, r.disabled = true, r.pending_updated_at = $now)
+		RETURN count(*) AS count
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write pending opens: %w", err)
+	}
+	closed, err := countQuery(driver, `
+		UNWIND $closing AS row
+		MATCH ()-[r:edge {chan_point: row.chan_point, namespace: $namespace}]->()
+		SET r.pending = row.state, r.pending_updated_at = $now
+		RETURN count(DISTINCT row.chan_point) AS count
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark pending closes: %w", err)
+	}
+	for _, query := range []string{`
+		MATCH ()-[r:edge {namespace: $namespace, pending: 'opening'}]->()
+		WHERE r.pending_updated_at < $now
+		DELETE r
+	`, `
+		MATCH ()-[r:edge {namespace: $namespace}]->()
+		WHERE r.pending IS NOT NULL AND r.pending_updated_at < $now
+		REMOVE r.pending, r.pending_updated_at
+	`} {
+		if _, err := CommitQuery(ctx, driver, query, params); err != nil {
+			return 0, fmt.Errorf("failed to clear resolved pending channels: %w", err)
+		}
+	}
+	return opened + closed, nil
+}
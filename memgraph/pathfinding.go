@@ -0,0 +1,207 @@
+package memgraph
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// maxHops is the longest route a payment may take, matching the limit of
+// the onion format.
+const maxHops = 20
+
+// channelEdge is one direction of a channel as used for path finding.
+// Amounts are in sats except for the msat-denominated policy fields.
+type channelEdge struct {
+	ChannelID     string
+	From, To      string
+	Capacity      int64
+	FeeBaseMsat   int64
+	FeeRateMilli  int64
+	TimeLockDelta int64
+	Disabled      bool
+	MinHtlcMsat   int64
+	MaxHtlcMsat   int64
+	MinLiquidity  int64
+	MaxLiquidity  int64
+}
+
+// fee returns the fee in msat the edge's from node charges to forward amt
+// msat over it.
+func (e *channelEdge) fee(amt int64) int64 {
+	return e.FeeBaseMsat + amt*e.FeeRateMilli/1_000_000
+}
+
+// canCarry reports whether an HTLC of amt msat fits the edge's policy and
+// capacity.
+func (e *channelEdge) canCarry(amt int64) bool {
+	if e.Disabled || amt < e.MinHtlcMsat {
+		return false
+	}
+	if e.MaxHtlcMsat > 0 && amt > e.MaxHtlcMsat {
+		return false
+	}
+	return amt <= e.Capacity*1000
+}
+
+// successProbability estimates the chance that the edge's from node has amt
+// msat of outbound liquidity, assuming the liquidity is uniformly distributed
+// between the stored lower and upper bounds.
+func (e *channelEdge) successProbability(amt int64) float64 {
+	sats := (amt + 999) / 1000
+	if sats <= e.MinLiquidity {
+		return 1
+	}
+	if sats > e.MaxLiquidity {
+		return 0
+	}
+	return float64(e.MaxLiquidity+1-sats) / float64(e.MaxLiquidity+1-e.MinLiquidity)
+}
+
+// channelGraph is an in-memory copy of a namespace's channels, indexed by the
+// node each direction leads to so that routes can be searched backwards from
+// the destination.
+type channelGraph struct {
+	incoming map[string][]*channelEdge
+	outgoing map[string][]*channelEdge
+}
+
+// loadChannelGraph reads every open channel direction of a namespace. Values are
+// cast with toInteger since snapshot imports store numbers as strings; edges
+// without liquidity bounds get the full capacity as their upper bound.
+func loadChannelGraph(driver neo4j.Driver, namespace string) (*channelGraph, error) {
+	records, err := collectRecords(driver, `
+		MATCH (a:node {namespace: $namespace})-[r:edge]->(b:node)
+		WHERE `+openChannel("r")+`
+		RETURN a.pubkey AS from, b.pubkey AS to, r.channel_id AS channel_id,
+			toInteger(r.capacity) AS capacity, toInteger(r.fee_base_msat) AS fee_base,
+			toInteger(r.fee_rate_milli_msat) AS fee_rate, toInteger(r.time_lock_delta) AS time_lock,
+			r.disabled AS disabled, toInteger(r.min_htlc_msat) AS min_htlc,
+			toInteger(r.max_htlc_msat) AS max_htlc, toInteger(r.min_liquidity) AS min_liquidity,
+			toInteger(r.max_liquidity) AS max_liquidity
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channel graph: %w", err)
+	}
+
+	g := &channelGraph{incoming: map[string][]*channelEdge{}, outgoing: map[string][]*channelEdge{}}
+	for _, record := range records {
+		values := recordMap(record)
+		e := &channelEdge{}
+		e.From, _ = values["from"].(string)
+		e.To, _ = values["to"].(string)
+		e.ChannelID, _ = values["channel_id"].(string)
+		e.Capacity, _ = values["capacity"].(int64)
+		e.FeeBaseMsat, _ = values["fee_base"].(int64)
+		e.FeeRateMilli, _ = values["fee_rate"].(int64)
+		e.TimeLockDelta, _ = values["time_lock"].(int64)
+		e.Disabled, _ = values["disabled"].(bool)
+		e.MinHtlcMsat, _ = values["min_htlc"].(int64)
+		e.MaxHtlcMsat, _ = values["max_htlc"].(int64)
+		e.MinLiquidity, _ = values["min_liquidity"].(int64)
+		var ok bool
+		if e.MaxLiquidity, ok = values["max_liquidity"].(int64); !ok {
+			e.MaxLiquidity = e.Capacity
+		}
+		g.incoming[e.To] = append(g.incoming[e.To], e)
+		g.outgoing[e.From] = append(g.outgoing[e.From], e)
+	}
+	return g, nil
+}
+
+// hasNode reports whether pubKey has any channels in the graph.
+func (g *channelGraph) hasNode(pubKey string) bool {
+	return len(g.incoming[pubKey]) > 0 || len(g.outgoing[pubKey]) > 0
+}
+
+// routeHop is one hop of a found route.
+type routeHop struct {
+	edge *channelEdge
+	// amt is the amount in msat carried over the edge.
+	amt int64
+}
+
+// searchState is a node's best known way to the destination during a
+// backwards search.
+type searchState struct {
+	node string
+	// amt is the amount in msat that must arrive at node.
+	amt   int64
+	hops  int
+	next  *channelEdge
+	index int
+}
+
+// searchQueue orders search states by the amount that must arrive, i.e. by
+// the fees accumulated so far.
+type searchQueue []*searchState
+
+func (q searchQueue) Len() int           { return len(q) }
+func (q searchQueue) Less(i, j int) bool { return q[i].amt < q[j].amt }
+func (q searchQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *searchQueue) Push(x interface{}) {
+	s := x.(*searchState)
+	s.index = len(*q)
+	*q = append(*q, s)
+}
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
+
+// cheapestRoute finds the route from source to destination with the lowest
+// fees for delivering amt msat, skipping excluded channels. Like LND, it
+// searches backwards from the destination so that each hop's amount includes
+// the fees of the hops after it. The source pays no fee for its own channel.
+// Returns nil if no route exists.
+func (g *channelGraph) cheapestRoute(source, destination string, amt int64, excluded map[string]bool) []routeHop {
+	best := map[string]*searchState{destination: {node: destination, amt: amt}}
+	done := map[string]bool{}
+	queue := &searchQueue{best[destination]}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(*searchState)
+		if done[current.node] {
+			continue
+		}
+		done[current.node] = true
+		if current.node == source {
+			break
+		}
+		if current.hops == maxHops {
+			continue
+		}
+
+		for _, e := range g.incoming[current.node] {
+			if done[e.From] || excluded[e.ChannelID] || !e.canCarry(current.amt) {
+				continue
+			}
+			arriving := current.amt
+			if e.From != source {
+				arriving += e.fee(current.amt)
+			}
+			if previous, ok := best[e.From]; ok && previous.amt <= arriving {
+				continue
+			}
+			state := &searchState{node: e.From, amt: arriving, hops: current.hops + 1, next: e}
+			best[e.From] = state
+			heap.Push(queue, state)
+		}
+	}
+
+	if !done[source] {
+		return nil
+	}
+	var route []routeHop
+	for state := best[source]; state.next != nil; state = best[state.next.To] {
+		route = append(route, routeHop{edge: state.next, amt: best[state.next.To].amt})
+	}
+	return route
+}
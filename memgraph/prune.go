@@ -0,0 +1,115 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// countQuery runs a write query that returns a single count column named "count".
+func countQuery(driver neo4j.Driver, query string, params map[string]interface{}) (int64, error) {
+	session := driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	result, err := session.Run(query, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	record, err := result.Single()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read query result: %w", err)
+	}
+	count, _ := record.Get("count")
+	n, _ := count.(int64)
+	return n, nil
+}
+
+// PruneStale handles a namespace's nodes and edges whose last_update is
+// older than ttl. In flag mode they get stale = true (and fresh entities
+// stale = false); in delete mode stale edges are removed, along with stale
+// nodes that have no channels left. Entities without a last_update are left
+// untouched. No further query is started once ctx is done.
+func PruneStale(ctx context.Context, driver neo4j.Driver, namespace string, ttl time.Duration, remove bool) (nodes, edges int64, err error) {
+	params := map[string]interface{}{"namespace": namespace, "cutoff": time.Now().Add(-ttl).Unix()}
+	count := func(query string) (int64, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return countQuery(driver, query, params)
+	}
+
+	if remove {
+		edges, err = count(`
+			MATCH (:node {namespace: $namespace})-[r:edge]->()
+			WHERE r.last_update < $cutoff
+			DELETE r
+			RETURN count(*) AS count
+		`)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to delete stale edges: %w", err)
+		}
+		nodes, err = count(`
+			MATCH (n:node {namespace: $namespace})
+			WHERE n.last_update < $cutoff AND NOT (n)--()
+			DELETE n
+			RETURN count(*) AS count
+		`)
+		if err != nil {
+			return 0, edges, fmt.Errorf("failed to delete stale nodes: %w", err)
+		}
+		return nodes, edges, nil
+	}
+
+	edges, err = count(`
+		MATCH (:node {namespace: $namespace})-[r:edge]->()
+		WHERE r.last_update IS NOT NULL
+		SET r.stale = r.last_update < $cutoff
+		WITH r WHERE r.stale
+		RETURN count(r) AS count
+	`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to flag stale edges: %w", err)
+	}
+	nodes, err = count(`
+		MATCH (n:node {namespace: $namespace})
+		WHERE n.last_update IS NOT NULL
+		SET n.stale = n.last_update < $cutoff
+		WITH n WHERE n.stale
+		RETURN count(n) AS count
+	`)
+	if err != nil {
+		return 0, edges, fmt.Errorf("failed to flag stale nodes: %w", err)
+	}
+	return nodes, edges, nil
+}
+
+// RunStalePruning calls PruneStale on every live namespace every interval
+// until stop is closed. Datasets that receive no updates are never pruned,
+// since their gossip is old by definition.
+func RunStalePruning(driver neo4j.Driver, ttl, interval time.Duration, remove bool, stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	action := "Flagged"
+	if remove {
+		action = "Deleted"
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, namespace := range LiveNamespaces() {
+				nodes, edges, err := PruneStale(ctx, driver, namespace, ttl, remove)
+				if err != nil {
+					log.Printf("Stale pruning of %q failed: %v", namespace, err)
+					continue
+				}
+				log.Printf("%s %d stale nodes and %d stale edges in %q (ttl %s)", action, nodes, edges, namespace, ttl)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
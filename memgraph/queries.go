@@ -0,0 +1,371 @@
+package memgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// QueryParam is a parameter of a saved query, passed to Cypher as $name.
+// The type of Default decides how a value given as a string is converted: a
+// number, a bool, or otherwise the string itself. Parameters without a
+// default are required.
+type QueryParam struct {
+	Name    string      `json:"name"`
+	Default interface{} `json:"default"`
+}
+
+// SavedQuery is a named, parameterized read-only Cypher query. $namespace is
+// always set to the selected namespace, so parameters may not be named
+// namespace, nor limit, which caps the returned rows. Source is "file" for
+// queries from the queries file and "api" for registered ones.
+type SavedQuery struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Cypher      string       `json:"cypher"`
+	Parameters  []QueryParam `json:"parameters"`
+	Source      string       `json:"source"`
+}
+
+// ErrInvalidQuery is returned for saved queries that are malformed or would
+// write to the database.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrQueryConflict is returned when registering or removing a saved query
+// that is defined in the queries file.
+var ErrQueryConflict = errors.New("query is defined in the queries file")
+
+// writeClause matches the Cypher clauses that modify the database. Saved
+// queries run in read sessions too, but Memgraph does not enforce those. It
+// is matched against cypherKeywords, so words in strings and names do not
+// count.
+var writeClause = regexp.MustCompile(`(?i)\b(CREATE|MERGE|SET|DELETE|REMOVE|DROP|FOREACH|LOAD\s+CSV)\b`)
+
+// procedureCall matches CALL, which registered queries may not use: a
+// procedure can write even in a read session, and writeClause cannot see
+// what it does. Queries from the queries file may call procedures.
+var procedureCall = regexp.MustCompile(`(?i)\bCALL\b`)
+
+var (
+	// queriesMu protects savedQueries, the file-defined and registered
+	// queries by name.
+	queriesMu    sync.RWMutex
+	savedQueries = map[string]SavedQuery{}
+)
+
+// cypherKeywords returns cypher with string literals, backticked names,
+// comments, and property and parameter names after . or $ blanked out, so
+// that only keywords and plain identifiers are left to match. It is a
+// best-effort guard, not a parser.
+func cypherKeywords(cypher string) string {
+	out := []byte(cypher)
+	for i := 0; i < len(out); i++ {
+		// end is the exclusive end of the span to blank out.
+		var end int
+		switch c := out[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end = i + 1
+			for end < len(out) && out[end] != c {
+				if out[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end++
+		case strings.HasPrefix(cypher[i:], "//"):
+			end = i + strings.IndexByte(cypher[i:]+"\n", '\n')
+		case strings.HasPrefix(cypher[i:], "/*"):
+			end = len(out)
+			if n := strings.Index(cypher[i+2:], "*/"); n >= 0 {
+				end = i + 2 + n + 2
+			}
+		case c == '.' || c == '$':
+			end = i + 1
+			for end < len(out) && isIdentifierByte(out[end]) {
+				end++
+			}
+		default:
+			continue
+		}
+		end = min(end, len(out))
+		for j := i; j < end; j++ {
+			out[j] = ' '
+		}
+		i = end - 1
+	}
+	return string(out)
+}
+
+// isIdentifierByte reports whether c can be part of an unquoted Cypher name.
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// validateQuery checks a saved query and normalizes its parameter defaults.
+func validateQuery(query *SavedQuery) error {
+	if !identifier.MatchString(query.Name) || strings.Contains(query.Name, ".") {
+		return fmt.Errorf("%w: name %q must be an identifier", ErrInvalidQuery, query.Name)
+	}
+	if query.Cypher == "" {
+		return fmt.Errorf("%w: %s has no cypher", ErrInvalidQuery, query.Name)
+	}
+	keywords := cypherKeywords(query.Cypher)
+	if clause := writeClause.FindString(keywords); clause != "" {
+		return fmt.Errorf("%w: %s uses write clause %s", ErrInvalidQuery, query.Name, clause)
+	}
+	if query.Source == "api" && procedureCall.MatchString(keywords) {
+		return fmt.Errorf("%w: %s calls a procedure, which only the queries file may do", ErrInvalidQuery, query.Name)
+	}
+	for i, param := range query.Parameters {
+		if !identifier.MatchString(param.Name) || strings.Contains(param.Name, ".") ||
+			param.Name == "namespace" || param.Name == "limit" {
+			return fmt.Errorf("%w: %s parameter %d has an invalid name %q", ErrInvalidQuery, query.Name, i, param.Name)
+		}
+		query.Parameters[i].Default = normalizeNumber(param.Default)
+	}
+	if query.Parameters == nil {
+		query.Parameters = []QueryParam{}
+	}
+	return nil
+}
+
+// LoadSavedQueries reads the queries file, a JSON object mapping names to
+// queries, and the queries registered through the API, which are stored as
+// :saved_query nodes. File-defined queries win over registered ones of the
+// same name. A missing file only loads the registered queries. Returns the
+// number of queries loaded.
+func LoadSavedQueries(driver neo4j.Driver, path string) (int, error) {
+	loaded := map[string]SavedQuery{}
+	records, err := collectRecords(driver, `
+		MATCH (q:saved_query)
+		RETURN q.name AS name, q.description AS description, q.cypher AS cypher, q.parameters AS parameters
+	`, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load saved queries: %w", err)
+	}
+	for _, record := range records {
+		values := recordMap(record)
+		query := SavedQuery{Source: "api"}
+		query.Name, _ = values["name"].(string)
+		query.Description, _ = values["description"].(string)
+		query.Cypher, _ = values["cypher"].(string)
+		if encoded, ok := values["parameters"].(string); ok {
+			decoder := json.NewDecoder(bytes.NewReader([]byte(encoded)))
+			decoder.UseNumber()
+			if err := decoder.Decode(&query.Parameters); err != nil {
+				return 0, fmt.Errorf("failed to decode parameters of saved query %s: %w", query.Name, err)
+			}
+		}
+		if err := validateQuery(&query); err != nil {
+			// Queries registered before a validation rule was added are
+			// skipped rather than blocking startup.
+			log.Printf("Skipping registered query: %v", err)
+			continue
+		}
+		loaded[query.Name] = query
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read queries file: %w", err)
+	}
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var fromFile map[string]SavedQuery
+		if err := decoder.Decode(&fromFile); err != nil {
+			return 0, fmt.Errorf("failed to parse queries file: %w", err)
+		}
+		for name, query := range fromFile {
+			query.Name, query.Source = name, "file"
+			if err := validateQuery(&query); err != nil {
+				return 0, err
+			}
+			loaded[name] = query
+		}
+	}
+
+	queriesMu.Lock()
+	savedQueries = loaded
+	queriesMu.Unlock()
+	return len(loaded), nil
+}
+
+// ListSavedQueries returns every saved query.
+func ListSavedQueries() []SavedQuery {
+	queriesMu.RLock()
+	defer queriesMu.RUnlock()
+	queries := make([]SavedQuery, 0, len(savedQueries))
+	for _, query := range savedQueries {
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+// LookupSavedQuery returns the saved query of a name, if any.
+func LookupSavedQuery(name string) (SavedQuery, bool) {
+	queriesMu.RLock()
+	defer queriesMu.RUnlock()
+	query, ok := savedQueries[name]
+	return query, ok
+}
+
+// RegisterQuery validates and stores a saved query, replacing a registered
+// query of the same name. Returns ErrQueryConflict if the name is taken by
+// the queries file.
+func RegisterQuery(ctx context.Context, driver neo4j.Driver, query SavedQuery) (SavedQuery, error) {
+	query.Source = "api"
+	if err := validateQuery(&query); err != nil {
+		return query, err
+	}
+	if existing, ok := LookupSavedQuery(query.Name); ok && existing.Source == "file" {
+		return query, ErrQueryConflict
+	}
+	parameters, err := json.Marshal(query.Parameters)
+	if err != nil {
+		return query, fmt.Errorf("failed to encode parameters: %w", err)
+	}
+	_, err = CommitQuery(ctx, driver, `
+		MERGE (q:saved_query {name: $name})
+		SET q.description = $description, q.cypher = $cypher, q.parameters = $parameters, q.updated_at = $now
+	`, map[string]interface{}{"name": query.Name, "description": query.Description, "cypher": query.Cypher,
+		"parameters": string(parameters), "now": time.Now().Unix()})
+	if err != nil {
+		return query, fmt.Errorf("failed to register query: %w", err)
+	}
+	queriesMu.Lock()
+	savedQueries[query.Name] = query
+	queriesMu.Unlock()
+	return query, nil
+}
+
+// RemoveQuery deletes a registered query. Returns ErrNotFound if there is no
+// such query and ErrQueryConflict if it is defined in the queries file.
+func RemoveQuery(ctx context.Context, driver neo4j.Driver, name string) error {
+	existing, ok := LookupSavedQuery(name)
+	if !ok {
+		return ErrNotFound
+	}
+	if existing.Source == "file" {
+		return ErrQueryConflict
+	}
+	if _, err := CommitQuery(ctx, driver, "MATCH (q:saved_query {name: $name}) DELETE q",
+		map[string]interface{}{"name": name}); err != nil {
+		return fmt.Errorf("failed to remove query: %w", err)
+	}
+	queriesMu.Lock()
+	delete(savedQueries, name)
+	queriesMu.Unlock()
+	return nil
+}
+
+// parseParam converts a string value to the type of a parameter's default.
+func parseParam(param QueryParam, value string) (interface{}, error) {
+	switch param.Default.(type) {
+	case int64:
+		return strconv.ParseInt(value, 10, 64)
+	case float64:
+		return strconv.ParseFloat(value, 64)
+	case bool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// RunSavedQuery runs a saved query in a read session on a namespace, with
+// values given as strings overriding parameter defaults. Unknown and
+// missing required parameters are rejected with ErrInvalidParameter. At
+// most limit rows are returned; truncated reports whether there were more.
+func RunSavedQuery(driver neo4j.Driver, namespace string, query SavedQuery, values map[string]string, limit int) (rows []map[string]interface{}, truncated bool, err error) {
+	params := map[string]interface{}{"namespace": namespace}
+	known := map[string]bool{}
+	for _, param := range query.Parameters {
+		known[param.Name] = true
+		value, ok := values[param.Name]
+		if !ok {
+			if param.Default == nil {
+				return nil, false, fmt.Errorf("%w: missing parameter %q", ErrInvalidParameter, param.Name)
+			}
+			params[param.Name] = param.Default
+			continue
+		}
+		if params[param.Name], err = parseParam(param, value); err != nil {
+			return nil, false, fmt.Errorf("%w: %s: %v", ErrInvalidParameter, param.Name, err)
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, false, fmt.Errorf("%w: unknown parameter %q", ErrInvalidParameter, name)
+		}
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+	result, err := session.Run(query.Cypher, params)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run query %s: %w", query.Name, err)
+	}
+	rows = []map[string]interface{}{}
+	for result.Next() {
+		if len(rows) == limit {
+			truncated = true
+			break
+		}
+		row := recordMap(result.Record())
+		for key, value := range row {
+			row[key] = jsonValue(value)
+		}
+		rows = append(rows, row)
+	}
+	if err := result.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to run query %s: %w", query.Name, err)
+	}
+	return rows, truncated, nil
+}
+
+// jsonValue converts the graph types in a record value to plain maps, so
+// that nodes, relationships and paths encode with lowercase keys.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case neo4j.Node:
+		return map[string]interface{}{"id": v.Id, "labels": v.Labels, "properties": v.Props}
+	case neo4j.Relationship:
+		return map[string]interface{}{"id": v.Id, "type": v.Type, "start": v.StartId, "end": v.EndId, "properties": v.Props}
+	case neo4j.Path:
+		nodes := make([]interface{}, len(v.Nodes))
+		for i, n := range v.Nodes {
+			nodes[i] = jsonValue(n)
+		}
+		relationships := make([]interface{}, len(v.Relationships))
+		for i, r := range v.Relationships {
+			relationships[i] = jsonValue(r)
+		}
+		return map[string]interface{}{"nodes": nodes, "relationships": relationships}
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = jsonValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = jsonValue(item)
+		}
+		return m
+	default:
+		return value
+	}
+}
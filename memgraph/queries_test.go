@@ -0,0 +1,72 @@
+package memgraph
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		cypher string
+		source string
+		valid  bool
+	}{
+		{"plain read", "MATCH (n:node {namespace: $namespace}) RETURN n.alias", "api", true},
+		{"create", "CREATE (n:node) RETURN n", "api", false},
+		{"merge lower case", "merge (n:node {pubkey: 'x'}) return n", "api", false},
+		{"set clause", "MATCH (n) SET n.alias = 'x'", "api", false},
+		{"detach delete", "MATCH (n) DETACH DELETE n", "api", false},
+		{"load csv", "LOAD  CSV FROM 'file.csv' AS row RETURN row", "api", false},
+		{"keyword in single-quoted string", "MATCH (n) WHERE n.alias = 'Set Node' RETURN n", "api", true},
+		{"keyword in double-quoted string", `MATCH (n) WHERE n.alias = "create delete" RETURN n`, "api", true},
+		{"escaped quote in string", `MATCH (n) WHERE n.alias = 'it\'s a set' RETURN n`, "api", true},
+		{"keyword after string", "MATCH (n) WHERE n.alias = 'a' SET n.x = 1", "api", false},
+		{"backticked name", "MATCH (n) RETURN n.`set` AS `delete`", "api", true},
+		{"property name", "MATCH (n) RETURN n.set, n.remove", "api", true},
+		{"parameter name", "MATCH (n) WHERE n.alias = $create RETURN n", "api", true},
+		{"line comment", "MATCH (n) // never SET anything\nRETURN n", "api", true},
+		{"block comment", "MATCH (n) /* DELETE */ RETURN n", "api", true},
+		{"created_at is not create", "MATCH (n) RETURN n.created_at, n.settled", "api", true},
+		{"call from the api", "CALL pagerank.get() YIELD node, rank RETURN node, rank", "api", false},
+		{"call subquery from the api", "CALL { MATCH (n) RETURN n } RETURN n", "api", false},
+		{"call from the file", "CALL pagerank.get() YIELD node, rank RETURN node, rank", "file", true},
+		{"call in string", "MATCH (n) WHERE n.alias = 'call me' RETURN n", "api", true},
+		{"write inside call from the file", "CALL { CREATE (n) } RETURN 1", "file", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := SavedQuery{Name: "q", Cypher: test.cypher, Source: test.source}
+			err := validateQuery(&query)
+			if test.valid && err != nil {
+				t.Errorf("validateQuery(%q) = %v, want nil", test.cypher, err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("validateQuery(%q) = %v, want ErrInvalidQuery", test.cypher, err)
+			}
+		})
+	}
+}
+
+func TestValidateQueryParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		valid bool
+	}{
+		{"identifier", "count", true},
+		{"reserved namespace", "namespace", false},
+		{"reserved limit", "limit", false},
+		{"dotted", "a.b", false},
+		{"not an identifier", "1count", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := SavedQuery{Name: "q", Cypher: "RETURN $" + test.param, Parameters: []QueryParam{{Name: test.param}}}
+			err := validateQuery(&query)
+			if (err == nil) != test.valid {
+				t.Errorf("validateQuery with parameter %q = %v, want valid %v", test.param, err, test.valid)
+			}
+		})
+	}
+}
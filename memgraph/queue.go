@@ -0,0 +1,218 @@
+package memgraph
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// OnUpdate, if set, is called with every update pushed to an UpdateQueue and
+// the name of its source, before coalescing. It must not block.
+var OnUpdate func(source string, update *lndclient.GraphTopologyUpdate)
+
+// QueueWarnAt is the number of pending updates above which an UpdateQueue
+// logs that writes are falling behind the incoming stream.
+var QueueWarnAt = 10000
+
+// maxDrain bounds how many pending updates are applied in one round, so a
+// large backlog is written in several smaller transactions.
+const maxDrain = 1000
+
+// QueueStats describes the state of an UpdateQueue.
+type QueueStats struct {
+	Pending    int        `json:"pending"`
+	HighWater  int        `json:"high_water"`
+	Enqueued   uint64     `json:"enqueued"`
+	Coalesced  uint64     `json:"coalesced"`
+	Applied    uint64     `json:"applied"`
+	Throughput Throughput `json:"throughput"`
+}
+
+// UpdateQueue decouples receiving graph updates from writing them, so slow
+// database writes never stall the stream they come from. Pending updates are
+// coalesced: a newer update for the same node or channel direction replaces
+// the older one, and a channel close discards pending updates for that
+// channel. The backlog is therefore bounded by the size of the graph.
+// Liveness scores only see the updates that survive coalescing.
+type UpdateQueue struct {
+	source     string
+	throughput throughputTracker
+
+	mu     sync.Mutex
+	notify chan struct{}
+	nodes  map[route.Vertex]lndclient.NodeUpdate
+	edges  map[uint64]map[route.Vertex]lndclient.ChannelEdgeUpdate
+	closes map[uint64]lndclient.ChannelCloseUpdate
+	stats  QueueStats
+	behind bool
+}
+
+// NewUpdateQueue returns an empty queue for updates from the named source
+// (e.g. "lnd" or "p2p") and registers it for WriteMetrics. Call Run to start
+// applying updates.
+func NewUpdateQueue(source string) *UpdateQueue {
+	q := &UpdateQueue{
+		source: source,
+		notify: make(chan struct{}, 1),
+		nodes:  map[route.Vertex]lndclient.NodeUpdate{},
+		edges:  map[uint64]map[route.Vertex]lndclient.ChannelEdgeUpdate{},
+		closes: map[uint64]lndclient.ChannelCloseUpdate{},
+	}
+	registerQueue(q)
+	return q
+}
+
+// Push adds an update to the queue without blocking.
+func (q *UpdateQueue) Push(update *lndclient.GraphTopologyUpdate) {
+	if update == nil {
+		return
+	}
+	if OnUpdate != nil {
+		OnUpdate(q.source, update)
+	}
+	q.mu.Lock()
+	for _, nodeUpdate := range update.NodeUpdates {
+		if _, ok := q.nodes[nodeUpdate.IdentityKey]; ok {
+			q.stats.Coalesced++
+		} else {
+			q.stats.Pending++
+		}
+		q.nodes[nodeUpdate.IdentityKey] = nodeUpdate
+	}
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		scid := edgeUpdate.ChannelID.ToUint64()
+		directions, ok := q.edges[scid]
+		if !ok {
+			directions = map[route.Vertex]lndclient.ChannelEdgeUpdate{}
+			q.edges[scid] = directions
+		}
+		if _, ok := directions[edgeUpdate.AdvertisingNode]; ok {
+			q.stats.Coalesced++
+		} else {
+			q.stats.Pending++
+		}
+		directions[edgeUpdate.AdvertisingNode] = edgeUpdate
+	}
+	for _, closeUpdate := range update.ChannelCloseUpdates {
+		scid := closeUpdate.ChannelID.ToUint64()
+		if directions, ok := q.edges[scid]; ok {
+			q.stats.Coalesced += uint64(len(directions))
+			q.stats.Pending -= len(directions)
+			delete(q.edges, scid)
+		}
+		if _, ok := q.closes[scid]; ok {
+			q.stats.Coalesced++
+		} else {
+			q.stats.Pending++
+		}
+		q.closes[scid] = closeUpdate
+	}
+	q.stats.Enqueued += uint64(len(update.NodeUpdates) + len(update.ChannelEdgeUpdates) + len(update.ChannelCloseUpdates))
+	if q.stats.Pending > q.stats.HighWater {
+		q.stats.HighWater = q.stats.Pending
+	}
+	if q.stats.Pending > QueueWarnAt && !q.behind {
+		q.behind = true
+		log.Printf("Graph update queue is falling behind: %d updates pending", q.stats.Pending)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain removes up to max pending updates from the queue.
+func (q *UpdateQueue) drain(max int) *lndclient.GraphTopologyUpdate {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	update := &lndclient.GraphTopologyUpdate{}
+	n := 0
+	for key, nodeUpdate := range q.nodes {
+		if n == max {
+			break
+		}
+		update.NodeUpdates = append(update.NodeUpdates, nodeUpdate)
+		delete(q.nodes, key)
+		n++
+	}
+	for scid, directions := range q.edges {
+		if n == max {
+			break
+		}
+		for _, edgeUpdate := range directions {
+			update.ChannelEdgeUpdates = append(update.ChannelEdgeUpdates, edgeUpdate)
+			n++
+		}
+		delete(q.edges, scid)
+	}
+	for scid, closeUpdate := range q.closes {
+		if n == max {
+			break
+		}
+		update.ChannelCloseUpdates = append(update.ChannelCloseUpdates, closeUpdate)
+		delete(q.closes, scid)
+		n++
+	}
+
+	q.stats.Pending -= n
+	if q.behind && q.stats.Pending < QueueWarnAt/2 {
+		q.behind = false
+		log.Printf("Graph update queue caught up: %d updates pending", q.stats.Pending)
+	}
+	if n == 0 {
+		return nil
+	}
+	return update
+}
+
+// Run applies pending updates with apply until stop is closed. apply is
+// called from this goroutine only, with about maxDrain updates at a time
+// (both directions of a channel are always drained together). While the
+// health check reports Memgraph down, updates stay queued (and coalesced)
+// until it is back.
+func (q *UpdateQueue) Run(stop <-chan struct{}, apply func(*lndclient.GraphTopologyUpdate)) {
+	for {
+		select {
+		case <-q.notify:
+		case <-stop:
+			return
+		}
+		for {
+			// Keep updates queued while Memgraph is down.
+			if !waitDatabaseUp(stop) {
+				return
+			}
+			update := q.drain(maxDrain)
+			if update == nil {
+				break
+			}
+			apply(update)
+			q.throughput.record(update, time.Now())
+
+			q.mu.Lock()
+			q.stats.Applied += uint64(len(update.NodeUpdates) + len(update.ChannelEdgeUpdates) + len(update.ChannelCloseUpdates))
+			q.mu.Unlock()
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// Stats returns a snapshot of the queue's counters and throughput.
+func (q *UpdateQueue) Stats() QueueStats {
+	q.mu.Lock()
+	stats := q.stats
+	q.mu.Unlock()
+	stats.Throughput = q.throughput.snapshot(time.Now())
+	return stats
+}
@@ -0,0 +1,251 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"golang.org/x/net/proxy"
+)
+
+// probeBatchSize is the number of nodes whose probe results are written per
+// query.
+const probeBatchSize = 1000
+
+// ProbeConfig configures ProbeReachability. TorProxy is the host:port of a
+// SOCKS5 proxy through which .onion addresses are dialed; without one they
+// are not probed.
+type ProbeConfig struct {
+	Timeout     time.Duration
+	Concurrency int
+	TorProxy    string
+}
+
+// probeTarget is a node and the advertised addresses that can be probed.
+type probeTarget struct {
+	namespace string
+	pubKey    string
+	addresses []string
+}
+
+// ProbeReachability attempts a TCP connection to every advertised address of
+// every node in the live namespaces and records the outcome on the node:
+//   - reachable: whether any address accepted a connection
+//   - reachable_address: the first address that did, removed if none
+//   - last_probe: unix time of the probe
+//
+// A connection is only opened and closed; no Lightning handshake is made.
+// Addresses that are not public, such as loopback, private or link-local
+// IPs, are never dialed, so gossip cannot point the probe at the local
+// network. Nodes without addresses, or with only .onion addresses and no
+// TorProxy, are left untouched. Returns the number of nodes probed and how many of
+// them were reachable.
+func ProbeReachability(ctx context.Context, driver neo4j.Driver, config ProbeConfig) (probed, reachable int, err error) {
+	namespaces := LiveNamespaces()
+	if len(namespaces) == 0 {
+		return 0, 0, nil
+	}
+	records, err := collectRecords(driver, `
+		MATCH (n:node)
+		WHERE n.namespace IN $namespaces AND size(coalesce(n.addresses, [])) > 0
+		RETURN n.namespace AS namespace, n.pubkey AS pubkey, n.addresses AS addresses
+	`, map[string]interface{}{"namespaces": namespaces})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read node addresses: %w", err)
+	}
+
+	var targets []probeTarget
+	unique := map[string]bool{}
+	for _, record := range records {
+		values := recordMap(record)
+		target := probeTarget{}
+		target.namespace, _ = values["namespace"].(string)
+		target.pubKey, _ = values["pubkey"].(string)
+		addresses, _ := values["addresses"].([]interface{})
+		for _, a := range addresses {
+			address := fmt.Sprint(a)
+			if isOnion(address) {
+				if config.TorProxy == "" {
+					continue
+				}
+			} else if !isPublicAddress(address) {
+				continue
+			}
+			target.addresses = append(target.addresses, address)
+			unique[address] = true
+		}
+		if len(target.addresses) > 0 {
+			targets = append(targets, target)
+		}
+	}
+
+	open, err := probeAddresses(ctx, unique, config)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now().Unix()
+	rows := make([]map[string]interface{}, 0, probeBatchSize)
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err := CommitQuery(ctx, driver, `
+			UNWIND $rows AS row
+			MATCH (n:node {pubkey: row.pubkey, namespace: row.namespace})
+			SET n.reachable = row.reachable, n.reachable_address = row.address, n.last_probe = $now
+		`, map[string]interface{}{"rows": rows, "now": now})
+		rows = rows[:0]
+		if err != nil {
+			return fmt.Errorf("failed to store probe results: %w", err)
+		}
+		return nil
+	}
+	for _, target := range targets {
+		var address interface{}
+		for _, a := range target.addresses {
+			if open[a] {
+				address = a
+				break
+			}
+		}
+		if address != nil {
+			reachable++
+		}
+		rows = append(rows, map[string]interface{}{
+			"namespace": target.namespace,
+			"pubkey":    target.pubKey,
+			"reachable": address != nil,
+			"address":   address,
+		})
+		if len(rows) == probeBatchSize {
+			if err := flush(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, 0, err
+	}
+	return len(targets), reachable, nil
+}
+
+// probeAddresses dials every address with at most config.Concurrency
+// connection attempts in flight and returns the set that accepted one.
+// Direct connections to IPs that are not public are refused, which covers
+// host names resolving to them; the Tor proxy itself may be local.
+func probeAddresses(ctx context.Context, addresses map[string]bool, config ProbeConfig) (map[string]bool, error) {
+	direct := &net.Dialer{Timeout: config.Timeout, Control: refuseNonPublic}
+	var tor proxy.ContextDialer
+	if config.TorProxy != "" {
+		dialer, err := proxy.SOCKS5("tcp", config.TorProxy, nil, &net.Dialer{Timeout: config.Timeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up Tor proxy: %w", err)
+		}
+		tor = dialer.(proxy.ContextDialer)
+	}
+
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	open := map[string]bool{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for address := range addresses {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			dialCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+			defer cancel()
+			var conn net.Conn
+			var err error
+			if isOnion(address) {
+				conn, err = tor.DialContext(dialCtx, "tcp", address)
+			} else {
+				conn, err = direct.DialContext(dialCtx, "tcp", address)
+			}
+			if err != nil {
+				return
+			}
+			conn.Close()
+			mu.Lock()
+			open[address] = true
+			mu.Unlock()
+		}(address)
+	}
+	wg.Wait()
+	return open, ctx.Err()
+}
+
+// isOnion reports whether address is a Tor onion service address.
+func isOnion(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	return strings.HasSuffix(strings.ToLower(host), ".onion")
+}
+
+// isPublicAddress reports whether address is a host name or a public IP
+// address, with or without a port.
+func isPublicAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || isPublicIP(ip)
+}
+
+// isPublicIP reports whether ip is a global unicast address outside the
+// private ranges.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// refuseNonPublic is a net.Dialer Control function that refuses connections
+// to IPs that are not public.
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	if !isPublicAddress(address) {
+		return fmt.Errorf("refusing to probe non-public address %s", address)
+	}
+	return nil
+}
+
+// RunReachabilityProbe calls ProbeReachability every interval until stop is
+// closed.
+func RunReachabilityProbe(driver neo4j.Driver, interval time.Duration, config ProbeConfig, stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			probed, reachable, err := ProbeReachability(ctx, driver, config)
+			if err != nil {
+				log.Printf("Reachability probe failed: %v", err)
+				continue
+			}
+			log.Printf("Probed %d nodes, %d reachable, in %s", probed, reachable, time.Since(start).Round(time.Second))
+		case <-stop:
+			return
+		}
+	}
+}
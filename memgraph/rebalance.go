@@ -0,0 +1,109 @@
+package memgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// RebalanceRoute is a circular route that moves liquidity out of one of a
+// node's channels and back in through another. Pubkeys lists the nodes the
+// route passes, ending with the node itself, as external rebalancers take
+// them.
+type RebalanceRoute struct {
+	Outbound           string         `json:"outbound_channel_id"`
+	Inbound            string         `json:"inbound_channel_id"`
+	Hops               []SimulatedHop `json:"hops"`
+	Pubkeys            []string       `json:"pubkeys"`
+	TotalFeeMsat       int64          `json:"total_fee_msat"`
+	FeePPM             float64        `json:"fee_ppm"`
+	TotalTimeLock      int64          `json:"total_time_lock"`
+	SuccessProbability float64        `json:"success_probability"`
+}
+
+// FindRebalanceRoutes finds the cheapest circular route for amountMsat from
+// node pubKey out through each of its outbound channels and back in through
+// each of its inbound channels, over a namespace's stored graph. Routes
+// costing more than maxFeePPM (if positive) are dropped, and at most
+// maxRoutes are returned, cheapest first. Returns ErrNotFound if a channel
+// is not one of the node's.
+func FindRebalanceRoutes(driver neo4j.Driver, namespace, pubKey string, outbound, inbound []string, amountMsat, maxFeePPM int64, maxRoutes int) ([]RebalanceRoute, error) {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return nil, err
+	}
+	outEdges := map[string]*channelEdge{}
+	for _, e := range g.outgoing[pubKey] {
+		outEdges[e.ChannelID] = e
+	}
+	inEdges := map[string]*channelEdge{}
+	for _, e := range g.incoming[pubKey] {
+		inEdges[e.ChannelID] = e
+	}
+	for _, id := range outbound {
+		if outEdges[id] == nil {
+			return nil, fmt.Errorf("%w: outbound channel %s of %s", ErrNotFound, id, pubKey)
+		}
+	}
+	for _, id := range inbound {
+		if inEdges[id] == nil {
+			return nil, fmt.Errorf("%w: inbound channel %s of %s", ErrNotFound, id, pubKey)
+		}
+	}
+
+	routes := []RebalanceRoute{}
+	for _, outID := range outbound {
+		// Leave the node only through the chosen outbound channel.
+		excluded := map[string]bool{}
+		for id := range outEdges {
+			excluded[id] = id != outID
+		}
+		for _, inID := range inbound {
+			if inID == outID {
+				continue
+			}
+			last := inEdges[inID]
+			if !last.canCarry(amountMsat) {
+				continue
+			}
+			// The peer of the inbound channel charges its fee on the way back.
+			hops := g.cheapestRoute(pubKey, last.From, amountMsat+last.fee(amountMsat), excluded)
+			if hops == nil {
+				continue
+			}
+			hops = append(hops, routeHop{edge: last, amt: amountMsat})
+
+			route := RebalanceRoute{Outbound: outID, Inbound: inID, SuccessProbability: 1}
+			for i, hop := range hops {
+				h := SimulatedHop{
+					ChannelID:          hop.edge.ChannelID,
+					From:               hop.edge.From,
+					To:                 hop.edge.To,
+					AmountMsat:         hop.amt,
+					SuccessProbability: hop.edge.successProbability(hop.amt),
+				}
+				if i > 0 {
+					h.FeeMsat = hop.edge.fee(hop.amt)
+					h.TimeLockDelta = hop.edge.TimeLockDelta
+				}
+				route.Hops = append(route.Hops, h)
+				route.Pubkeys = append(route.Pubkeys, h.To)
+				route.TotalFeeMsat += h.FeeMsat
+				route.TotalTimeLock += h.TimeLockDelta
+				route.SuccessProbability *= h.SuccessProbability
+			}
+			route.FeePPM = float64(route.TotalFeeMsat) * 1_000_000 / float64(amountMsat)
+			if maxFeePPM > 0 && route.FeePPM > float64(maxFeePPM) {
+				continue
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].TotalFeeMsat < routes[j].TotalFeeMsat })
+	if len(routes) > maxRoutes {
+		routes = routes[:maxRoutes]
+	}
+	return routes, nil
+}
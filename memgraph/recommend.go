@@ -0,0 +1,173 @@
+package memgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// recommendCandidates bounds how many of the best-connected nodes are
+// evaluated as peers, since each needs a search over the whole graph.
+const recommendCandidates = 300
+
+// PeerRecommendation is a suggested peer for a new channel. Score is the
+// mean of the normalized components that apply: CentralityGain, the
+// increase in the node's harmonic closeness to the rest of the network;
+// the candidate's fee level, lower being better; and, if payment targets
+// are given, how much closer the channel brings them.
+type PeerRecommendation struct {
+	PubKey         string  `json:"pubkey"`
+	Alias          string  `json:"alias,omitempty"`
+	Score          float64 `json:"score"`
+	CentralityGain float64 `json:"centrality_gain"`
+	MedianFeeRate  int64   `json:"median_fee_rate_milli_msat"`
+	Channels       int     `json:"channels"`
+	Capacity       int64   `json:"capacity"`
+	// TargetHops is the number of hops from the node to each payment target
+	// through the new channel, or -1 if a target stays unreachable.
+	TargetHops map[string]int `json:"target_hops,omitempty"`
+}
+
+// hopDistances returns the number of hops from source to every node
+// reachable over enabled channel directions.
+func (g *channelGraph) hopDistances(source string) map[string]int {
+	dist := map[string]int{source: 0}
+	queue := []string{source}
+	for i := 0; i < len(queue); i++ {
+		v := queue[i]
+		for _, e := range g.outgoing[v] {
+			if _, seen := dist[e.To]; !seen && !e.Disabled {
+				dist[e.To] = dist[v] + 1
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	return dist
+}
+
+// RecommendPeers suggests up to limit nodes for pubKey to open channels
+// with, computed over a namespace's stored graph. Candidates are the
+// recommendCandidates nodes with the most channel capacity that pubKey has
+// no channel with yet. pubKey does not need to be in the graph, so new nodes
+// can get recommendations too.
+func RecommendPeers(driver neo4j.Driver, namespace, pubKey string, targets []string, limit int) ([]PeerRecommendation, error) {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return nil, err
+	}
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})-[r:edge]->()
+		WHERE n.pubkey <> $pubkey AND NOT coalesce(r.disabled, false) AND `+openChannel("r")+`
+			AND NOT exists((n)-[:edge]-(:node {pubkey: $pubkey, namespace: $namespace}))
+		RETURN n.pubkey AS pubkey, n.alias AS alias, count(r) AS channels,
+			sum(toInteger(r.capacity)) AS capacity
+		ORDER BY capacity DESC
+		LIMIT $limit
+	`, map[string]interface{}{"namespace": namespace, "pubkey": pubKey, "limit": recommendCandidates})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select candidates: %w", err)
+	}
+
+	current := g.hopDistances(pubKey)
+	recommendations := make([]PeerRecommendation, 0, len(records))
+	var maxGain, maxTargetGain float64
+	targetGains := make([]float64, 0, len(records))
+	for _, record := range records {
+		values := recordMap(record)
+		rec := PeerRecommendation{}
+		rec.PubKey, _ = values["pubkey"].(string)
+		rec.Alias, _ = values["alias"].(string)
+		channels, _ := values["channels"].(int64)
+		rec.Channels = int(channels)
+		rec.Capacity, _ = values["capacity"].(int64)
+
+		var fees []int64
+		for _, e := range g.outgoing[rec.PubKey] {
+			if !e.Disabled {
+				fees = append(fees, e.FeeRateMilli)
+			}
+		}
+		sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+		if len(fees) > 0 {
+			rec.MedianFeeRate = fees[len(fees)/2]
+		}
+
+		// A channel to the candidate puts every node it reaches in d hops
+		// within d+1 hops of pubKey.
+		via := g.hopDistances(rec.PubKey)
+		for node, d := range via {
+			if node == pubKey {
+				continue
+			}
+			old, ok := current[node]
+			if !ok || d+1 < old {
+				rec.CentralityGain += 1 / float64(d+1)
+				if ok {
+					rec.CentralityGain -= 1 / float64(old)
+				}
+			}
+		}
+		if n := len(g.outgoing); n > 1 {
+			rec.CentralityGain /= float64(n - 1)
+		}
+
+		var targetGain float64
+		if len(targets) > 0 {
+			rec.TargetHops = make(map[string]int, len(targets))
+			for _, target := range targets {
+				d, ok := via[target]
+				if !ok {
+					rec.TargetHops[target] = -1
+					continue
+				}
+				rec.TargetHops[target] = d + 1
+				if old, ok := current[target]; !ok || d+1 < old {
+					targetGain += 1 / float64(d+1)
+					if ok {
+						targetGain -= 1 / float64(old)
+					}
+				}
+			}
+		}
+
+		maxGain = max(maxGain, rec.CentralityGain)
+		maxTargetGain = max(maxTargetGain, targetGain)
+		targetGains = append(targetGains, targetGain)
+		recommendations = append(recommendations, rec)
+	}
+
+	// Rank fees so that one expensive outlier does not flatten the rest.
+	byFee := make([]int, len(recommendations))
+	for i := range byFee {
+		byFee[i] = i
+	}
+	sort.SliceStable(byFee, func(i, j int) bool {
+		return recommendations[byFee[i]].MedianFeeRate < recommendations[byFee[j]].MedianFeeRate
+	})
+	feeScores := make([]float64, len(recommendations))
+	for rank, i := range byFee {
+		feeScores[i] = 1 - float64(rank)/float64(max(len(byFee)-1, 1))
+	}
+
+	for i := range recommendations {
+		rec := &recommendations[i]
+		score, parts := feeScores[i], 1.0
+		if maxGain > 0 {
+			score += rec.CentralityGain / maxGain
+			parts++
+		}
+		if len(targets) > 0 {
+			if maxTargetGain > 0 {
+				score += targetGains[i] / maxTargetGain
+			}
+			parts++
+		}
+		rec.Score = score / parts
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool { return recommendations[i].Score > recommendations[j].Score })
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
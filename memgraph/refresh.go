@@ -0,0 +1,163 @@
+package memgraph
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var (
+	// refreshEnabled turns on tracking of nodes affected by live updates; it
+	// is set by RunMetricRefresh so that nothing accumulates when the
+	// refresh is off.
+	refreshEnabled atomic.Bool
+
+	// dirtyMu protects dirtyNodes, the nodes per namespace whose channels
+	// changed since their total_capacity was last computed, and
+	// topologyChanged, the namespaces whose channels changed since
+	// centrality was last computed.
+	dirtyMu         sync.Mutex
+	dirtyNodes      = map[string]map[string]bool{}
+	topologyChanged = map[string]bool{}
+)
+
+// markAffected records the nodes whose derived metrics an update makes
+// stale. Endpoints of closed channels are looked up, so it must run before
+// the update is applied.
+func markAffected(driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) {
+	if !refreshEnabled.Load() || len(update.ChannelEdgeUpdates)+len(update.ChannelCloseUpdates) == 0 {
+		return
+	}
+
+	var pubKeys []string
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		pubKeys = append(pubKeys, edgeUpdate.AdvertisingNode.String(), edgeUpdate.ConnectingNode.String())
+	}
+	if len(update.ChannelCloseUpdates) > 0 {
+		ids := make([]string, 0, len(update.ChannelCloseUpdates))
+		for _, closeUpdate := range update.ChannelCloseUpdates {
+			ids = append(ids, channelID(closeUpdate.ChannelID))
+		}
+		records, err := collectRecords(driver, `
+			MATCH (a:node {namespace: $namespace})-[r:edge]->()
+			WHERE r.channel_id IN $ids
+			RETURN DISTINCT a.pubkey AS pubkey
+		`, map[string]interface{}{"namespace": namespace, "ids": ids})
+		if err != nil {
+			log.Printf("Failed to read closed channel endpoints: %v", err)
+		}
+		for _, record := range records {
+			pubKey, _ := record.Get("pubkey")
+			if s, ok := pubKey.(string); ok {
+				pubKeys = append(pubKeys, s)
+			}
+		}
+	}
+
+	dirtyMu.Lock()
+	defer dirtyMu.Unlock()
+	nodes := dirtyNodes[namespace]
+	if nodes == nil {
+		nodes = map[string]bool{}
+		dirtyNodes[namespace] = nodes
+	}
+	for _, pubKey := range pubKeys {
+		nodes[pubKey] = true
+	}
+	topologyChanged[namespace] = true
+}
+
+// refreshCapacities recomputes total_capacity for the nodes affected since
+// the last call, in every namespace, and brings channel ages and capacity
+// values in those namespaces up to date.
+func refreshCapacities(ctx context.Context, driver neo4j.Driver) {
+	dirtyMu.Lock()
+	pending := dirtyNodes
+	dirtyNodes = map[string]map[string]bool{}
+	dirtyMu.Unlock()
+
+	for namespace, nodes := range pending {
+		pubKeys := make([]string, 0, len(nodes))
+		for pubKey := range nodes {
+			pubKeys = append(pubKeys, pubKey)
+		}
+		_, err := CommitQuery(ctx, driver, `
+			UNWIND $pubkeys AS pubkey
+			MATCH (n:node {pubkey: pubkey, namespace: $namespace})
+			OPTIONAL MATCH (n)-[r:edge]-()
+			WHERE `+openChannel("r")+`
+			WITH n, sum(r.capacity) AS total_capacity
+			SET n.total_capacity = total_capacity/2
+		`, map[string]interface{}{"pubkeys": pubKeys, "namespace": namespace})
+		if err != nil {
+			log.Printf("Failed to refresh node capacities in namespace %q: %v", namespace, err)
+			continue
+		}
+		log.Printf("Refreshed total_capacity of %d nodes in namespace %q", len(pubKeys), namespace)
+		if err := SetChannelAges(ctx, driver, namespace); err != nil {
+			log.Printf("Failed to refresh channel ages in namespace %q: %v", namespace, err)
+		}
+		if err := SetCapacityValues(ctx, driver, namespace); err != nil {
+			log.Printf("Failed to refresh capacity values in namespace %q: %v", namespace, err)
+		}
+	}
+}
+
+// refreshCentrality recomputes node and edge betweenness for every namespace
+// whose channels changed since the last call.
+func refreshCentrality(ctx context.Context, driver neo4j.Driver) {
+	dirtyMu.Lock()
+	pending := topologyChanged
+	topologyChanged = map[string]bool{}
+	dirtyMu.Unlock()
+
+	for namespace := range pending {
+		start := time.Now()
+		if err := setBetweenness(ctx, driver, namespace); err != nil {
+			log.Printf("Failed to refresh betweenness in namespace %q: %v", namespace, err)
+			continue
+		}
+		if _, err := CommitQuery(ctx, driver, edgeBetweennessQuery, map[string]interface{}{"namespace": namespace}); err != nil {
+			log.Printf("Failed to refresh edge betweenness in namespace %q: %v", namespace, err)
+			continue
+		}
+		log.Printf("Refreshed betweenness in namespace %q in %s", namespace, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// RunMetricRefresh keeps derived metrics current while live updates change
+// the graph: every capacityInterval, total_capacity is recomputed for nodes
+// whose channels were updated or closed, and every centralityInterval,
+// betweenness is recomputed for namespaces whose channels changed. A zero
+// centralityInterval leaves centrality to the next import. Runs until stop
+// is closed.
+func RunMetricRefresh(driver neo4j.Driver, capacityInterval, centralityInterval time.Duration, stop <-chan struct{}) {
+	refreshEnabled.Store(true)
+	defer refreshEnabled.Store(false)
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+
+	capacityTicker := time.NewTicker(capacityInterval)
+	defer capacityTicker.Stop()
+	var centralityTick <-chan time.Time
+	if centralityInterval > 0 {
+		centralityTicker := time.NewTicker(centralityInterval)
+		defer centralityTicker.Stop()
+		centralityTick = centralityTicker.C
+	}
+	for {
+		select {
+		case <-capacityTicker.C:
+			refreshCapacities(ctx, driver)
+		case <-centralityTick:
+			refreshCentrality(ctx, driver)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package memgraph
+
+import (
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// SimulatedHop is one hop of a simulated route. AmountMsat is the amount
+// carried over the channel and FeeMsat the fee charged by From for it.
+type SimulatedHop struct {
+	ChannelID          string  `json:"channel_id"`
+	From               string  `json:"from"`
+	To                 string  `json:"to"`
+	AmountMsat         int64   `json:"amount_msat"`
+	FeeMsat            int64   `json:"fee_msat"`
+	TimeLockDelta      int64   `json:"time_lock_delta"`
+	SuccessProbability float64 `json:"success_probability"`
+}
+
+// SimulatedRoute is a candidate route for a simulated payment.
+type SimulatedRoute struct {
+	Hops               []SimulatedHop `json:"hops"`
+	TotalFeeMsat       int64          `json:"total_fee_msat"`
+	TotalTimeLock      int64          `json:"total_time_lock"`
+	SuccessProbability float64        `json:"success_probability"`
+}
+
+// PaymentSimulation is the result of SimulatePayment. SuccessProbability is
+// the estimated chance that at least one candidate succeeds when they are
+// tried in order, treating the routes as independent.
+type PaymentSimulation struct {
+	Source             string           `json:"source"`
+	Destination        string           `json:"destination"`
+	AmountMsat         int64            `json:"amount_msat"`
+	Routes             []SimulatedRoute `json:"routes"`
+	SuccessProbability float64          `json:"success_probability"`
+}
+
+// SimulatePayment searches a namespace's stored graph for up to maxRoutes
+// candidate routes delivering amountMsat from source to destination. Each
+// route is the cheapest one that avoids the least likely channel of every
+// earlier candidate, so candidates are alternatives rather than variations
+// of a single path. Disabled channels and channels whose htlc limits or
+// capacity cannot carry the amount are never used. Returns ErrNotFound if
+// either node has no channels.
+func SimulatePayment(driver neo4j.Driver, namespace, source, destination string, amountMsat int64, maxRoutes int) (*PaymentSimulation, error) {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !g.hasNode(source) || !g.hasNode(destination) {
+		return nil, fmt.Errorf("%w: source or destination has no channels", ErrNotFound)
+	}
+
+	result := &PaymentSimulation{
+		Source:      source,
+		Destination: destination,
+		AmountMsat:  amountMsat,
+		Routes:      []SimulatedRoute{},
+	}
+	excluded := map[string]bool{}
+	failure := 1.0
+	for len(result.Routes) < maxRoutes {
+		hops := g.cheapestRoute(source, destination, amountMsat, excluded)
+		if hops == nil {
+			break
+		}
+
+		route := SimulatedRoute{SuccessProbability: 1}
+		var weakest string
+		weakestProbability := 2.0
+		for i, hop := range hops {
+			h := SimulatedHop{
+				ChannelID:          hop.edge.ChannelID,
+				From:               hop.edge.From,
+				To:                 hop.edge.To,
+				AmountMsat:         hop.amt,
+				SuccessProbability: hop.edge.successProbability(hop.amt),
+			}
+			// The sender neither charges itself a fee nor adds its own delta.
+			if i > 0 {
+				h.FeeMsat = hop.edge.fee(hop.amt)
+				h.TimeLockDelta = hop.edge.TimeLockDelta
+			}
+			route.Hops = append(route.Hops, h)
+			route.TotalFeeMsat += h.FeeMsat
+			route.TotalTimeLock += h.TimeLockDelta
+			route.SuccessProbability *= h.SuccessProbability
+			if h.SuccessProbability < weakestProbability {
+				weakest, weakestProbability = h.ChannelID, h.SuccessProbability
+			}
+		}
+		result.Routes = append(result.Routes, route)
+		failure *= 1 - route.SuccessProbability
+		excluded[weakest] = true
+	}
+	if len(result.Routes) > 0 {
+		result.SuccessProbability = 1 - failure
+	}
+	return result, nil
+}
@@ -0,0 +1,336 @@
+package memgraph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Distribution summarizes a set of values by count and percentiles.
+type Distribution struct {
+	Count int     `json:"count"`
+	P10   float64 `json:"p10"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// NetworkSummary holds percentile breakdowns of channel capacity (per channel)
+// and fees (per channel direction) across the graph.
+type NetworkSummary struct {
+	Capacity         Distribution `json:"capacity"`
+	FeeBaseMsat      Distribution `json:"fee_base_msat"`
+	FeeRateMilliMsat Distribution `json:"fee_rate_milli_msat"`
+	ComputedAt       time.Time    `json:"computed_at"`
+}
+
+// collectRecords runs a read query and returns all of its records. Like
+// CommitQuery, it retries once if the query fails to connect.
+func collectRecords(driver neo4j.Driver, query string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	records, err := readRecords(driver, query, params)
+	if err != nil && reconnectOnError(driver, errors.Unwrap(err)) {
+		records, err = readRecords(driver, query, params)
+	}
+	return records, err
+}
+
+// readRecords runs a read query in a session of its own.
+func readRecords(driver neo4j.Driver, query string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+	result, err := session.Run(query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	records, err := result.Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query results: %w", err)
+	}
+	return records, nil
+}
+
+// recordMap returns the values of a record keyed by column name.
+func recordMap(record *neo4j.Record) map[string]interface{} {
+	values := make(map[string]interface{}, len(record.Keys))
+	for i, key := range record.Keys {
+		values[key] = record.Values[i]
+	}
+	return values
+}
+
+// toFloat converts a numeric record value to float64. Nulls and non-numeric
+// values report false.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// distribution computes the summary of an unsorted set of values.
+func distribution(values []float64) Distribution {
+	sort.Float64s(values)
+	return Distribution{
+		Count: len(values),
+		P10:   percentile(values, 10),
+		P50:   percentile(values, 50),
+		P90:   percentile(values, 90),
+		P99:   percentile(values, 99),
+	}
+}
+
+// ComputeNetworkSummary computes capacity and fee distributions over a
+// namespace's graph. Values are cast with toInteger since snapshot imports
+// store them as strings.
+func ComputeNetworkSummary(driver neo4j.Driver, namespace string) (*NetworkSummary, error) {
+	records, err := collectRecords(driver, `
+		MATCH ()-[r:edge {namespace: $namespace}]->()
+		WHERE `+openChannel("r")+`
+		RETURN r.channel_id AS channel_id, toInteger(r.capacity) AS capacity,
+			toInteger(r.fee_base_msat) AS fee_base, toInteger(r.fee_rate_milli_msat) AS fee_rate
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	var capacities, feeBases, feeRates []float64
+	seen := map[interface{}]bool{}
+	for _, record := range records {
+		// Both directions of a channel carry its capacity; count it once.
+		channelID, _ := record.Get("channel_id")
+		if !seen[channelID] {
+			seen[channelID] = true
+			capacity, _ := record.Get("capacity")
+			if v, ok := toFloat(capacity); ok {
+				capacities = append(capacities, v)
+			}
+		}
+		feeBase, _ := record.Get("fee_base")
+		if v, ok := toFloat(feeBase); ok {
+			feeBases = append(feeBases, v)
+		}
+		feeRate, _ := record.Get("fee_rate")
+		if v, ok := toFloat(feeRate); ok {
+			feeRates = append(feeRates, v)
+		}
+	}
+
+	return &NetworkSummary{
+		Capacity:         distribution(capacities),
+		FeeBaseMsat:      distribution(feeBases),
+		FeeRateMilliMsat: distribution(feeRates),
+		ComputedAt:       time.Now(),
+	}, nil
+}
+
+// HistogramBucket counts the values in [Lower, Upper). The last bucket of a
+// histogram also includes its upper bound.
+type HistogramBucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// Histogram is an equal-width histogram spanning the minimum to maximum value.
+type Histogram struct {
+	Count   int               `json:"count"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// FeeHistograms holds histograms of base fee and fee rate over enabled
+// channel directions.
+type FeeHistograms struct {
+	FeeBaseMsat      Histogram `json:"fee_base_msat"`
+	FeeRateMilliMsat Histogram `json:"fee_rate_milli_msat"`
+}
+
+// histogram sorts values into n equal-width buckets between their minimum and maximum.
+func histogram(values []float64, n int) Histogram {
+	h := Histogram{Count: len(values), Buckets: []HistogramBucket{}}
+	if len(values) == 0 || n < 1 {
+		return h
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	width := (hi - lo) / float64(n)
+	if width == 0 {
+		width = 1
+	}
+	h.Buckets = make([]HistogramBucket, n)
+	for i := range h.Buckets {
+		h.Buckets[i].Lower = lo + float64(i)*width
+		h.Buckets[i].Upper = lo + float64(i+1)*width
+	}
+	for _, v := range values {
+		i := int((v - lo) / width)
+		if i >= n {
+			i = n - 1
+		}
+		h.Buckets[i].Count++
+	}
+	return h
+}
+
+// ComputeFeeHistograms builds base fee and fee rate histograms with the given
+// number of buckets over all enabled channel directions in a namespace.
+func ComputeFeeHistograms(driver neo4j.Driver, namespace string, buckets int) (*FeeHistograms, error) {
+	records, err := collectRecords(driver, `
+		MATCH ()-[r:edge {namespace: $namespace}]->()
+		WHERE r.disabled = false AND `+openChannel("r")+`
+		RETURN toInteger(r.fee_base_msat) AS fee_base, toInteger(r.fee_rate_milli_msat) AS fee_rate
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	var feeBases, feeRates []float64
+	for _, record := range records {
+		feeBase, _ := record.Get("fee_base")
+		if v, ok := toFloat(feeBase); ok {
+			feeBases = append(feeBases, v)
+		}
+		feeRate, _ := record.Get("fee_rate")
+		if v, ok := toFloat(feeRate); ok {
+			feeRates = append(feeRates, v)
+		}
+	}
+
+	return &FeeHistograms{
+		FeeBaseMsat:      histogram(feeBases, buckets),
+		FeeRateMilliMsat: histogram(feeRates, buckets),
+	}, nil
+}
+
+// DegreeCount is the number of nodes having a given number of channels.
+type DegreeCount struct {
+	Degree int64 `json:"degree"`
+	Nodes  int64 `json:"nodes"`
+}
+
+// DegreeDistribution describes how channels are spread over nodes. Weighted,
+// when requested, is a histogram of each node's total channel capacity.
+type DegreeDistribution struct {
+	Degrees  []DegreeCount `json:"degrees"`
+	Weighted *Histogram    `json:"weighted,omitempty"`
+}
+
+// ComputeDegreeDistribution counts a namespace's nodes by channel count. Each channel counts
+// once per node even though it is stored as two directed edges. If weighted is
+// set, a histogram of per-node capacity with the given number of buckets is
+// included as well.
+func ComputeDegreeDistribution(driver neo4j.Driver, namespace string, weighted bool, buckets int) (*DegreeDistribution, error) {
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})
+		OPTIONAL MATCH (n)-[r:edge]-()
+		WHERE `+openChannel("r")+`
+		WITH n, r.channel_id AS channel_id, max(toInteger(r.capacity)) AS capacity
+		WITH n, count(channel_id) AS degree, sum(capacity) AS weighted_degree
+		RETURN degree, weighted_degree
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[int64]int64{}
+	var weightedDegrees []float64
+	for _, record := range records {
+		degree, _ := record.Get("degree")
+		if d, ok := degree.(int64); ok {
+			counts[d]++
+		}
+		weightedDegree, _ := record.Get("weighted_degree")
+		if v, ok := toFloat(weightedDegree); ok {
+			weightedDegrees = append(weightedDegrees, v)
+		}
+	}
+
+	dist := &DegreeDistribution{Degrees: make([]DegreeCount, 0, len(counts))}
+	for degree, nodes := range counts {
+		dist.Degrees = append(dist.Degrees, DegreeCount{Degree: degree, Nodes: nodes})
+	}
+	sort.Slice(dist.Degrees, func(i, j int) bool {
+		return dist.Degrees[i].Degree < dist.Degrees[j].Degree
+	})
+	if weighted {
+		h := histogram(weightedDegrees, buckets)
+		dist.Weighted = &h
+	}
+	return dist, nil
+}
+
+// ConnectivityClass counts the nodes of one connectivity class and their
+// combined channel capacity in sats.
+type ConnectivityClass struct {
+	Class    string `json:"class"`
+	Nodes    int64  `json:"nodes"`
+	Capacity int64  `json:"capacity"`
+}
+
+// ConnectivityStats breaks a namespace's nodes down by how they can be
+// reached, as classified from their advertised addresses. Nodes imported
+// before addresses were classified count as class "unknown".
+type ConnectivityStats struct {
+	Classes  []ConnectivityClass `json:"classes"`
+	IPv4     int64               `json:"ipv4"`
+	IPv6     int64               `json:"ipv6"`
+	IPv6Only int64               `json:"ipv6_only"`
+	Tor      int64               `json:"tor"`
+}
+
+// ComputeConnectivityStats counts a namespace's nodes per connectivity class
+// and per address family.
+func ComputeConnectivityStats(driver neo4j.Driver, namespace string) (*ConnectivityStats, error) {
+	records, err := collectRecords(driver, `
+		MATCH (n:node {namespace: $namespace})
+		WITH coalesce(n.connectivity, 'unknown') AS class, n
+		RETURN class, count(n) AS nodes, sum(coalesce(n.total_capacity, 0)) AS capacity,
+			sum(CASE WHEN n.has_ipv4 THEN 1 ELSE 0 END) AS ipv4,
+			sum(CASE WHEN n.has_ipv6 THEN 1 ELSE 0 END) AS ipv6,
+			sum(CASE WHEN n.ipv6_only THEN 1 ELSE 0 END) AS ipv6_only,
+			sum(CASE WHEN n.has_tor THEN 1 ELSE 0 END) AS tor
+		ORDER BY class
+	`, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ConnectivityStats{Classes: make([]ConnectivityClass, 0, len(records))}
+	for _, record := range records {
+		values := recordMap(record)
+		class := ConnectivityClass{}
+		class.Class, _ = values["class"].(string)
+		class.Nodes, _ = values["nodes"].(int64)
+		if capacity, ok := toFloat(values["capacity"]); ok {
+			class.Capacity = int64(capacity)
+		}
+		stats.Classes = append(stats.Classes, class)
+		for key, total := range map[string]*int64{"ipv4": &stats.IPv4, "ipv6": &stats.IPv6, "ipv6_only": &stats.IPv6Only, "tor": &stats.Tor} {
+			n, _ := values[key].(int64)
+			*total += n
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,64 @@
+package memgraph
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/price"
+)
+
+// PriceQuote, if set, returns the current price of bitcoin, which is used to
+// store fiat equivalents of capacities. Without it no values are stored.
+var PriceQuote func(ctx context.Context) (price.Quote, error)
+
+// SetCapacityValues stores capacity_btc and capacity_fiat on every edge of a
+// namespace, total_capacity_btc and total_capacity_fiat on its nodes, and the
+// quote used on its graph_meta node. Only mainnet graphs (or graphs of no
+// recorded network) are valued, since coins on test networks are worthless.
+// A failing price feed is logged and leaves the previous values in place.
+func SetCapacityValues(ctx context.Context, driver neo4j.Driver, namespace string) error {
+	if PriceQuote == nil {
+		return nil
+	}
+	network, err := GetNetwork(driver, namespace)
+	if err != nil {
+		return err
+	}
+	if network != "" && network != "mainnet" {
+		return nil
+	}
+	quote, err := PriceQuote(ctx)
+	if err != nil {
+		log.Printf("Skipping fiat values for namespace %q: %v", namespace, err)
+		return nil
+	}
+
+	params := map[string]interface{}{"namespace": namespace, "sats": float64(price.SatsPerBTC),
+		"price": quote.BTC, "currency": quote.Currency, "at": quote.At.Unix()}
+	queries := []struct {
+		desc  string
+		query string
+	}{
+		{desc: "value channel capacities", query: `
+			MATCH (:node {namespace: $namespace})-[r:edge]->()
+			WHERE r.capacity IS NOT NULL
+			SET r.capacity_btc = r.capacity / $sats,
+				r.capacity_fiat = r.capacity / $sats * $price`},
+		{desc: "value node capacities", query: `
+			MATCH (n:node {namespace: $namespace})
+			WHERE n.total_capacity IS NOT NULL
+			SET n.total_capacity_btc = n.total_capacity / $sats,
+				n.total_capacity_fiat = n.total_capacity / $sats * $price`},
+		{desc: "record price", query: `
+			MERGE (m:graph_meta {namespace: $namespace})
+			SET m.fiat_currency = $currency, m.btc_price = $price, m.priced_at = $at`},
+	}
+	for _, q := range queries {
+		if _, err := CommitQuery(ctx, driver, q.query, params); err != nil {
+			return fmt.Errorf("failed to %s: %w", q.desc, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package memgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Watch kinds. Nodes are targeted by pubkey, channels by channel_id in the
+// block x index x output form.
+const (
+	WatchNode    = "node"
+	WatchChannel = "channel"
+)
+
+// Watch is a registered interest in a node or channel. Watches are stored as :watch
+// nodes so that they survive restarts and namespace resets.
+type Watch struct {
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Target    string    `json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Change is the old and new value of a changed property. Old is nil for
+// properties that were not known before.
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// WatchEvent is a recorded change affecting a watched target. Type is one of
+// node_announcement, channel_policy, channel_disabled, channel_enabled or
+// channel_closed; for channel events ChannelID is set, and Node is the node
+// whose direction of the channel changed.
+type WatchEvent struct {
+	Namespace string            `json:"namespace"`
+	Kind      string            `json:"kind"`
+	Target    string            `json:"target"`
+	Type      string            `json:"type"`
+	ChannelID string            `json:"channel_id,omitempty"`
+	Node      string            `json:"node,omitempty"`
+	Changes   map[string]Change `json:"changes,omitempty"`
+	At        time.Time         `json:"at"`
+}
+
+// OnWatchEvent, if set, is called for every recorded watch event, e.g. to
+// send notifications. It is called synchronously and must not block.
+var OnWatchEvent func(WatchEvent)
+
+// watchKey identifies a watch in the in-memory watch set.
+type watchKey struct {
+	namespace, kind, target string
+}
+
+var (
+	// watchMu protects watched, the in-memory copy of all stored watches,
+	// and watchCount, the number of watches per namespace.
+	watchMu    sync.RWMutex
+	watched    = map[watchKey]bool{}
+	watchCount = map[string]int{}
+)
+
+// NormalizeChannelID converts a channel ID given as BLOCKxTXxOUTPUT,
+// BLOCK:TX:OUTPUT or a numeric short channel ID to the stored
+// BLOCKxTXxOUTPUT form.
+func NormalizeChannelID(s string) (string, error) {
+	if scid, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return channelID(lnwire.NewShortChanIDFromInt(scid)), nil
+	}
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == 'x' || r == ':' })
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid channel id %q", s)
+	}
+	var values [3]uint64
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid channel id %q", s)
+		}
+		values[i] = v
+	}
+	return fmt.Sprintf("%dx%dx%d", values[0], values[1], values[2]), nil
+}
+
+// isWatched reports whether target of the given kind is watched in namespace.
+func isWatched(namespace, kind, target string) bool {
+	watchMu.RLock()
+	defer watchMu.RUnlock()
+	return watched[watchKey{namespace, kind, target}]
+}
+
+// hasWatches reports whether namespace has any watches.
+func hasWatches(namespace string) bool {
+	watchMu.RLock()
+	defer watchMu.RUnlock()
+	return watchCount[namespace] > 0
+}
+
+// setWatched adds or removes a watch from the in-memory set.
+func setWatched(key watchKey, on bool) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if watched[key] == on {
+		return
+	}
+	if on {
+		watched[key] = true
+		watchCount[key.namespace]++
+	} else {
+		delete(watched, key)
+		watchCount[key.namespace]--
+	}
+}
+
+// LoadWatches replaces the watches in memory with the stored ones. Should be
+// called at startup and after the database was restored.
+func LoadWatches(driver neo4j.Driver) error {
+	records, err := collectRecords(driver,
+		"MATCH (w:watch) RETURN w.namespace AS namespace, w.kind AS kind, w.target AS target", nil)
+	if err != nil {
+		return fmt.Errorf("failed to load watches: %w", err)
+	}
+	watchMu.Lock()
+	watched, watchCount = map[watchKey]bool{}, map[string]int{}
+	watchMu.Unlock()
+	for _, record := range records {
+		namespace, _ := record.Get("namespace")
+		kind, _ := record.Get("kind")
+		target, _ := record.Get("target")
+		key := watchKey{}
+		key.namespace, _ = namespace.(string)
+		key.kind, _ = kind.(string)
+		key.target, _ = target.(string)
+		setWatched(key, true)
+	}
+	return nil
+}
+
+// AddWatch registers a watch. Adding an existing watch is a no-op.
+func AddWatch(ctx context.Context, driver neo4j.Driver, namespace, kind, target string) error {
+	_, err := CommitQuery(ctx, driver, `
+		MERGE (w:watch {namespace: $namespace, kind: $kind, target: $target})
+		ON CREATE SET w.created_at = $now
+	`, map[string]interface{}{"namespace": namespace, "kind": kind, "target": target, "now": time.Now().Unix()})
+	if err != nil {
+		return fmt.Errorf("failed to add watch: %w", err)
+	}
+	setWatched(watchKey{namespace, kind, target}, true)
+	return nil
+}
+
+// RemoveWatch deletes a watch, returning ErrNotFound if it does not exist.
+// Events recorded for it are kept.
+func RemoveWatch(ctx context.Context, driver neo4j.Driver, namespace, kind, target string) error {
+	if !isWatched(namespace, kind, target) {
+		return ErrNotFound
+	}
+	_, err := CommitQuery(ctx, driver, "MATCH (w:watch {namespace: $namespace, kind: $kind, target: $target}) DELETE w",
+		map[string]interface{}{"namespace": namespace, "kind": kind, "target": target})
+	if err != nil {
+		return fmt.Errorf("failed to remove watch: %w", err)
+	}
+	setWatched(watchKey{namespace, kind, target}, false)
+	return nil
+}
+
+// ListWatches returns the watches of a kind in a namespace, oldest first.
+func ListWatches(driver neo4j.Driver, namespace, kind string) ([]Watch, error) {
+	records, err := collectRecords(driver, `
+		MATCH (w:watch {namespace: $namespace, kind: $kind})
+		RETURN w.target AS target, w.created_at AS created_at
+		ORDER BY w.created_at
+	`, map[string]interface{}{"namespace": namespace, "kind": kind})
+	if err != nil {
+		return nil, err
+	}
+	watches := make([]Watch, 0, len(records))
+	for _, record := range records {
+		target, _ := record.Get("target")
+		createdAt, _ := record.Get("created_at")
+		w := Watch{Namespace: namespace, Kind: kind}
+		w.Target, _ = target.(string)
+		if unix, ok := createdAt.(int64); ok {
+			w.CreatedAt = time.Unix(unix, 0)
+		}
+		watches = append(watches, w)
+	}
+	return watches, nil
+}
+
+// ListWatchEvents returns recorded events in a namespace at or after since,
+// oldest first. Empty kind or target match any. At most limit events are
+// returned.
+func ListWatchEvents(driver neo4j.Driver, namespace, kind, target string, since time.Time, limit int) ([]WatchEvent, error) {
+	records, err := collectRecords(driver, `
+		MATCH (e:watch_event {namespace: $namespace})
+		WHERE e.at >= $since AND ($kind = '' OR e.kind = $kind) AND ($target = '' OR e.target = $target)
+		RETURN e.kind AS kind, e.target AS target, e.type AS type, e.channel_id AS channel_id,
+			e.node AS node, e.changes AS changes, e.at AS at
+		ORDER BY e.at
+		LIMIT $limit
+	`, map[string]interface{}{
+		"namespace": namespace, "kind": kind, "target": target, "since": since.Unix(), "limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]WatchEvent, 0, len(records))
+	for _, record := range records {
+		e := WatchEvent{Namespace: namespace}
+		values := recordMap(record)
+		e.Kind, _ = values["kind"].(string)
+		e.Target, _ = values["target"].(string)
+		e.Type, _ = values["type"].(string)
+		e.ChannelID, _ = values["channel_id"].(string)
+		e.Node, _ = values["node"].(string)
+		if changes, ok := values["changes"].(string); ok && changes != "" {
+			if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+				log.Printf("Failed to decode watch event changes: %v", err)
+			}
+		}
+		if at, ok := values["at"].(int64); ok {
+			e.At = time.Unix(at, 0)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// recordWatchEvent stores an event and passes it to OnWatchEvent.
+func recordWatchEvent(ctx context.Context, driver neo4j.Driver, event WatchEvent) {
+	changes := ""
+	if len(event.Changes) > 0 {
+		data, err := json.Marshal(event.Changes)
+		if err != nil {
+			log.Printf("Failed to encode watch event changes: %v", err)
+		}
+		changes = string(data)
+	}
+	_, err := commitOnce(ctx, driver, `
+		CREATE (:watch_event {namespace: $namespace, kind: $kind, target: $target, type: $type,
+			channel_id: $channelID, node: $node, changes: $changes, at: $at})
+	`, map[string]interface{}{
+		"namespace": event.Namespace,
+		"kind":      event.Kind,
+		"target":    event.Target,
+		"type":      event.Type,
+		"channelID": event.ChannelID,
+		"node":      event.Node,
+		"changes":   changes,
+		"at":        event.At.Unix(),
+	})
+	if err != nil {
+		log.Printf("Failed to record watch event: %v", err)
+	}
+	if OnWatchEvent != nil {
+		OnWatchEvent(event)
+	}
+}
+
+// diff compares stored values with new ones and returns the changed fields.
+// Values are compared by their printed form, since snapshot imports store
+// numbers as strings.
+func diff(old, current map[string]interface{}) map[string]Change {
+	changes := map[string]Change{}
+	for field, value := range current {
+		previous := old[field]
+		if previous == nil || fmt.Sprint(previous) != fmt.Sprint(value) {
+			changes[field] = Change{Old: previous, New: value}
+		}
+	}
+	return changes
+}
+
+// storedProperties returns the named properties of the first record matched
+// by query, or nil if nothing matched.
+func storedProperties(driver neo4j.Driver, query string, params map[string]interface{}) map[string]interface{} {
+	records, err := collectRecords(driver, query, params)
+	if err != nil {
+		log.Printf("Failed to read watched properties: %v", err)
+		return nil
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return recordMap(records[0])
+}
+
+// policyEventType names a channel policy change, singling out flips of the
+// disabled flag.
+func policyEventType(changes map[string]Change) string {
+	if change, ok := changes["disabled"]; ok && change.Old != nil {
+		if disabled, _ := change.New.(bool); disabled {
+			return "channel_disabled"
+		}
+		return "channel_enabled"
+	}
+	return "channel_policy"
+}
+
+// recordWatchEvents compares an update with the stored graph and records an
+// event for every change affecting a watched node or channel. Must run before
+// the update is applied.
+func recordWatchEvents(ctx context.Context, driver neo4j.Driver, namespace string, update *lndclient.GraphTopologyUpdate) {
+	if !hasWatches(namespace) {
+		return
+	}
+	now := time.Now()
+
+	for _, nodeUpdate := range update.NodeUpdates {
+		pubKey := nodeUpdate.IdentityKey.String()
+		if !isWatched(namespace, WatchNode, pubKey) {
+			continue
+		}
+		old := storedProperties(driver, `
+			MATCH (n:node {pubkey: $pubKey, namespace: $namespace})
+			RETURN n.alias AS alias, n.color AS color, n.addresses AS addresses
+		`, map[string]interface{}{"pubKey": pubKey, "namespace": namespace})
+		changes := diff(old, map[string]interface{}{
+			"alias":     nodeUpdate.Alias,
+			"color":     nodeUpdate.Color,
+			"addresses": nodeUpdate.Addresses,
+		})
+		if len(changes) > 0 {
+			recordWatchEvent(ctx, driver, WatchEvent{Namespace: namespace, Kind: WatchNode, Target: pubKey,
+				Type: "node_announcement", Node: pubKey, Changes: changes, At: now})
+		}
+	}
+
+	for _, edgeUpdate := range update.ChannelEdgeUpdates {
+		advertising := edgeUpdate.AdvertisingNode.String()
+		connecting := edgeUpdate.ConnectingNode.String()
+		chanID := channelID(edgeUpdate.ChannelID)
+		var targets []watchKey
+		for _, key := range []watchKey{
+			{namespace, WatchNode, advertising},
+			{namespace, WatchNode, connecting},
+			{namespace, WatchChannel, chanID},
+		} {
+			if isWatched(key.namespace, key.kind, key.target) {
+				targets = append(targets, key)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		old := storedProperties(driver, `
+			MATCH (:node {pubkey: $advertisingNode, namespace: $namespace})-[r:edge {channel_id: $channelID}]->()
+			RETURN r.fee_base_msat AS fee_base_msat, r.fee_rate_milli_msat AS fee_rate_milli_msat,
+				r.time_lock_delta AS time_lock_delta, r.disabled AS disabled
+		`, map[string]interface{}{"advertisingNode": advertising, "namespace": namespace, "channelID": chanID})
+		// Disabling updates only touch the disabled flag; see ProcessEdgeUpdate.
+		policy := map[string]interface{}{"disabled": edgeUpdate.RoutingPolicy.Disabled}
+		if !edgeUpdate.RoutingPolicy.Disabled {
+			policy["fee_base_msat"] = edgeUpdate.RoutingPolicy.FeeBaseMsat
+			policy["fee_rate_milli_msat"] = edgeUpdate.RoutingPolicy.FeeRateMilliMsat
+			policy["time_lock_delta"] = edgeUpdate.RoutingPolicy.TimeLockDelta
+		}
+		changes := diff(old, policy)
+		if len(changes) == 0 {
+			continue
+		}
+		for _, target := range targets {
+			recordWatchEvent(ctx, driver, WatchEvent{Namespace: namespace, Kind: target.kind, Target: target.target,
+				Type: policyEventType(changes), ChannelID: chanID, Node: advertising, Changes: changes, At: now})
+		}
+	}
+
+	for _, closeUpdate := range update.ChannelCloseUpdates {
+		chanID := channelID(closeUpdate.ChannelID)
+		if isWatched(namespace, WatchChannel, chanID) {
+			recordWatchEvent(ctx, driver, WatchEvent{Namespace: namespace, Kind: WatchChannel, Target: chanID,
+				Type: "channel_closed", ChannelID: chanID, At: now})
+		}
+		records, err := collectRecords(driver, `
+			MATCH (a:node {namespace: $namespace})-[:edge {channel_id: $channelID}]-()
+			RETURN DISTINCT a.pubkey AS pubkey
+		`, map[string]interface{}{"namespace": namespace, "channelID": chanID})
+		if err != nil {
+			log.Printf("Failed to read closed channel endpoints: %v", err)
+			continue
+		}
+		for _, record := range records {
+			pubKey, _ := record.Get("pubkey")
+			target, _ := pubKey.(string)
+			if isWatched(namespace, WatchNode, target) {
+				recordWatchEvent(ctx, driver, WatchEvent{Namespace: namespace, Kind: WatchNode, Target: target,
+					Type: "channel_closed", ChannelID: chanID, At: now})
+			}
+		}
+	}
+}
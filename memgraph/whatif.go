@@ -0,0 +1,263 @@
+package memgraph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// whatIfSamples is the number of sources betweenness is estimated from when
+// a what-if scenario is evaluated, unless BetweennessSamples is set. The
+// same sources are used before and after the change so that the deltas are
+// not sampling noise.
+const whatIfSamples = 200
+
+// WhatIfChannel is a hypothetical channel between two nodes, added in both
+// directions with the same policy.
+type WhatIfChannel struct {
+	Node1            string `json:"node1"`
+	Node2            string `json:"node2"`
+	Capacity         int64  `json:"capacity"`
+	FeeBaseMsat      int64  `json:"fee_base_msat"`
+	FeeRateMilliMsat int64  `json:"fee_rate_milli_msat"`
+	TimeLockDelta    int64  `json:"time_lock_delta"`
+}
+
+// WhatIfPair is a source and destination whose shortest paths are compared.
+type WhatIfPair struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// WhatIfScenario describes changes to apply to an in-memory copy of a
+// namespace's graph. AmountMsat is the payment amount the cheapest routes
+// between Pairs are found for.
+type WhatIfScenario struct {
+	RemoveNodes    []string        `json:"remove_nodes"`
+	RemoveChannels []string        `json:"remove_channels"`
+	AddChannels    []WhatIfChannel `json:"add_channels"`
+	Pairs          []WhatIfPair    `json:"pairs"`
+	AmountMsat     int64           `json:"-"`
+}
+
+// Connectivity summarizes the weakly connected components of a graph.
+type Connectivity struct {
+	Nodes            int `json:"nodes"`
+	Channels         int `json:"channels"`
+	Components       int `json:"components"`
+	LargestComponent int `json:"largest_component"`
+}
+
+// PathComparison is the shortest path between a pair before and after a
+// scenario. Hops is the unweighted hop count and FeeMsat the fee of the
+// cheapest route for the scenario's amount; both are -1 when there is no
+// path.
+type PathComparison struct {
+	WhatIfPair
+	HopsBefore    int   `json:"hops_before"`
+	HopsAfter     int   `json:"hops_after"`
+	FeeMsatBefore int64 `json:"fee_msat_before"`
+	FeeMsatAfter  int64 `json:"fee_msat_after"`
+}
+
+// CentralityDelta is a node's change in betweenness under a scenario.
+type CentralityDelta struct {
+	PubKey string  `json:"pubkey"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+}
+
+// WhatIfResult is the impact of a scenario on a namespace's graph.
+// Disconnected lists the nodes that fall out of the largest component.
+// CentralityDeltas holds the limit largest changes in betweenness.
+type WhatIfResult struct {
+	Before           Connectivity      `json:"before"`
+	After            Connectivity      `json:"after"`
+	Disconnected     []string          `json:"disconnected"`
+	Paths            []PathComparison  `json:"paths"`
+	CentralityDeltas []CentralityDelta `json:"centrality_deltas"`
+}
+
+// withChanges returns a copy of the graph with a scenario's nodes and
+// channels removed and its channels added. The original graph is not
+// modified.
+func (g *channelGraph) withChanges(scenario WhatIfScenario) *channelGraph {
+	removedNodes := map[string]bool{}
+	for _, pubKey := range scenario.RemoveNodes {
+		removedNodes[pubKey] = true
+	}
+	removedChannels := map[string]bool{}
+	for _, id := range scenario.RemoveChannels {
+		removedChannels[id] = true
+	}
+
+	changed := &channelGraph{incoming: map[string][]*channelEdge{}, outgoing: map[string][]*channelEdge{}}
+	add := func(e *channelEdge) {
+		changed.incoming[e.To] = append(changed.incoming[e.To], e)
+		changed.outgoing[e.From] = append(changed.outgoing[e.From], e)
+	}
+	for _, edges := range g.outgoing {
+		for _, e := range edges {
+			if !removedNodes[e.From] && !removedNodes[e.To] && !removedChannels[e.ChannelID] {
+				add(e)
+			}
+		}
+	}
+	for i, ch := range scenario.AddChannels {
+		id := fmt.Sprintf("whatif-%d", i)
+		for _, dir := range [][2]string{{ch.Node1, ch.Node2}, {ch.Node2, ch.Node1}} {
+			add(&channelEdge{
+				ChannelID:     id,
+				From:          dir[0],
+				To:            dir[1],
+				Capacity:      ch.Capacity,
+				FeeBaseMsat:   ch.FeeBaseMsat,
+				FeeRateMilli:  ch.FeeRateMilliMsat,
+				TimeLockDelta: ch.TimeLockDelta,
+				MaxLiquidity:  ch.Capacity,
+			})
+		}
+	}
+	return changed
+}
+
+// components returns the weakly connected component each node belongs to,
+// numbered from 0, and the size of each component.
+func (g *channelGraph) components() (map[string]int, []int) {
+	component := map[string]int{}
+	var sizes []int
+	for _, start := range g.nodes() {
+		if _, seen := component[start]; seen {
+			continue
+		}
+		id := len(sizes)
+		component[start] = id
+		queue := []string{start}
+		for i := 0; i < len(queue); i++ {
+			v := queue[i]
+			for _, e := range g.outgoing[v] {
+				if _, seen := component[e.To]; !seen {
+					component[e.To] = id
+					queue = append(queue, e.To)
+				}
+			}
+			for _, e := range g.incoming[v] {
+				if _, seen := component[e.From]; !seen {
+					component[e.From] = id
+					queue = append(queue, e.From)
+				}
+			}
+		}
+		sizes = append(sizes, len(queue))
+	}
+	return component, sizes
+}
+
+// connectivity summarizes the graph's components and returns the members of
+// its largest component.
+func (g *channelGraph) connectivity() (Connectivity, map[string]bool) {
+	component, sizes := g.components()
+	largest := -1
+	c := Connectivity{Nodes: len(component), Components: len(sizes)}
+	for id, size := range sizes {
+		if size > c.LargestComponent {
+			c.LargestComponent, largest = size, id
+		}
+	}
+	members := map[string]bool{}
+	for pubKey, id := range component {
+		if id == largest {
+			members[pubKey] = true
+		}
+	}
+	channels := map[string]bool{}
+	for _, edges := range g.outgoing {
+		for _, e := range edges {
+			channels[e.ChannelID] = true
+		}
+	}
+	c.Channels = len(channels)
+	return c, members
+}
+
+// comparePath finds the hop count and cheapest route fee between a pair.
+func (g *channelGraph) comparePath(pair WhatIfPair, amountMsat int64) (int, int64) {
+	hops, ok := g.hopDistances(pair.Source)[pair.Destination]
+	if !ok {
+		hops = -1
+	}
+	route := g.cheapestRoute(pair.Source, pair.Destination, amountMsat, nil)
+	if route == nil {
+		return hops, -1
+	}
+	return hops, route[0].amt - amountMsat
+}
+
+// WhatIf evaluates a scenario against an in-memory copy of a namespace's
+// graph, comparing connectivity, the shortest paths between the scenario's
+// pairs and node betweenness before and after the change. Nothing is
+// written to the database. Betweenness is estimated from BetweennessSamples
+// sources, or whatIfSamples if unset, and the limit largest changes are
+// returned.
+func WhatIf(driver neo4j.Driver, namespace string, scenario WhatIfScenario, limit int) (*WhatIfResult, error) {
+	g, err := loadChannelGraph(driver, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, pubKey := range scenario.RemoveNodes {
+		if !g.hasNode(pubKey) {
+			return nil, fmt.Errorf("%w: node %s has no channels", ErrNotFound, pubKey)
+		}
+	}
+	changed := g.withChanges(scenario)
+
+	result := &WhatIfResult{Disconnected: []string{}, Paths: []PathComparison{}}
+	var before, after map[string]bool
+	result.Before, before = g.connectivity()
+	result.After, after = changed.connectivity()
+	for pubKey := range before {
+		if !after[pubKey] {
+			result.Disconnected = append(result.Disconnected, pubKey)
+		}
+	}
+	sort.Strings(result.Disconnected)
+
+	for _, pair := range scenario.Pairs {
+		p := PathComparison{WhatIfPair: pair}
+		p.HopsBefore, p.FeeMsatBefore = g.comparePath(pair, scenario.AmountMsat)
+		p.HopsAfter, p.FeeMsatAfter = changed.comparePath(pair, scenario.AmountMsat)
+		result.Paths = append(result.Paths, p)
+	}
+
+	samples := BetweennessSamples
+	if samples <= 0 {
+		samples = whatIfSamples
+	}
+	sources := g.sampleNodes(samples)
+	centralityBefore := betweennessFrom(g, sources)
+	centralityAfter := betweennessFrom(changed, sources)
+	deltas := []CentralityDelta{}
+	seen := map[string]bool{}
+	for _, nodes := range []map[string]float64{centralityBefore, centralityAfter} {
+		for pubKey := range nodes {
+			if seen[pubKey] {
+				continue
+			}
+			seen[pubKey] = true
+			d := CentralityDelta{PubKey: pubKey, Before: centralityBefore[pubKey], After: centralityAfter[pubKey]}
+			d.Delta = d.After - d.Before
+			if d.Delta != 0 {
+				deltas = append(deltas, d)
+			}
+		}
+	}
+	sort.SliceStable(deltas, func(i, j int) bool { return math.Abs(deltas[i].Delta) > math.Abs(deltas[j].Delta) })
+	if len(deltas) > limit {
+		deltas = deltas[:limit]
+	}
+	result.CentralityDeltas = deltas
+	return result, nil
+}
@@ -0,0 +1,68 @@
+// Package middleware provides gin middleware shared by all ln-stream routes:
+// request ID assignment and structured access logging.
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader is the HTTP header used to receive and return request IDs.
+	RequestIDHeader = "X-Request-ID"
+	// requestIDKey is the gin context key under which the request ID is stored.
+	requestIDKey = "request_id"
+)
+
+// validRequestID matches the caller-supplied request IDs that are reused.
+// Anything else could forge fields or lines in the access and audit logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newRequestID returns a random 16-character hex identifier.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// RequestID assigns an ID to every request, reusing the caller's X-Request-ID
+// header when it is made of at most 64 letters, digits, dots, dashes and
+// underscores, and echoes it back in the response headers.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned to the request by RequestID, or "-" if none.
+func GetRequestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	return "-"
+}
+
+// AccessLog logs one key=value line per request with its ID, method, path,
+// status and duration. It replaces gin's default logger.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		log.Printf("request_id=%s method=%s path=%s status=%d duration=%s client_ip=%s",
+			GetRequestID(c), c.Request.Method, c.Request.URL.Path, c.Writer.Status(),
+			time.Since(start), c.ClientIP())
+	}
+}
@@ -0,0 +1,187 @@
+// Package mock provides a fake graph source for demos. It serves a bundled
+// sample of the public graph and makes up topology updates on a timer, so
+// that ln-stream, including live updates, can be tried without LND or a
+// snapshot file.
+package mock
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"ln-stream/lnd"
+)
+
+// sampleGraph is a describegraph snapshot of well-connected mainnet nodes and
+// some of the channels between them.
+//
+//go:embed sample_graph.json
+var sampleGraph []byte
+
+// Source is a GraphSource serving the sample graph. Each update it emits is
+// also applied to the graph it serves, so a reset after a while of updates
+// returns the graph as the updates left it.
+type Source struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	rng        *rand.Rand
+	nodes      []lndclient.Node
+	edges      []lndclient.ChannelEdge
+	minEdges   int
+	nextHeight uint32
+}
+
+// NewSource returns a Source that emits one update every interval.
+func NewSource(interval time.Duration) (*Source, error) {
+	graph, err := lnd.DecodeSnapshot(bytes.NewReader(sampleGraph))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sample graph: %w", err)
+	}
+	sample, err := graph.LndGraph()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sample graph: %w", err)
+	}
+	s := &Source{interval: interval, rng: rand.New(rand.NewSource(time.Now().UnixNano())), nodes: sample.Nodes}
+	for _, edge := range sample.Edges {
+		// Updates pick a random direction, so both must be known.
+		if edge.Node1Policy == nil || edge.Node2Policy == nil {
+			continue
+		}
+		s.edges = append(s.edges, edge)
+		if height := uint32(edge.ChannelID >> 40); height >= s.nextHeight {
+			s.nextHeight = height + 1
+		}
+	}
+	if len(s.nodes) < 2 {
+		return nil, fmt.Errorf("sample graph has too few nodes")
+	}
+	s.minEdges = len(s.edges) / 2
+	return s, nil
+}
+
+// DescribeGraph returns a copy of the current graph.
+func (s *Source) DescribeGraph(ctx context.Context) (*lndclient.Graph, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	graph := &lndclient.Graph{Nodes: append([]lndclient.Node(nil), s.nodes...)}
+	for _, edge := range s.edges {
+		policy1, policy2 := *edge.Node1Policy, *edge.Node2Policy
+		edge.Node1Policy, edge.Node2Policy = &policy1, &policy2
+		graph.Edges = append(graph.Edges, edge)
+	}
+	return graph, nil
+}
+
+// SubscribeGraph emits a made-up update every interval until ctx is
+// cancelled. The error channel never delivers.
+func (s *Source) SubscribeGraph(ctx context.Context) (<-chan *lndclient.GraphTopologyUpdate, <-chan error, error) {
+	updates := make(chan *lndclient.GraphTopologyUpdate)
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case updates <- s.nextUpdate():
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, make(chan error), nil
+}
+
+// nextUpdate makes up an update and applies it to the served graph. Most
+// updates change a channel's fees; some disable or re-enable a channel
+// direction, re-announce a node, open a channel or close one.
+func (s *Source) nextUpdate() *lndclient.GraphTopologyUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	update := &lndclient.GraphTopologyUpdate{}
+
+	switch roll := s.rng.Intn(100); {
+	case roll < 70:
+		edge := &s.edges[s.rng.Intn(len(s.edges))]
+		policy, from, to := s.direction(edge)
+		policy.FeeRateMilliMsat = max(1, policy.FeeRateMilliMsat*int64(80+s.rng.Intn(46))/100)
+		if s.rng.Intn(4) == 0 {
+			policy.FeeBaseMsat = int64(s.rng.Intn(3)) * 500
+		}
+		policy.LastUpdate = now
+		update.ChannelEdgeUpdates = append(update.ChannelEdgeUpdates, edgeUpdate(edge, policy, from, to))
+
+	case roll < 80:
+		edge := &s.edges[s.rng.Intn(len(s.edges))]
+		policy, from, to := s.direction(edge)
+		policy.Disabled = !policy.Disabled
+		policy.LastUpdate = now
+		update.ChannelEdgeUpdates = append(update.ChannelEdgeUpdates, edgeUpdate(edge, policy, from, to))
+
+	case roll < 90:
+		node := &s.nodes[s.rng.Intn(len(s.nodes))]
+		node.LastUpdate = now
+		update.NodeUpdates = append(update.NodeUpdates, lndclient.NodeUpdate{
+			IdentityKey: node.PubKey, Alias: node.Alias, Color: node.Color, Addresses: node.Addresses,
+		})
+
+	case roll < 97 || len(s.edges) <= s.minEdges:
+		i := s.rng.Intn(len(s.nodes))
+		j := (i + 1 + s.rng.Intn(len(s.nodes)-1)) % len(s.nodes)
+		scid := lnwire.ShortChannelID{BlockHeight: s.nextHeight, TxIndex: uint32(s.rng.Intn(3000)), TxPosition: uint16(s.rng.Intn(2))}
+		s.nextHeight++
+		capacity := btcutil.Amount(1+s.rng.Intn(100)) * 100_000
+		newPolicy := func() *lndclient.RoutingPolicy {
+			return &lndclient.RoutingPolicy{TimeLockDelta: 80, MinHtlcMsat: 1000, MaxHtlcMsat: uint64(capacity) * 990,
+				FeeBaseMsat: 1000, FeeRateMilliMsat: int64(1 + s.rng.Intn(1000)), LastUpdate: now}
+		}
+		s.edges = append(s.edges, lndclient.ChannelEdge{ChannelID: scid.ToUint64(), Capacity: capacity,
+			Node1: s.nodes[i].PubKey, Node2: s.nodes[j].PubKey, Node1Policy: newPolicy(), Node2Policy: newPolicy()})
+		edge := &s.edges[len(s.edges)-1]
+		update.ChannelEdgeUpdates = append(update.ChannelEdgeUpdates,
+			edgeUpdate(edge, edge.Node1Policy, edge.Node1, edge.Node2),
+			edgeUpdate(edge, edge.Node2Policy, edge.Node2, edge.Node1))
+
+	default:
+		i := s.rng.Intn(len(s.edges))
+		edge := s.edges[i]
+		s.edges = append(s.edges[:i], s.edges[i+1:]...)
+		update.ChannelCloseUpdates = append(update.ChannelCloseUpdates, lndclient.ChannelCloseUpdate{
+			ChannelID: lnwire.NewShortChanIDFromInt(edge.ChannelID), Capacity: edge.Capacity, ClosedHeight: s.nextHeight,
+		})
+	}
+	return update
+}
+
+// direction picks a random direction of a channel, returning its policy and
+// the advertising and connecting nodes.
+func (s *Source) direction(edge *lndclient.ChannelEdge) (*lndclient.RoutingPolicy, route.Vertex, route.Vertex) {
+	if s.rng.Intn(2) == 0 {
+		return edge.Node1Policy, edge.Node1, edge.Node2
+	}
+	return edge.Node2Policy, edge.Node2, edge.Node1
+}
+
+// edgeUpdate builds the update announcing one direction of a channel.
+func edgeUpdate(edge *lndclient.ChannelEdge, policy *lndclient.RoutingPolicy, from, to route.Vertex) lndclient.ChannelEdgeUpdate {
+	return lndclient.ChannelEdgeUpdate{
+		ChannelID:       lnwire.NewShortChanIDFromInt(edge.ChannelID),
+		Capacity:        edge.Capacity,
+		RoutingPolicy:   *policy,
+		AdvertisingNode: from,
+		ConnectingNode:  to,
+	}
+}
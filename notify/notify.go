@@ -0,0 +1,38 @@
+// Package notify delivers ln-stream events, such as changes to watched nodes,
+// to external services.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// client is used for all deliveries; the timeout keeps a slow receiver from
+// piling up goroutines.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Webhook returns a function that POSTs each event as JSON to url. Delivery
+// happens in the background so callers never block; failures are logged.
+func Webhook(url string) func(event interface{}) {
+	return func(event interface{}) {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to encode webhook event: %v", err)
+			return
+		}
+		go func() {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				log.Printf("Failed to deliver webhook: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Printf("Webhook returned %s", resp.Status)
+			}
+		}()
+	}
+}
@@ -0,0 +1,358 @@
+// Package p2p syncs gossip directly from Lightning peers over the BOLT 8
+// transport, without a local LND or CLN node. Announcements are verified,
+// converted into graph topology updates, and handed to a callback that
+// writes them to Memgraph.
+package p2p
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/brontide"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"ln-stream/gossip"
+)
+
+// Lightning wire message types used by the sync protocol.
+const (
+	msgInit                  = 16
+	msgPing                  = 18
+	msgPong                  = 19
+	msgGossipTimestampFilter = 265
+
+	// featureGossipQueriesOptional is the optional gossip_queries feature bit,
+	// which peers require before honoring a gossip_timestamp_filter.
+	featureGossipQueriesOptional = 7
+
+	dialTimeout    = 30 * time.Second
+	reconnectDelay = time.Minute
+)
+
+// Peer is a remote Lightning node to sync gossip from.
+type Peer struct {
+	PubKey  *btcec.PublicKey
+	Address string
+}
+
+// String returns the peer in pubkey@host:port form.
+func (p Peer) String() string {
+	return hex.EncodeToString(p.PubKey.SerializeCompressed()) + "@" + p.Address
+}
+
+// ParsePeers parses a comma-separated list of pubkey@host:port peers.
+func ParsePeers(list string) ([]Peer, error) {
+	var peers []Peer
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pubKeyHex, address, ok := strings.Cut(entry, "@")
+		if !ok {
+			return nil, fmt.Errorf("peer %q is not in pubkey@host:port form", entry)
+		}
+		raw, err := hex.DecodeString(pubKeyHex)
+		if err != nil {
+			return nil, fmt.Errorf("peer %q: invalid pubkey: %w", entry, err)
+		}
+		pubKey, err := btcec.ParsePubKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("peer %q: invalid pubkey: %w", entry, err)
+		}
+		peers = append(peers, Peer{PubKey: pubKey, Address: address})
+	}
+	return peers, nil
+}
+
+// ChainHash returns the BOLT chain_hash (genesis block hash) for a network name
+// as used in LND_NETWORK.
+func ChainHash(network string) ([32]byte, error) {
+	params := map[string]*chaincfg.Params{
+		"mainnet": &chaincfg.MainNetParams,
+		"testnet": &chaincfg.TestNet3Params,
+		"signet":  &chaincfg.SigNetParams,
+		"regtest": &chaincfg.RegressionNetParams,
+		"simnet":  &chaincfg.SimNetParams,
+	}[network]
+	if params == nil {
+		return [32]byte{}, fmt.Errorf("unknown network %q", network)
+	}
+	return *params.GenesisHash, nil
+}
+
+// Syncer maintains gossip sessions with a set of peers and forwards every
+// verified announcement as a graph topology update.
+type Syncer struct {
+	peers     []Peer
+	chainHash [32]byte
+	apply     func(*lndclient.GraphTopologyUpdate)
+
+	// mu protects channels, which maps short channel IDs to their verified
+	// announcements so that channel_updates can be attributed to a node,
+	// and capacities, the estimated capacity of each channel in sats.
+	mu         sync.Mutex
+	channels   map[uint64]*gossip.ChannelAnnouncement
+	capacities map[uint64]uint64
+}
+
+// NewSyncer creates a syncer for the given peers and chain. apply is called
+// for every verified update, possibly from several goroutines at once.
+func NewSyncer(peers []Peer, chainHash [32]byte, apply func(*lndclient.GraphTopologyUpdate)) *Syncer {
+	return &Syncer{
+		peers:      peers,
+		chainHash:  chainHash,
+		apply:      apply,
+		channels:   map[uint64]*gossip.ChannelAnnouncement{},
+		capacities: map[uint64]uint64{},
+	}
+}
+
+// Run syncs from every peer until stop is closed, reconnecting after failures.
+func (s *Syncer) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, peer := range s.peers {
+		wg.Add(1)
+		go func(peer Peer) {
+			defer wg.Done()
+			for {
+				if err := s.syncPeer(peer, stop); err != nil {
+					log.Printf("Gossip sync with %s failed: %v", peer, err)
+				}
+				select {
+				case <-stop:
+					return
+				case <-time.After(reconnectDelay):
+				}
+			}
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// syncPeer connects to a single peer, requests all gossip via a
+// gossip_timestamp_filter, and processes messages until the connection fails
+// or stop is closed.
+func (s *Syncer) syncPeer(peer Peer, stop <-chan struct{}) error {
+	// An ephemeral identity is sufficient; peers only need it for the handshake.
+	localKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	addr, err := net.ResolveTCPAddr("tcp", peer.Address)
+	if err != nil {
+		return fmt.Errorf("failed to resolve address: %w", err)
+	}
+	conn, err := brontide.Dial(&keychain.PrivKeyECDH{PrivKey: localKey},
+		&lnwire.NetAddress{IdentityKey: peer.PubKey, Address: addr}, dialTimeout, net.DialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the read loop when we are asked to stop.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := writeMessage(conn, initMessage()); err != nil {
+		return fmt.Errorf("failed to send init: %w", err)
+	}
+	if err := writeMessage(conn, s.timestampFilterMessage()); err != nil {
+		return fmt.Errorf("failed to send gossip_timestamp_filter: %w", err)
+	}
+	log.Printf("Syncing gossip from %s", peer)
+
+	for {
+		msg, err := conn.ReadNextMessage()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return fmt.Errorf("failed to read message: %w", err)
+			}
+		}
+		if len(msg) < 2 {
+			continue
+		}
+
+		switch binary.BigEndian.Uint16(msg[:2]) {
+		case msgPing:
+			pong := pongMessage(msg[2:])
+			if pong == nil {
+				continue
+			}
+			if err := writeMessage(conn, pong); err != nil {
+				return fmt.Errorf("failed to send pong: %w", err)
+			}
+		case gossip.MsgChannelAnnouncement, gossip.MsgChannelUpdate, gossip.MsgNodeAnnouncement:
+			if err := s.handleGossip(msg); err != nil {
+				log.Printf("Dropping gossip from %s: %v", peer, err)
+			}
+		}
+	}
+}
+
+// handleGossip verifies a gossip message and forwards it as a topology update.
+// Channel announcements are only recorded; the channel is written once its
+// first channel_update arrives, since that carries the routing policy.
+func (s *Syncer) handleGossip(msg []byte) error {
+	parsed, err := gossip.Parse(msg)
+	if err != nil {
+		return err
+	}
+
+	switch m := parsed.(type) {
+	case *gossip.ChannelAnnouncement:
+		if m.ChainHash != s.chainHash {
+			return fmt.Errorf("channel %d is for another chain", m.ShortChannelID)
+		}
+		if err := m.Verify(); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.channels[m.ShortChannelID] = m
+		s.mu.Unlock()
+
+	case *gossip.ChannelUpdate:
+		s.mu.Lock()
+		announcement, ok := s.channels[m.ShortChannelID]
+		s.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("channel_update for unknown channel %d", m.ShortChannelID)
+		}
+		if err := m.Verify(announcement); err != nil {
+			return err
+		}
+		update := edgeUpdate(announcement, m)
+		update.Capacity = btcutil.Amount(s.estimateCapacity(m))
+		s.apply(&lndclient.GraphTopologyUpdate{
+			ChannelEdgeUpdates: []lndclient.ChannelEdgeUpdate{update},
+		})
+
+	case *gossip.NodeAnnouncement:
+		if err := m.Verify(); err != nil {
+			return err
+		}
+		s.apply(&lndclient.GraphTopologyUpdate{
+			NodeUpdates: []lndclient.NodeUpdate{{
+				Addresses:   m.Addresses,
+				IdentityKey: route.Vertex(m.NodeID),
+				Alias:       m.AliasString(),
+				Color:       "#" + hex.EncodeToString(m.RGBColor[:]),
+				Features:    featureBits(m.Features),
+			}},
+		})
+	}
+	return nil
+}
+
+// estimateCapacity returns a channel's capacity in sats as far as gossip
+// reveals it. Gossip does not carry the funding amount, and there is no
+// chain backend to look it up, so the largest htlc_maximum_msat announced
+// for either direction is used: a lower bound, often equal to the capacity.
+func (s *Syncer) estimateCapacity(update *gossip.ChannelUpdate) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	capacity := max(s.capacities[update.ShortChannelID], update.HtlcMaximumMsat/1000)
+	s.capacities[update.ShortChannelID] = capacity
+	return capacity
+}
+
+// featureBits returns the bits set in a raw feature bitfield.
+func featureBits(features []byte) []lnwire.FeatureBit {
+	var bits []lnwire.FeatureBit
+	for i := range features {
+		b := features[len(features)-1-i]
+		for j := 0; j < 8; j++ {
+			if b&(1<<j) != 0 {
+				bits = append(bits, lnwire.FeatureBit(i*8+j))
+			}
+		}
+	}
+	return bits
+}
+
+// edgeUpdate converts a verified channel_update into the update type produced
+// by LND's graph subscription, so it can share the memgraph write path.
+func edgeUpdate(announcement *gossip.ChannelAnnouncement, update *gossip.ChannelUpdate) lndclient.ChannelEdgeUpdate {
+	advertising, connecting := announcement.NodeID1, announcement.NodeID2
+	if update.Direction() == 1 {
+		advertising, connecting = connecting, advertising
+	}
+	return lndclient.ChannelEdgeUpdate{
+		ChannelID: lnwire.NewShortChanIDFromInt(update.ShortChannelID),
+		RoutingPolicy: lndclient.RoutingPolicy{
+			TimeLockDelta:    uint32(update.CltvExpiryDelta),
+			MinHtlcMsat:      int64(update.HtlcMinimumMsat),
+			MaxHtlcMsat:      update.HtlcMaximumMsat,
+			FeeBaseMsat:      int64(update.FeeBaseMsat),
+			FeeRateMilliMsat: int64(update.FeeProportionalMillionths),
+			Disabled:         update.Disabled(),
+			LastUpdate:       time.Unix(int64(update.Timestamp), 0),
+		},
+		AdvertisingNode: route.Vertex(advertising),
+		ConnectingNode:  route.Vertex(connecting),
+	}
+}
+
+// writeMessage sends a single wire message over the encrypted connection.
+func writeMessage(conn *brontide.Conn, msg []byte) error {
+	if err := conn.WriteMessage(msg); err != nil {
+		return err
+	}
+	_, err := conn.Flush()
+	return err
+}
+
+// initMessage builds an init message advertising only gossip_queries.
+func initMessage() []byte {
+	features := []byte{1 << featureGossipQueriesOptional}
+	msg := binary.BigEndian.AppendUint16(nil, msgInit)
+	msg = binary.BigEndian.AppendUint16(msg, 0) // no global features
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(features)))
+	return append(msg, features...)
+}
+
+// timestampFilterMessage builds a gossip_timestamp_filter asking for all
+// gossip, past and future.
+func (s *Syncer) timestampFilterMessage() []byte {
+	msg := binary.BigEndian.AppendUint16(nil, msgGossipTimestampFilter)
+	msg = append(msg, s.chainHash[:]...)
+	msg = binary.BigEndian.AppendUint32(msg, 0)          // first_timestamp
+	msg = binary.BigEndian.AppendUint32(msg, 0xFFFFFFFF) // timestamp_range
+	return msg
+}
+
+// pongMessage builds the reply to a ping, with as many padding bytes as
+// requested. It returns nil when BOLT 1 says the ping must not be answered.
+func pongMessage(ping []byte) []byte {
+	var numPongBytes uint16
+	if len(ping) >= 2 {
+		numPongBytes = binary.BigEndian.Uint16(ping[:2])
+	}
+	if numPongBytes >= 65532 {
+		return nil
+	}
+	msg := binary.BigEndian.AppendUint16(nil, msgPong)
+	msg = binary.BigEndian.AppendUint16(msg, numPongBytes)
+	return append(msg, make([]byte, numPongBytes)...)
+}
@@ -0,0 +1,172 @@
+// Package price fetches the price of bitcoin in a fiat currency from a public
+// price feed, so that amounts can be shown in fiat as well as in sats.
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SatsPerBTC is the number of satoshis in one bitcoin.
+const SatsPerBTC = 100_000_000
+
+// Quote is the price of one bitcoin at a point in time.
+type Quote struct {
+	// Currency is the lowercase ISO 4217 code of the fiat currency, e.g. usd.
+	Currency string    `json:"currency"`
+	BTC      float64   `json:"btc_price"`
+	At       time.Time `json:"priced_at"`
+	Provider string    `json:"provider"`
+}
+
+// provider knows where to ask for a price and how to read the answer.
+type provider struct {
+	// url returns the request URL for a currency, given the base URL.
+	url  func(base, currency string) string
+	base string
+	// parse returns the price from a response body.
+	parse func(body []byte, currency string) (float64, error)
+}
+
+// providers are the supported price feeds by name.
+var providers = map[string]provider{
+	"coingecko": {
+		base: "https://api.coingecko.com",
+		url: func(base, currency string) string {
+			return base + "/api/v3/simple/price?ids=bitcoin&vs_currencies=" + currency
+		},
+		parse: func(body []byte, currency string) (float64, error) {
+			var resp map[string]map[string]float64
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return 0, err
+			}
+			p, ok := resp["bitcoin"][currency]
+			if !ok {
+				return 0, fmt.Errorf("no %s price in response", currency)
+			}
+			return p, nil
+		},
+	},
+	"coinbase": {
+		base: "https://api.coinbase.com",
+		url: func(base, currency string) string {
+			return base + "/v2/prices/BTC-" + strings.ToUpper(currency) + "/spot"
+		},
+		parse: func(body []byte, currency string) (float64, error) {
+			var resp struct {
+				Data struct {
+					Amount string `json:"amount"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return 0, err
+			}
+			return strconv.ParseFloat(resp.Data.Amount, 64)
+		},
+	},
+	"mempool": {
+		base: "https://mempool.space",
+		url: func(base, currency string) string {
+			return base + "/api/v1/prices"
+		},
+		parse: func(body []byte, currency string) (float64, error) {
+			var resp map[string]json.RawMessage
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return 0, err
+			}
+			raw, ok := resp[strings.ToUpper(currency)]
+			if !ok {
+				return 0, fmt.Errorf("no %s price in response", currency)
+			}
+			var p float64
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return 0, err
+			}
+			return p, nil
+		},
+	},
+}
+
+// Feed fetches quotes from one provider and caches them for a while, so that
+// frequent conversions do not run into the provider's rate limits.
+type Feed struct {
+	name     string
+	provider provider
+	base     string
+	currency string
+	ttl      time.Duration
+	client   *http.Client
+
+	mu     sync.Mutex
+	cached Quote
+}
+
+// New returns a feed for the named provider (coingecko, coinbase or mempool)
+// and currency, whose quotes are reused for ttl. base replaces the provider's
+// default URL, e.g. for a self-hosted mempool instance, if not empty. No
+// request is made.
+func New(name, currency, base string, ttl time.Duration) (*Feed, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown price provider %q (want coingecko, coinbase or mempool)", name)
+	}
+	currency = strings.ToLower(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return nil, fmt.Errorf("invalid currency %q", currency)
+	}
+	if base == "" {
+		base = p.base
+	}
+	return &Feed{
+		name:     name,
+		provider: p,
+		base:     strings.TrimSuffix(base, "/"),
+		currency: currency,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Quote returns the current price of bitcoin, fetching it if the cached
+// quote is older than the feed's ttl.
+func (f *Feed) Quote(ctx context.Context) (Quote, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.cached.At.IsZero() && time.Since(f.cached.At) < f.ttl {
+		return f.cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.provider.url(f.base, f.currency), nil)
+	if err != nil {
+		return Quote{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to fetch price from %s: %w", f.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Quote{}, fmt.Errorf("failed to fetch price from %s: %s", f.name, resp.Status)
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Quote{}, fmt.Errorf("failed to read price from %s: %w", f.name, err)
+	}
+	p, err := f.provider.parse(body, f.currency)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to read price from %s: %w", f.name, err)
+	}
+	if p <= 0 {
+		return Quote{}, fmt.Errorf("%s returned a non-positive price", f.name)
+	}
+
+	f.cached = Quote{Currency: f.currency, BTC: p, At: time.Now().UTC(), Provider: f.name}
+	return f.cached, nil
+}
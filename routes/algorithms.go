@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// algorithms is the whitelist of MAGE procedures that can be run through
+// the API, loaded once at startup.
+var algorithms = map[string]memgraph.Algorithm{}
+
+// LoadAlgorithms reads the algorithm whitelist from ALGORITHMS_FILE (default
+// ./algorithms.json). A missing file leaves the whitelist empty.
+func LoadAlgorithms() error {
+	path := envOrDefault("ALGORITHMS_FILE", "./algorithms.json")
+	loaded, err := memgraph.LoadAlgorithms(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No algorithms file at %s; the algorithm runner is disabled", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	algorithms = loaded
+	log.Printf("Loaded %d algorithms from %s", len(algorithms), path)
+	return nil
+}
+
+// ListAlgorithmsHandler returns the configured algorithms, sorted by name.
+func ListAlgorithmsHandler(c *gin.Context) {
+	list := make([]memgraph.Algorithm, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		list = append(list, algorithm)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	c.JSON(http.StatusOK, list)
+}
+
+// RunAlgorithmHandler runs a configured algorithm on the selected namespace
+// and writes its results to node properties. The optional JSON body maps
+// parameter names to values overriding the configured defaults. Takes the
+// operation lock since it modifies the graph.
+func RunAlgorithmHandler(c *gin.Context) {
+	algorithm, ok := algorithms[c.Param("name")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown algorithm"})
+		return
+	}
+	values := map[string]interface{}{}
+	if c.Request.ContentLength != 0 {
+		decoder := json.NewDecoder(c.Request.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&values); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+	}
+
+	if !beginOperation(c, "algorithm") {
+		return
+	}
+	defer endOperation()
+
+	namespace := namespaceParam(c)
+	updated, err := memgraph.RunAlgorithm(Driver, namespace, algorithm, values)
+	if errors.Is(err, memgraph.ErrInvalidParameter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Ran algorithm %s on namespace %q, updating %d nodes", algorithm.Name, namespace, updated)
+	c.JSON(http.StatusOK, gin.H{"algorithm": algorithm.Name, "namespace": namespace, "updated": updated})
+}
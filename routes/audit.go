@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/middleware"
+)
+
+// auditKey marks a request as state-changing in its gin context; see
+// blockChange.
+const auditKey = "audit"
+
+// maxAuditError bounds how much of an error response is kept for the audit
+// log.
+const maxAuditError = 1024
+
+// AuditEntry records one state-changing request and its outcome.
+type AuditEntry struct {
+	At        time.Time `json:"at"`
+	RequestID string    `json:"request_id"`
+	ClientIP  string    `json:"client_ip"`
+	// User is the value of the AUDIT_USER_HEADER header (X-Forwarded-User by
+	// default), as set by an authenticating proxy, or the basic auth user.
+	User       string `json:"user,omitempty"`
+	Method     string `json:"method"`
+	Endpoint   string `json:"endpoint"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// auditMu serializes appends to the audit log.
+var auditMu sync.Mutex
+
+// auditFilePath returns where the audit log is kept. It defaults to
+// ./ln-stream-audit.jsonl and can be set with AUDIT_FILE.
+func auditFilePath() string {
+	return envOrDefault("AUDIT_FILE", "./ln-stream-audit.jsonl")
+}
+
+// errorCapture keeps the start of a response body so that the error message
+// of a failed request can be audited.
+type errorCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorCapture) Write(data []byte) (int, error) {
+	if room := maxAuditError - w.body.Len(); room > 0 {
+		w.body.Write(data[:min(room, len(data))])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorCapture) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Audit appends an entry to the audit log for every request that a handler
+// marked as state-changing, once the handler has finished. Requests that were
+// rejected, e.g. in read-only mode, are recorded too.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		capture := &errorCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+		c.Next()
+		if !c.GetBool(auditKey) {
+			return
+		}
+
+		user := c.GetHeader(envOrDefault("AUDIT_USER_HEADER", "X-Forwarded-User"))
+		if user == "" {
+			user, _, _ = c.Request.BasicAuth()
+		}
+		entry := AuditEntry{
+			At:         start.UTC(),
+			RequestID:  middleware.GetRequestID(c),
+			ClientIP:   c.ClientIP(),
+			User:       user,
+			Method:     c.Request.Method,
+			Endpoint:   c.Request.URL.RequestURI(),
+			Status:     c.Writer.Status(),
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if entry.Status >= http.StatusBadRequest {
+			var response struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(capture.body.Bytes(), &response) == nil && response.Error != "" {
+				entry.Error = response.Error
+			} else {
+				entry.Error = capture.body.String()
+			}
+		}
+		if err := appendAudit(entry); err != nil {
+			log.Printf("Failed to write audit log: %v", err)
+		}
+	}
+}
+
+// appendAudit writes an entry as one JSON line at the end of the audit log.
+func appendAudit(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(auditFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAudit returns the entries at or after since, newest first, at most
+// limit of them. A missing log has no entries; unreadable lines are skipped.
+func readAudit(since time.Time, limit int) ([]AuditEntry, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.Open(auditFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.At.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]AuditEntry, 0, min(limit, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, entries[i])
+	}
+	return result, nil
+}
+
+// AuditHandler returns the audit log of state-changing requests, newest
+// first. Filters: ?since= (unix seconds or RFC 3339) and ?limit= (default
+// 100, at most 10000). The log reveals client addresses, so it is not served
+// in read-only mode.
+func AuditHandler(c *gin.Context) {
+	if ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the audit log is not available on read-only instances"})
+		return
+	}
+	since, err := parseSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 10000"})
+		return
+	}
+	entries, err := readAudit(since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read audit log: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
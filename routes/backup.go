@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// backupSuffix ends the names of backup files.
+const backupSuffix = ".jsonl.gz"
+
+// BackupDir returns where database backups are written, ./backups unless
+// set with BACKUP_DIR.
+func BackupDir() string {
+	return envOrDefault("BACKUP_DIR", "./backups")
+}
+
+// validBackupName rejects backup names that would escape the backup
+// directory.
+func validBackupName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, backupSuffix) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	return nil
+}
+
+// BackupHandler writes the complete database contents, including derived
+// properties and bookkeeping such as watches and journals, to a new
+// timestamped file in the backup directory and returns its name.
+func BackupHandler(c *gin.Context) {
+	if !beginOperation(c, "backup") {
+		return
+	}
+	defer endOperation()
+
+	dir := BackupDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create backup directory: %v", err)})
+		return
+	}
+	name := "ln-stream-" + time.Now().UTC().Format("20060102T150405Z") + backupSuffix
+	path := filepath.Join(dir, name)
+	// Write to a temporary file first so a failed backup never shows up in the list.
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create backup: %v", err)})
+		return
+	}
+	stats, err := memgraph.WriteBackup(c.Request.Context(), Driver, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to write backup: %v", err)})
+		return
+	}
+	logf(c, "Backed up %d nodes and %d relationships to %s", stats.Nodes, stats.Relationships, path)
+	c.JSON(http.StatusOK, gin.H{"message": "Backup written.", "name": name, "backup": stats})
+}
+
+// ListBackupsHandler returns the backups in the backup directory, newest
+// first. Backups hold the whole database, including own-channel balances
+// and peers, so they are not served in read-only mode.
+func ListBackupsHandler(c *gin.Context) {
+	if ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "backups are not available on read-only instances"})
+		return
+	}
+	backups, err := listLocalFiles(BackupDir(), backupSuffix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// DownloadBackupHandler sends a backup file, e.g. to move it to another
+// instance. Not served in read-only mode, like ListBackupsHandler.
+func DownloadBackupHandler(c *gin.Context) {
+	if ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "backups are not available on read-only instances"})
+		return
+	}
+	name := c.Param("name")
+	if err := validBackupName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	path := filepath.Join(BackupDir(), name)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "backup not found"})
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// RestoreHandler replaces the complete database contents with a backup:
+// the one named by ?name= in the backup directory, or otherwise the request
+// body, so that a backup downloaded from another instance can be uploaded.
+// Live updates are stopped first, and watches and saved queries are
+// reloaded afterwards.
+func RestoreHandler(c *gin.Context) {
+	var backup io.Reader = c.Request.Body
+	if name := c.Query("name"); name != "" {
+		if err := validBackupName(name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		file, err := os.Open(filepath.Join(BackupDir(), name))
+		if errors.Is(err, fs.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "backup not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to open backup: %v", err)})
+			return
+		}
+		defer file.Close()
+		backup = file
+	}
+
+	if !beginOperation(c, "restore") {
+		return
+	}
+	defer endOperation()
+
+	logf(c, "Restore initiated, replacing all data")
+	stopRoutine()
+	stats, err := memgraph.RestoreBackup(c.Request.Context(), Driver, backup)
+	if errors.Is(err, memgraph.ErrInvalidBackup) && !errors.Is(err, memgraph.ErrPartialRestore) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore: %v", err)})
+		return
+	}
+	if err := memgraph.LoadWatches(Driver); err != nil {
+		logf(c, "Failed to reload watches: %v", err)
+	}
+	if err := LoadSavedQueries(); err != nil {
+		logf(c, "Failed to reload saved queries: %v", err)
+	}
+	refreshSummary(ActiveNamespace())
+	logf(c, "Restored %d nodes and %d relationships from a backup of %s", stats.Nodes, stats.Relationships,
+		stats.CreatedAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{"message": "Restore complete.", "backup": stats})
+}
@@ -0,0 +1,29 @@
+package routes
+
+import "testing"
+
+func TestValidBackupName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"backup-20240101T000000Z" + backupSuffix, true},
+		{"", false},
+		{backupSuffix, false},
+		{".hidden" + backupSuffix, false},
+		{"../backup" + backupSuffix, false},
+		{"../../etc/passwd" + backupSuffix, false},
+		{"dir/backup" + backupSuffix, false},
+		{"/tmp/backup" + backupSuffix, false},
+		{"..", false},
+		{"backup.jsonl", false},
+		{"backup.json", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validBackupName(test.name); (err == nil) != test.valid {
+				t.Errorf("validBackupName(%q) = %v, want valid %v", test.name, err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// openBootstrapSnapshot opens a snapshot given as a local path or an
+// http(s) URL.
+func openBootstrapSnapshot(ctx context.Context, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Bootstrap imports the describegraph snapshot at source, a path or an
+// http(s) URL, into the active namespace and runs post-import setup, but
+// only if no namespace holds a graph yet. It holds the operation lock, so
+// it shows up in the status as the "bootstrap" operation, and should be
+// called once at startup, after Driver is set.
+func Bootstrap(ctx context.Context, source string) error {
+	populated, err := memgraph.HasGraph(Driver)
+	if err != nil {
+		return err
+	}
+	if populated {
+		log.Printf("Memgraph already holds a graph, not bootstrapping from %s", source)
+		return nil
+	}
+
+	opMu.Lock()
+	stateMu.Lock()
+	currentOperation = "bootstrap"
+	stateMu.Unlock()
+	defer endOperation()
+
+	log.Printf("Memgraph is empty, bootstrapping from %s", source)
+	snapshot, err := openBootstrapSnapshot(ctx, source)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer snapshot.Close()
+	graph, err := lnd.DecodeSnapshot(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	network := graph.Network
+	if network == "" {
+		network = lndNetwork()
+	}
+	graph, report := lnd.ValidateSnapshot(graph)
+
+	namespace := ActiveNamespace()
+	if err := memgraph.SetNetwork(ctx, Driver, namespace, network); err != nil {
+		return err
+	}
+	if err := lnd.WriteSnapshotToMemgraph(ctx, graph, Driver, namespace); err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	if err := memgraph.SetupAfterImport(ctx, Driver, namespace); err != nil {
+		return fmt.Errorf("post-import setup failed: %w", err)
+	}
+	refreshSummary(namespace)
+	log.Printf("Bootstrapped namespace %q with %d nodes and %d channels (%d invalid records skipped)", namespace,
+		report.ValidNodes, report.ValidEdges, report.InvalidNodes+report.InvalidEdges)
+	return nil
+}
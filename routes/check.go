@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// ConsistencyCheckHandler scans the selected namespace for integrity problems
+// and returns a report. With ?fix=true, problems that can be repaired
+// automatically (dangling and duplicate edges) are fixed; this takes the
+// operation lock since it modifies the graph.
+func ConsistencyCheckHandler(c *gin.Context) {
+	fix := c.Query("fix") == "true"
+	if fix {
+		if !beginOperation(c, "check-fix") {
+			return
+		}
+		defer endOperation()
+	}
+
+	namespace := namespaceParam(c)
+	report, err := memgraph.CheckConsistency(Driver, namespace, fix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to check graph: %v", err)})
+		return
+	}
+	if fix {
+		logf(c, "Consistency check fixed problems in namespace %q", namespace)
+		refreshSummary(namespace)
+	}
+	c.JSON(http.StatusOK, report)
+}
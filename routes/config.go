@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/gossip"
+)
+
+// configVar is an environment variable ln-stream reads. Default is what
+// applies when it is unset, "" if the feature is off. Values of secret
+// variables are never returned.
+type configVar struct {
+	Name    string
+	Default string
+	Secret  bool
+}
+
+// configVars lists the environment variables that configure ln-stream.
+var configVars = []configVar{
+	{Name: "PROFILE"},
+	{Name: "POLAR_NODE_DIR"},
+	{Name: "NEO4J_HOST"},
+	{Name: "NEO4J_PORT"},
+	{Name: "NEO4J_USERNAME"},
+	{Name: "NEO4J_PASSWORD", Secret: true},
+	{Name: "NEO4J_CONNECT_TIMEOUT", Default: "1m"},
+	{Name: "NEO4J_HEALTH_INTERVAL", Default: "30s"},
+	{Name: "LND_ADDRESS"},
+	{Name: "LND_NETWORK", Default: "mainnet"},
+	{Name: "LND_MACAROON_PATH"},
+	{Name: "LND_MACAROON_HEX", Secret: true},
+	{Name: "LND_TLS_CERT_PATH"},
+	{Name: "LND_TLS_SKIP_VERIFY", Default: "false"},
+	{Name: "MOCK_LND", Default: "false"},
+	{Name: "MOCK_UPDATE_INTERVAL"},
+	{Name: "READ_ONLY", Default: "false"},
+	{Name: "DEFAULT_NAMESPACE", Default: "default"},
+	{Name: "STATE_FILE", Default: "./ln-stream-state.json"},
+	{Name: "AUDIT_FILE", Default: "./ln-stream-audit.jsonl"},
+	{Name: "AUDIT_USER_HEADER", Default: "X-Forwarded-User"},
+	{Name: "BOOTSTRAP_SNAPSHOT"},
+	{Name: "SNAPSHOT_DIR", Default: "./snapshots"},
+	{Name: "BACKUP_DIR", Default: "./backups"},
+	{Name: "DUMP_INTERVAL"},
+	{Name: "DUMP_DIR"},
+	{Name: "DUMP_NAMESPACE"},
+	{Name: "DUMP_KEEP_LAST"},
+	{Name: "DUMP_MAX_AGE"},
+	{Name: "JOURNAL_KEEP_LAST"},
+	{Name: "JOURNAL_MAX_AGE"},
+	{Name: "S3_BUCKET"},
+	{Name: "S3_ENDPOINT"},
+	{Name: "S3_REGION", Default: "us-east-1"},
+	{Name: "S3_PREFIX", Default: "snapshots/"},
+	{Name: "S3_PATH_STYLE"},
+	{Name: "S3_ACCESS_KEY_ID", Secret: true},
+	{Name: "S3_SECRET_ACCESS_KEY", Secret: true},
+	{Name: "S3_SESSION_TOKEN", Secret: true},
+	{Name: "CLN_LISTNODES_PATH", Default: "./listnodes.json"},
+	{Name: "CLN_LISTCHANNELS_PATH", Default: "./listchannels.json"},
+	{Name: "CLN_GOSSIP_STORE_PATH", Default: "./gossip_store"},
+	{Name: "GOSSIP_VERIFY", Default: "off"},
+	{Name: "CAPTURE_DIR"},
+	{Name: "CAPTURE_ROTATE", Default: "1h"},
+	{Name: "CAPTURE_MAX_MB", Default: "0"},
+	{Name: "CAPTURE_COMPRESS", Default: "false"},
+	{Name: "P2P_PEERS"},
+	{Name: "P2P_NAMESPACE"},
+	{Name: "WRITE_BATCH_SIZE", Default: "100"},
+	{Name: "WRITE_CONCURRENCY", Default: "4"},
+	{Name: "WRITE_CHUNK_SIZE", Default: "10000"},
+	{Name: "GRAPH_PULL_TIMEOUT", Default: "10m"},
+	{Name: "GRAPH_PULL_ATTEMPTS", Default: "3"},
+	{Name: "GRAPH_CACHE_FILE", Default: "./ln-stream-graph-cache.gob.gz"},
+	{Name: "UPDATE_QUEUE_WARN", Default: "10000"},
+	{Name: "BETWEENNESS_SAMPLES", Default: "0"},
+	{Name: "METRICS_REFRESH_INTERVAL", Default: "5m"},
+	{Name: "CENTRALITY_REFRESH_INTERVAL", Default: "1h"},
+	{Name: "STALE_TTL"},
+	{Name: "STALE_CHECK_INTERVAL", Default: "1h"},
+	{Name: "STALE_ACTION", Default: "flag"},
+	{Name: "PROBE_INTERVAL"},
+	{Name: "PROBE_TIMEOUT", Default: "10s"},
+	{Name: "PROBE_CONCURRENCY", Default: "50"},
+	{Name: "TOR_SOCKS_PROXY"},
+	{Name: "OWN_CHANNELS_INTERVAL", Default: "1m"},
+	{Name: "FORWARDS_INTERVAL", Default: "1m"},
+	{Name: "ENTITIES_FILE"},
+	{Name: "WATCH_WEBHOOK_URL", Secret: true},
+	{Name: "ALGORITHMS_FILE", Default: "./algorithms.json"},
+	{Name: "QUERIES_FILE", Default: "./queries.json"},
+	{Name: "PRICE_PROVIDER"},
+	{Name: "PRICE_CURRENCY", Default: "usd"},
+	{Name: "PRICE_URL", Secret: true},
+	{Name: "PRICE_TTL", Default: "5m"},
+	{Name: "RECOMMEND_TARGETS"},
+}
+
+// lndNetworks are the values LND_NETWORK accepts.
+var lndNetworks = []string{"mainnet", "testnet", "signet", "regtest", "simnet"}
+
+// ValidateConfig checks the environment for settings ln-stream cannot start
+// with, such as a missing Memgraph host, an invalid port or an LND address
+// without credentials, and returns all problems found at once. Settings of
+// optional features are checked where they are applied.
+func ValidateConfig() error {
+	var problems []error
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	fileExists := func(name string) {
+		if path := os.Getenv(name); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				fail("%s: cannot read %s: %v", name, path, errors.Unwrap(err))
+			}
+		}
+	}
+	isBool := func(name string) {
+		if v := os.Getenv(name); v != "" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				fail("%s must be true or false, got %q", name, v)
+			}
+		}
+	}
+
+	if os.Getenv("NEO4J_HOST") == "" {
+		fail("NEO4J_HOST is not set")
+	}
+	if port := os.Getenv("NEO4J_PORT"); port == "" {
+		fail("NEO4J_PORT is not set")
+	} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		fail("NEO4J_PORT must be a port number between 1 and 65535, got %q", port)
+	}
+
+	if network := os.Getenv("LND_NETWORK"); network != "" && !contains(lndNetworks, network) {
+		fail("LND_NETWORK must be one of %s, got %q", strings.Join(lndNetworks, ", "), network)
+	}
+	if address := os.Getenv("LND_ADDRESS"); address != "" {
+		if _, port, err := net.SplitHostPort(address); err != nil {
+			fail("LND_ADDRESS must be host:port, got %q", address)
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			fail("LND_ADDRESS has an invalid port %q", port)
+		}
+		if macaroon := os.Getenv("LND_MACAROON_HEX"); macaroon != "" {
+			if _, err := hex.DecodeString(macaroon); err != nil {
+				fail("LND_MACAROON_HEX is not valid hex")
+			}
+		} else if os.Getenv("LND_MACAROON_PATH") == "" {
+			fail("LND_ADDRESS is set, but neither LND_MACAROON_PATH nor LND_MACAROON_HEX is")
+		} else {
+			fileExists("LND_MACAROON_PATH")
+		}
+		if skip, _ := strconv.ParseBool(os.Getenv("LND_TLS_SKIP_VERIFY")); !skip {
+			if os.Getenv("LND_TLS_CERT_PATH") == "" {
+				fail("LND_ADDRESS is set, but LND_TLS_CERT_PATH is not (or set LND_TLS_SKIP_VERIFY=true)")
+			} else {
+				fileExists("LND_TLS_CERT_PATH")
+			}
+		}
+	}
+	for _, name := range []string{"LND_TLS_SKIP_VERIFY", "MOCK_LND", "READ_ONLY", "S3_PATH_STYLE", "CAPTURE_COMPRESS"} {
+		isBool(name)
+	}
+	if source := os.Getenv("BOOTSTRAP_SNAPSHOT"); source != "" &&
+		!strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		fileExists("BOOTSTRAP_SNAPSHOT")
+	}
+	if _, err := gossip.ParseVerifyMode(os.Getenv("GOSSIP_VERIFY")); err != nil {
+		fail("GOSSIP_VERIFY: %v", err)
+	}
+	fileExists("ENTITIES_FILE")
+	return errors.Join(problems...)
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigHandler returns the effective configuration: the value of every
+// setting, or its default if unset, with secrets redacted, and whether LND
+// is connected. Meant for debugging deployments.
+func ConfigHandler(c *gin.Context) {
+	settings := make(map[string]interface{}, len(configVars))
+	for _, v := range configVars {
+		value, set := os.LookupEnv(v.Name)
+		switch {
+		case !set || value == "":
+			if v.Default == "" {
+				settings[v.Name] = nil
+			} else {
+				settings[v.Name] = v.Default
+			}
+		case v.Secret:
+			settings[v.Name] = "<redacted>"
+		default:
+			settings[v.Name] = value
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"settings":       settings,
+		"lnd_connected":  LndServices != nil,
+		"graph_source":   Source != nil,
+		"read_only":      ReadOnly,
+		"active_dataset": ActiveNamespace(),
+		"archive":        ArchiveLocation(),
+	})
+}
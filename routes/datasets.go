@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// activeDatasetRequest is the body of an active dataset switch.
+type activeDatasetRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// ListDatasetsHandler returns the datasets stored in Memgraph, one per
+// namespace, and which one is active.
+func ListDatasetsHandler(c *gin.Context) {
+	datasets, err := memgraph.ListDatasets(Driver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	stateMu.RLock()
+	updating := ""
+	if isRoutineRunning {
+		updating = updatesNamespace
+	}
+	stateMu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"active": ActiveNamespace(), "updating": updating, "datasets": datasets})
+}
+
+// SetActiveDatasetHandler switches the dataset served to requests that do
+// not select a namespace. The switch is persisted with the routine state.
+// Live updates keep writing into the namespace they were started for.
+func SetActiveDatasetHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	var req activeDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	datasets, err := memgraph.ListDatasets(Driver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	found := req.Namespace == DefaultNamespace()
+	for _, d := range datasets {
+		found = found || d.Namespace == req.Namespace
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no dataset %q", req.Namespace)})
+		return
+	}
+
+	stateMu.Lock()
+	activeNamespace = req.Namespace
+	stateMu.Unlock()
+	saveState()
+	logf(c, "Active dataset switched to %q", req.Namespace)
+	c.JSON(http.StatusOK, gin.H{"message": "Active dataset switched.", "active": req.Namespace})
+}
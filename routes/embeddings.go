@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// Node2VecHandler computes node2vec embeddings for the selected namespace and
+// returns them as a download. ?format= is csv (default) or npy, which returns
+// a NumPy .npz archive of the embeddings and their pubkeys. The walk
+// parameters can be tuned with ?dimensions=, ?walk_length=, ?num_walks=, ?p=,
+// ?q= and ?directed=true.
+func Node2VecHandler(c *gin.Context) {
+	params := memgraph.DefaultNode2VecParams()
+	params.Directed = c.Query("directed") == "true"
+	for _, p := range []struct {
+		name  string
+		value *int
+		max   int
+	}{
+		{"dimensions", &params.Dimensions, 1024},
+		{"walk_length", &params.WalkLength, 1000},
+		{"num_walks", &params.NumWalks, 1000},
+	} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > p.max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be an integer between 1 and %d", p.name, p.max)})
+			return
+		}
+		*p.value = v
+	}
+	for _, p := range []struct {
+		name  string
+		value *float64
+	}{
+		{"p", &params.P},
+		{"q", &params.Q},
+	} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a positive number", p.name)})
+			return
+		}
+		*p.value = v
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "npy" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or npy"})
+		return
+	}
+
+	namespace := namespaceParam(c)
+	embeddings, err := memgraph.ComputeEmbeddings(Driver, namespace, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Computed %d node2vec embeddings for namespace %q", len(embeddings), namespace)
+
+	if format == "npy" {
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", namespace+"-node2vec.npz"))
+		err = memgraph.WriteEmbeddingsNPZ(c.Writer, embeddings)
+	} else {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", namespace+"-node2vec.csv"))
+		err = memgraph.WriteEmbeddingsCSV(c.Writer, embeddings)
+	}
+	if err != nil {
+		logf(c, "Failed to write embeddings: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// ReloadEntitiesHandler re-reads ENTITIES_FILE and re-tags the nodes of known
+// entities in every namespace, so that edits to the file take effect without
+// a restart or re-import.
+func ReloadEntitiesHandler(c *gin.Context) {
+	path := os.Getenv("ENTITIES_FILE")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ENTITIES_FILE not set"})
+		return
+	}
+	if !beginOperation(c, "reload-entities") {
+		return
+	}
+	defer endOperation()
+
+	loaded, err := memgraph.LoadEntities(path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tagged, err := memgraph.TagEntities(c.Request.Context(), Driver, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Reloaded %d entities, tagging %d nodes", loaded, tagged)
+	c.JSON(http.StatusOK, gin.H{"entities": loaded, "tagged": tagged})
+}
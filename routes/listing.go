@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// listFilterParams reads the filters shared by ListNodesHandler and
+// ListEdgesHandler: ?min_capacity= (sats), ?enabled=true, ?peer=true,
+// ?connectivity= (clearnet, tor, hybrid or none), ?updated_since= (unix
+// seconds or RFC 3339), ?features= (comma-separated feature bits that must
+// all be set), ?cursor= and ?limit= (default 1000, at most 10000).
+func listFilterParams(c *gin.Context) (memgraph.ListFilter, error) {
+	filter := memgraph.ListFilter{
+		Cursor:       c.Query("cursor"),
+		EnabledOnly:  c.Query("enabled") == "true",
+		PeersOnly:    c.Query("peer") == "true",
+		Connectivity: c.Query("connectivity"),
+	}
+	if filter.Connectivity != "" && !contains(lnd.ConnectivityClasses, filter.Connectivity) {
+		return filter, fmt.Errorf("connectivity must be one of %s, got %q", strings.Join(lnd.ConnectivityClasses, ", "), filter.Connectivity)
+	}
+	var err error
+	if v := c.Query("min_capacity"); v != "" {
+		if filter.MinCapacity, err = strconv.ParseInt(v, 10, 64); err != nil || filter.MinCapacity < 0 {
+			return filter, fmt.Errorf("min_capacity must be a non-negative integer, got %q", v)
+		}
+	}
+	since, err := parseSince(c.Query("updated_since"))
+	if err != nil {
+		return filter, fmt.Errorf("updated_since must be unix seconds or RFC 3339, got %q", c.Query("updated_since"))
+	}
+	if !since.IsZero() {
+		filter.UpdatedSince = since.Unix()
+	}
+	if v := c.Query("features"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			bit, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil || bit < 0 {
+				return filter, fmt.Errorf("features must be comma-separated feature bits, got %q", v)
+			}
+			filter.Features = append(filter.Features, bit)
+		}
+	}
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if err != nil || filter.Limit < 1 || filter.Limit > 10000 {
+		return filter, errors.New("limit must be an integer between 1 and 10000")
+	}
+	return filter, nil
+}
+
+// ListNodesHandler returns a page of the selected namespace's nodes, ordered
+// by pubkey. Pass the returned next_cursor as ?cursor= to get the next page.
+func ListNodesHandler(c *gin.Context) {
+	listGraph(c, memgraph.ListNodes)
+}
+
+// ListEdgesHandler returns a page of the selected namespace's channel
+// directions, ordered by channel ID. Pass the returned next_cursor as
+// ?cursor= to get the next page.
+func ListEdgesHandler(c *gin.Context) {
+	listGraph(c, memgraph.ListEdges)
+}
+
+// ExportDeltaHandler returns the selected namespace's nodes and channel
+// directions updated since ?since= (unix seconds or RFC 3339, required) and
+// the channels closed since then, so that consumers can sync incrementally.
+func ExportDeltaHandler(c *gin.Context) {
+	if c.Query("since") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since is required"})
+		return
+	}
+	since, err := parseSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	delta, err := memgraph.ExportDelta(Driver, namespaceParam(c), since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delta)
+}
+
+// listGraph responds with one page of a listing.
+func listGraph(c *gin.Context, list func(driver neo4j.Driver, namespace string, filter memgraph.ListFilter) (*memgraph.Page, error)) {
+	filter, err := listFilterParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := list(Driver, namespaceParam(c), filter)
+	if errors.Is(err, memgraph.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"ln-stream/memgraph"
+)
+
+// LiveCounters is the message pushed to control panel WebSocket clients.
+type LiveCounters struct {
+	Namespace        string    `json:"namespace"`
+	IsRoutineRunning bool      `json:"isRoutineRunning"`
+	Operation        string    `json:"operation"`
+	Nodes            int64     `json:"nodes"`
+	Channels         int64     `json:"channels"`
+	UpdatesApplied   int64     `json:"updatesApplied"`
+	LastUpdateAt     time.Time `json:"lastUpdateAt"`
+	SentAt           time.Time `json:"sentAt"`
+}
+
+var (
+	upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+	// liveMu protects liveClients, which maps each connected client's channel
+	// to the namespace it watches.
+	liveMu      sync.Mutex
+	liveClients = map[chan LiveCounters]string{}
+)
+
+// RunLiveCounters computes the live counters every interval, once per
+// namespace with connected clients, and pushes them to those clients until
+// stop is closed. Clients that are too slow to keep up skip a tick.
+func RunLiveCounters(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			liveMu.Lock()
+			clients := make(map[chan LiveCounters]string, len(liveClients))
+			for ch, namespace := range liveClients {
+				clients[ch] = namespace
+			}
+			liveMu.Unlock()
+			if len(clients) == 0 {
+				continue
+			}
+
+			counters := map[string]LiveCounters{}
+			for ch, namespace := range clients {
+				msg, ok := counters[namespace]
+				if !ok {
+					msg = liveCounters(namespace)
+					counters[namespace] = msg
+				}
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// liveCounters gathers the current counters for a namespace.
+func liveCounters(namespace string) LiveCounters {
+	nodes, channels, err := memgraph.CountGraph(Driver, namespace)
+	if err != nil {
+		log.Printf("Failed to count graph for live counters: %v", err)
+	}
+	applied, lastApplied := memgraph.AppliedUpdates()
+
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return LiveCounters{
+		Namespace:        namespace,
+		IsRoutineRunning: isRoutineRunning,
+		Operation:        currentOperation,
+		Nodes:            nodes,
+		Channels:         channels,
+		UpdatesApplied:   applied,
+		LastUpdateAt:     lastApplied,
+		SentAt:           time.Now(),
+	}
+}
+
+// LiveHandler upgrades the request to a WebSocket and streams LiveCounters
+// for the selected namespace until the client disconnects.
+func LiveHandler(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		logf(c, "WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	namespace := namespaceParam(c)
+	ch := make(chan LiveCounters, 1)
+	liveMu.Lock()
+	liveClients[ch] = namespace
+	liveMu.Unlock()
+	defer func() {
+		liveMu.Lock()
+		delete(liveClients, ch)
+		liveMu.Unlock()
+	}()
+
+	// Detect disconnects by reading until the connection fails; clients are
+	// not expected to send anything.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Send a first message right away instead of waiting for the next tick.
+	if err := conn.WriteJSON(liveCounters(namespace)); err != nil {
+		return
+	}
+	for {
+		select {
+		case msg := <-ch:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
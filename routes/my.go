@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// FeeSuggestionsHandler compares the fees of the connected node's channels
+// in the selected namespace with the competing channels into each peer, and
+// suggests new fees where they are far outside the competitive range.
+func FeeSuggestionsHandler(c *gin.Context) {
+	if LndServices == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "LND not configured"})
+		return
+	}
+	suggestions, err := memgraph.SuggestFees(Driver, namespaceParam(c), LndServices.NodePubkey.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to suggest fees: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// rebalanceRoutesRequest is the body of a rebalance route search. MaxRoutes
+// defaults to 5 and may be at most 20; a MaxFeePPM of 0 means no fee limit.
+type rebalanceRoutesRequest struct {
+	Outbound  []string `json:"outbound_channels"`
+	Inbound   []string `json:"inbound_channels"`
+	AmountSat int64    `json:"amount_sat"`
+	MaxFeePPM int64    `json:"max_fee_ppm"`
+	MaxRoutes int      `json:"max_routes"`
+}
+
+// RebalanceRoutesHandler finds the cheapest circular routes that move
+// amount_sat out of the connected node through one of the outbound channels
+// and back in through one of the inbound channels, over the selected
+// namespace's stored graph.
+func RebalanceRoutesHandler(c *gin.Context) {
+	if LndServices == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "LND not configured"})
+		return
+	}
+	var req rebalanceRoutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if len(req.Outbound) == 0 || len(req.Inbound) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "outbound_channels and inbound_channels must not be empty"})
+		return
+	}
+	if req.AmountSat <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount_sat must be positive"})
+		return
+	}
+	if req.MaxFeePPM < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_fee_ppm must not be negative"})
+		return
+	}
+	if req.MaxRoutes == 0 {
+		req.MaxRoutes = 5
+	}
+	if req.MaxRoutes < 1 || req.MaxRoutes > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_routes must be between 1 and 20"})
+		return
+	}
+
+	routes, err := memgraph.FindRebalanceRoutes(Driver, namespaceParam(c), LndServices.NodePubkey.String(),
+		req.Outbound, req.Inbound, req.AmountSat*1000, req.MaxFeePPM, req.MaxRoutes)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to find rebalance routes: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, routes)
+}
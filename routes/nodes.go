@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// GetNodeHandler returns the stored properties of a single node, including
+// derived metrics such as liveness_score and last_seen. With ?convert=true,
+// total_capacity is also given in BTC and fiat at the current price.
+func GetNodeHandler(c *gin.Context) {
+	quote, ok := quoteParam(c)
+	if !ok {
+		return
+	}
+	node, err := memgraph.GetNode(Driver, namespaceParam(c), c.Param("pubkey"))
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get node: %v", err)})
+		return
+	}
+	if capacity, ok := node["total_capacity"].(int64); ok && quote != nil {
+		node["values"] = convertAmounts(quote, map[string]float64{"total_capacity": float64(capacity)})
+	}
+	c.JSON(http.StatusOK, node)
+}
+
+// NodeChangesHandler returns the fee and disabled changes a node announced
+// for its channels, oldest first, from the policy change journal. Filters:
+// ?since= (unix seconds or RFC 3339) and ?limit= (default 1000, at most
+// 10000).
+func NodeChangesHandler(c *gin.Context) {
+	pubKey := c.Param("pubkey")
+	if !lnd.IsValidPubKey(pubKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pubkey must be 66 hex characters"})
+		return
+	}
+	since, err := parseSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if err != nil || limit < 1 || limit > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 10000"})
+		return
+	}
+
+	changes, err := memgraph.ListPolicyChanges(Driver, namespaceParam(c), pubKey, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+	"ln-stream/price"
+)
+
+// priceFeed converts amounts to BTC and fiat, or is nil if PRICE_PROVIDER is
+// not set.
+var priceFeed *price.Feed
+
+// ConfigurePrice enables the price feed if PRICE_PROVIDER is set (coingecko,
+// coinbase or mempool). PRICE_CURRENCY selects the fiat currency (default
+// usd), PRICE_URL replaces the provider's URL, e.g. with a self-hosted
+// mempool instance, and PRICE_TTL how long a price is reused (default 5m).
+// Imports then store fiat values of capacities.
+func ConfigurePrice() error {
+	provider := os.Getenv("PRICE_PROVIDER")
+	if provider == "" {
+		return nil
+	}
+	ttl := 5 * time.Minute
+	if v := os.Getenv("PRICE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("PRICE_TTL must be a positive duration, got %q", v)
+		}
+		ttl = d
+	}
+	feed, err := price.New(provider, envOrDefault("PRICE_CURRENCY", "usd"), os.Getenv("PRICE_URL"), ttl)
+	if err != nil {
+		return err
+	}
+	priceFeed = feed
+	memgraph.PriceQuote = feed.Quote
+	return nil
+}
+
+// amountValue is an amount converted to BTC and the quote's currency.
+type amountValue struct {
+	BTC  float64 `json:"btc"`
+	Fiat float64 `json:"fiat"`
+}
+
+// amountValues is added to responses as "values" when conversion is
+// requested: the quote used and the converted amounts, keyed by the name of
+// the field they were converted from.
+type amountValues struct {
+	price.Quote
+	Amounts map[string]amountValue `json:"amounts"`
+}
+
+// quoteParam returns the current quote if the request asks for amounts to be
+// converted with ?convert=true, or nil if it does not. It responds with an
+// error and returns false if no price feed is configured or the price cannot
+// be fetched.
+func quoteParam(c *gin.Context) (*price.Quote, bool) {
+	if c.Query("convert") != "true" {
+		return nil, true
+	}
+	if priceFeed == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no price feed configured (set PRICE_PROVIDER)"})
+		return nil, false
+	}
+	quote, err := priceFeed.Quote(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &quote, true
+}
+
+// convertAmounts converts amounts in sats with a quote, or returns nil if
+// quote is nil so that "values" is omitted.
+func convertAmounts(quote *price.Quote, sats map[string]float64) *amountValues {
+	if quote == nil {
+		return nil
+	}
+	values := &amountValues{Quote: *quote, Amounts: make(map[string]amountValue, len(sats))}
+	for field, amount := range sats {
+		btc := amount / price.SatsPerBTC
+		values.Amounts[field] = amountValue{BTC: btc, Fiat: btc * quote.BTC}
+	}
+	return values
+}
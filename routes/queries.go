@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+// LoadSavedQueries reads the saved queries from QUERIES_FILE (default
+// ./queries.json) and those registered through the API.
+func LoadSavedQueries() error {
+	path := envOrDefault("QUERIES_FILE", "./queries.json")
+	n, err := memgraph.LoadSavedQueries(Driver, path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d saved queries", n)
+	return nil
+}
+
+// ListQueriesHandler returns the saved queries, sorted by name.
+func ListQueriesHandler(c *gin.Context) {
+	queries := memgraph.ListSavedQueries()
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	c.JSON(http.StatusOK, queries)
+}
+
+// RunQueryHandler runs a saved query on the selected namespace. Query string
+// values other than ?namespace= and ?limit= (default 1000, at most 10000)
+// are passed as the query's parameters.
+func RunQueryHandler(c *gin.Context) {
+	query, ok := memgraph.LookupSavedQuery(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown query"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if err != nil || limit < 1 || limit > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 10000"})
+		return
+	}
+	values := map[string]string{}
+	for key, list := range c.Request.URL.Query() {
+		if key != "namespace" && key != "limit" && len(list) > 0 {
+			values[key] = list[0]
+		}
+	}
+
+	rows, truncated, err := memgraph.RunSavedQuery(Driver, namespaceParam(c), query, values, limit)
+	if errors.Is(err, memgraph.ErrInvalidParameter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"query": query.Name, "rows": rows, "truncated": truncated})
+}
+
+// RegisterQueryHandler registers or replaces a saved query. Queries from the
+// queries file cannot be replaced.
+func RegisterQueryHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	var req memgraph.SavedQuery
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	query, err := memgraph.RegisterQuery(c.Request.Context(), Driver, req)
+	if errors.Is(err, memgraph.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, memgraph.ErrQueryConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Registered saved query %s", query.Name)
+	c.JSON(http.StatusOK, query)
+}
+
+// DeleteQueryHandler removes a registered query. Queries from the queries
+// file cannot be removed.
+func DeleteQueryHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	name := c.Param("name")
+	err := memgraph.RemoveQuery(c.Request.Context(), Driver, name)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown query"})
+		return
+	}
+	if errors.Is(err, memgraph.ErrQueryConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Removed saved query %s", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Query removed."})
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// RecommendPeersHandler suggests peers for ?pubkey= to open channels with,
+// ranked by how much a channel would improve its centrality, by the peers'
+// fee levels and by how much closer it would bring payment targets. Targets
+// are given as comma-separated pubkeys with ?targets= and default to
+// RECOMMEND_TARGETS. ?limit= defaults to 10 and may be at most 100.
+func RecommendPeersHandler(c *gin.Context) {
+	pubKey := c.Query("pubkey")
+	if !lnd.IsValidPubKey(pubKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pubkey must be 66 hex characters"})
+		return
+	}
+	var targets []string
+	for _, target := range strings.Split(c.DefaultQuery("targets", os.Getenv("RECOMMEND_TARGETS")), ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		if !lnd.IsValidPubKey(target) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid target pubkey %q", target)})
+			return
+		}
+		targets = append(targets, target)
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 100"})
+		return
+	}
+
+	recommendations, err := memgraph.RecommendPeers(Driver, namespaceParam(c), pubKey, targets, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to recommend peers: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, recommendations)
+}
@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// RetentionPolicy limits how much of an archive is kept. A zero field does
+// not limit.
+type RetentionPolicy struct {
+	// KeepLast is the number of newest entries to keep.
+	KeepLast int
+	// MaxAge is the age beyond which entries are deleted.
+	MaxAge time.Duration
+}
+
+// MarshalJSON reports MaxAge as a duration string rather than nanoseconds.
+func (p RetentionPolicy) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{"keep_last": p.KeepLast, "max_age": nil}
+	if p.MaxAge > 0 {
+		out["max_age"] = p.MaxAge.String()
+	}
+	return json.Marshal(out)
+}
+
+// JournalRetention is the retention policy applied to the update journal,
+// reported by ArchiveStatusHandler.
+var JournalRetention RetentionPolicy
+
+// pruneDumps deletes the dumps of namespace that policy does not keep, from
+// the bucket if one is configured and from dir otherwise. Other snapshots in
+// the same place are never touched. Returns the number deleted.
+func pruneDumps(namespace, dir string, policy RetentionPolicy) (int, error) {
+	if policy.KeepLast == 0 && policy.MaxAge == 0 {
+		return 0, nil
+	}
+	var snapshots []SnapshotInfo
+	var err error
+	if archive != nil {
+		snapshots, err = listArchivedSnapshots()
+	} else {
+		snapshots, err = listLocalSnapshots(dir)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	type dump struct {
+		name  string
+		taken time.Time
+	}
+	var dumps []dump
+	for _, snapshot := range snapshots {
+		if taken, ok := lnd.ParseDumpTime(namespace, snapshot.Name); ok {
+			dumps = append(dumps, dump{snapshot.Name, taken})
+		}
+	}
+	sort.Slice(dumps, func(i, j int) bool { return dumps[i].taken.After(dumps[j].taken) })
+
+	cutoff := time.Now().Add(-policy.MaxAge)
+	deleted := 0
+	for i, d := range dumps {
+		keep := (policy.KeepLast == 0 || i < policy.KeepLast) && (policy.MaxAge == 0 || d.taken.After(cutoff))
+		if keep {
+			continue
+		}
+		if archive != nil {
+			err = archive.Delete(archivePrefix + d.name)
+		} else {
+			err = os.Remove(filepath.Join(dir, d.name))
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete dump %s: %w", d.name, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// archiveUsage summarizes the snapshots in one archive location.
+type archiveUsage struct {
+	Location  string `json:"location"`
+	Snapshots int    `json:"snapshots"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// usageOf totals the sizes of snapshots found at location.
+func usageOf(location string, snapshots []SnapshotInfo) archiveUsage {
+	usage := archiveUsage{Location: location, Snapshots: len(snapshots)}
+	for _, snapshot := range snapshots {
+		usage.Bytes += snapshot.Size
+	}
+	return usage
+}
+
+// ArchiveStatusHandler reports the size of the snapshot library and, if
+// automatic dumps go to a separate directory, of that directory, along with
+// the size of the update journal and the retention policies in effect.
+func ArchiveStatusHandler(c *gin.Context) {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	location := ArchiveLocation()
+	if location == "" {
+		location = SnapshotDir()
+	}
+	status := gin.H{"library": usageOf(location, snapshots)}
+
+	if Dumps != nil {
+		dumps := gin.H{"namespace": Dumps.Namespace, "interval": Dumps.Interval.String(), "retention": Dumps.Retention}
+		if archive == nil && filepath.Clean(Dumps.Dir) != filepath.Clean(SnapshotDir()) {
+			dumped, err := listLocalSnapshots(Dumps.Dir)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			dumps["usage"] = usageOf(Dumps.Dir, dumped)
+		}
+		status["dumps"] = dumps
+	}
+
+	journal, err := memgraph.GetJournalStats(Driver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status["journal"] = gin.H{"entries": journal.Entries, "oldest": journal.Oldest, "retention": JournalRetention}
+	c.JSON(http.StatusOK, status)
+}
@@ -1,45 +1,196 @@
 // Package routes defines the HTTP handlers for the ln-stream control panel.
-// Handlers are protected by a mutex to prevent concurrent graph operations.
+// Graph-mutating handlers are serialized by an operation lock; status and
+// read-only handlers never wait on it.
 package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lightninglabs/lndclient"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"ln-stream/cln"
+	"ln-stream/gossip"
 	"ln-stream/lnd"
 	"ln-stream/memgraph"
+	"ln-stream/middleware"
 )
 
 var (
 	// LndServices is the gRPC client for LND. Nil when running in snapshot-only mode.
 	LndServices *lndclient.GrpcLndServices
+	// Source provides graph resets and live updates: LND if connected, or
+	// the mock source in demo mode. Nil when running in snapshot-only mode.
+	Source lnd.GraphSource
 	// Driver is the Neo4j/Memgraph database connection.
 	Driver neo4j.Driver
+	// BaseContext is the parent of the contexts of background work started
+	// by handlers, such as the graph update subscription. main sets it to a
+	// context that is cancelled on shutdown.
+	BaseContext = context.Background()
+	// ReadOnly rejects every request that would modify the graph or stored
+	// data, so that an instance can be shared publicly. Queries, exports and
+	// status keep working, as do background updates configured at startup.
+	ReadOnly bool
 
-	// mu protects isRoutineRunning and stopChannel from concurrent access.
-	mu               sync.Mutex
+	// opMu serializes long-running graph operations (resets, imports, toggling
+	// updates). It is held for the whole operation, so it must never be taken
+	// by status or read-only handlers.
+	opMu sync.Mutex
+
+	// stateMu protects the fields below. It is only held briefly.
+	stateMu          sync.RWMutex
 	isRoutineRunning bool
 	stopChannel      chan struct{}
+	currentOperation string
+	// updatesNamespace is the namespace the update routine writes into.
+	updatesNamespace string
+	// activeNamespace is the dataset served to requests that do not select
+	// a namespace, or "" for DefaultNamespace.
+	activeNamespace string
+	// updateQueue buffers updates between the LND stream and Memgraph. Nil
+	// until the update routine has been started.
+	updateQueue *memgraph.UpdateQueue
 )
 
-// stopRoutine signals the graph update goroutine to stop. Must be called with mu held.
+// blockChange marks the request as state-changing for the audit log and, in
+// read-only mode, responds with 403 Forbidden and returns true. Handlers that
+// modify data without taking the operation lock call it directly;
+// beginOperation calls it for all others.
+func blockChange(c *gin.Context) bool {
+	c.Set(auditKey, true)
+	if !ReadOnly {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "this instance is read-only"})
+	return true
+}
+
+// beginOperation acquires the operation lock for the named operation. If
+// another operation is running it responds with 409 Conflict and returns false.
+// In read-only mode it responds with 403 Forbidden instead.
+func beginOperation(c *gin.Context, name string) bool {
+	if blockChange(c) {
+		return false
+	}
+	if !opMu.TryLock() {
+		stateMu.RLock()
+		running := currentOperation
+		stateMu.RUnlock()
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("operation %q already in progress", running)})
+		return false
+	}
+	stateMu.Lock()
+	currentOperation = name
+	stateMu.Unlock()
+	return true
+}
+
+// endOperation releases the operation lock taken by beginOperation.
+func endOperation() {
+	stateMu.Lock()
+	currentOperation = ""
+	stateMu.Unlock()
+	opMu.Unlock()
+}
+
+// stopRoutine signals the graph update goroutine to stop and persists the
+// new state.
 func stopRoutine() {
+	stateMu.Lock()
+	wasRunning := isRoutineRunning
 	if isRoutineRunning {
 		close(stopChannel)
 		isRoutineRunning = false
 	}
+	stateMu.Unlock()
+
+	if wasRunning {
+		saveState()
+	}
+}
+
+// stopRoutineFor stops the graph update routine if it writes into namespace.
+// Used before a namespace is dropped; routines feeding other namespaces keep
+// running.
+func stopRoutineFor(namespace string) {
+	stateMu.RLock()
+	affected := isRoutineRunning && updatesNamespace == namespace
+	stateMu.RUnlock()
+	if affected {
+		stopRoutine()
+	}
+}
+
+// logf writes a log line prefixed with the request's ID so that downstream
+// actions (e.g. a database drop) can be traced back to the triggering request.
+func logf(c *gin.Context, format string, args ...interface{}) {
+	log.Printf("request_id=%s "+format, append([]interface{}{middleware.GetRequestID(c)}, args...)...)
+}
+
+// envOrDefault returns the value of the environment variable key, or fallback if unset.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// DefaultNamespace returns the graph namespace used when a request does not
+// select one and no active dataset has been chosen. It defaults to "default"
+// and can be set with DEFAULT_NAMESPACE.
+func DefaultNamespace() string {
+	return envOrDefault("DEFAULT_NAMESPACE", "default")
+}
+
+// ActiveNamespace returns the namespace requests work on unless they select
+// one: the active dataset if one was chosen, or DefaultNamespace.
+func ActiveNamespace() string {
+	stateMu.RLock()
+	active := activeNamespace
+	stateMu.RUnlock()
+	if active == "" {
+		return DefaultNamespace()
+	}
+	return active
+}
+
+// namespaceParam returns the graph namespace a request targets, taken from
+// the ?namespace= query parameter, or the active dataset.
+func namespaceParam(c *gin.Context) string {
+	return c.DefaultQuery("namespace", ActiveNamespace())
+}
+
+// lndNetwork returns the network LND is configured for, defaulting to mainnet.
+func lndNetwork() string {
+	return envOrDefault("LND_NETWORK", "mainnet")
+}
+
+// snapshotNetwork picks the network of an imported snapshot: the ?network=
+// query parameter, then the snapshot's own metadata, then LND_NETWORK.
+func snapshotNetwork(c *gin.Context, graph *lnd.Graph) string {
+	if network := c.Query("network"); network != "" {
+		return network
+	}
+	if graph.Network != "" {
+		return graph.Network
+	}
+	return lndNetwork()
 }
 
-// requireLND checks that LND is configured and returns a 400 error if not.
-// Used to guard handlers that need a live LND connection.
-func requireLND(c *gin.Context) bool {
-	if LndServices == nil {
+// requireSource checks that a graph source (LND or the mock) is configured
+// and returns a 400 error if not. Used to guard handlers that pull the graph
+// or subscribe to updates.
+func requireSource(c *gin.Context) bool {
+	if Source == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "LND not configured"})
 		return false
 	}
@@ -47,117 +198,336 @@ func requireLND(c *gin.Context) bool {
 }
 
 // ToggleUpdatesHandler starts or stops the real-time graph update subscription.
-// Requires LND to be configured.
+// Updates are written into the namespace selected when starting. Requires LND
+// or the mock source to be configured.
 func ToggleUpdatesHandler(c *gin.Context) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if !requireLND(c) {
+	if !requireSource(c) {
+		return
+	}
+	if !beginOperation(c, "toggle-updates") {
 		return
 	}
+	defer endOperation()
 
-	if !isRoutineRunning {
+	stateMu.RLock()
+	running := isRoutineRunning
+	stateMu.RUnlock()
+
+	if !running {
+		namespace := namespaceParam(c)
+		if err := memgraph.EnsureNetwork(c.Request.Context(), Driver, namespace, lndNetwork()); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		stateMu.Lock()
 		stopChannel = make(chan struct{})
 		isRoutineRunning = true
-		go subscribeToGraphUpdates(stopChannel)
-		c.JSON(http.StatusOK, gin.H{"isRoutineRunning": true,
+		updatesNamespace = namespace
+		stop := stopChannel
+		stateMu.Unlock()
+		logf(c, "Starting graph update routine for namespace %q", namespace)
+		go subscribeToGraphUpdates(stop, namespace)
+		saveState()
+		c.JSON(http.StatusOK, gin.H{"isRoutineRunning": true, "namespace": namespace,
 			"message": "Routine started."})
 	} else {
+		logf(c, "Stopping graph update routine")
 		stopRoutine()
 		c.JSON(http.StatusOK, gin.H{"isRoutineRunning": false,
 			"message": "Routine stopped."})
 	}
 }
 
-// ResetGraphHandler drops the database, pulls a fresh graph from LND, writes it
-// to Memgraph, and runs post-import computations. Requires LND to be configured.
+// ResetGraphHandler drops the selected namespace, pulls a fresh graph from LND,
+// writes it to Memgraph, and runs post-import computations. Requires LND or
+// the mock source to be configured, unless ?use_cache=true rebuilds the
+// namespace from the graph cached by the last pull instead (see
+// lnd.CacheFile). If the client disconnects, the reset stops at the next
+// batch, leaving the namespace partially written.
 func ResetGraphHandler(c *gin.Context) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if !requireLND(c) {
+	useCache := c.Query("use_cache") == "true"
+	if !useCache && !requireSource(c) {
 		return
 	}
+	if !beginOperation(c, "reset-graph") {
+		return
+	}
+	defer endOperation()
 
-	log.Println("Graph update initiated...")
-	stopRoutine()
+	ctx := c.Request.Context()
+	namespace := namespaceParam(c)
+	network := lndNetwork()
+	var cached *lndclient.Graph
+	if useCache {
+		var (
+			pulledAt time.Time
+			err      error
+		)
+		cached, network, pulledAt, err = lnd.ReadGraphCache()
+		if errors.Is(err, lnd.ErrNoCache) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no graph has been cached yet"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		logf(c, "Using graph cached at %s", pulledAt.Format(time.RFC3339))
+	}
+	logf(c, "Graph update initiated, dropping namespace %q", namespace)
+	stopRoutineFor(namespace)
 
-	if err := memgraph.DropDatabase(Driver); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to drop database: %v", err)})
+	if err := memgraph.DropNamespace(ctx, Driver, namespace); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to drop namespace: %v", err)})
 		return
 	}
-	graph, err := lnd.PullGraph(LndServices)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to pull graph: %v", err)})
+	if err := memgraph.SetNetwork(ctx, Driver, namespace, network); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if err := lnd.WriteGraphToMemgraph(graph, Driver); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to write graph: %v", err)})
+	var err error
+	if cached != nil {
+		_, err = lnd.WriteGraphToMemgraph(ctx, cached, Driver, namespace)
+	} else {
+		_, err = lnd.ImportGraph(ctx, Source, Driver, namespace)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to import graph: %v", err)})
 		return
 	}
-	if err := memgraph.SetupAfterImport(Driver); err != nil {
+	if err := memgraph.SetupAfterImport(ctx, Driver, namespace); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("post-import setup failed: %v", err)})
 		return
 	}
 
+	refreshSummary(namespace)
+	logf(c, "Graph update complete")
 	c.String(http.StatusOK, "Graph update complete.")
 }
 
-// LoadLocalSnapshot drops the database and loads the graph from a local
-// describegraph.json snapshot. Does not require LND.
-func LoadLocalSnapshot(c *gin.Context) {
-	mu.Lock()
-	defer mu.Unlock()
+// importSnapshot validates a decoded snapshot, then drops the selected
+// namespace and loads the valid records. Invalid records are skipped and counted in the
+// response. With ?dry_run=true only the validation report is returned and the
+// database is left untouched. Must be called between beginOperation and endOperation.
+func importSnapshot(c *gin.Context, graph *lnd.Graph) {
+	network := snapshotNetwork(c, graph)
+	graph, report := lnd.ValidateSnapshot(graph)
+	logf(c, "Snapshot validated: %d/%d nodes and %d/%d channels valid", report.ValidNodes,
+		report.ValidNodes+report.InvalidNodes, report.ValidEdges, report.ValidEdges+report.InvalidEdges)
 
-	log.Println("Snapshot load initiated...")
-	stopRoutine()
+	namespace := namespaceParam(c)
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"message": "Dry run, nothing was written.", "dry_run": true,
+			"namespace": namespace, "network": network, "validation": report})
+		return
+	}
 
-	if err := memgraph.DropDatabase(Driver); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to drop database: %v", err)})
+	logf(c, "Snapshot load initiated, dropping namespace %q", namespace)
+	stopRoutineFor(namespace)
+
+	ctx := c.Request.Context()
+	if err := memgraph.DropNamespace(ctx, Driver, namespace); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to drop namespace: %v", err)})
+		return
+	}
+	if err := memgraph.SetNetwork(ctx, Driver, namespace, network); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if err := lnd.WriteSnapshotToMemgraph("./describegraph.json", Driver); err != nil {
+	if err := lnd.WriteSnapshotToMemgraph(ctx, graph, Driver, namespace); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to load snapshot: %v", err)})
 		return
 	}
-	if err := memgraph.SetupAfterImport(Driver); err != nil {
+	if err := memgraph.SetupAfterImport(ctx, Driver, namespace); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("post-import setup failed: %v", err)})
 		return
 	}
 
-	c.String(http.StatusOK, "Snapshot load complete.")
+	refreshSummary(namespace)
+	logf(c, "Snapshot load complete")
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot load complete.", "namespace": namespace, "network": network,
+		"validation": report})
+}
+
+// LoadLocalSnapshot loads the graph from describegraph.json in the snapshot
+// library, falling back to ./describegraph.json for setups that predate the
+// library. Does not require LND.
+func LoadLocalSnapshot(c *gin.Context) {
+	if !beginOperation(c, "load-local-snapshot") {
+		return
+	}
+	defer endOperation()
+
+	path := filepath.Join(SnapshotDir(), "describegraph.json")
+	if _, err := os.Stat(path); err != nil {
+		path = "./describegraph.json"
+	}
+	graph, err := lnd.ReadSnapshot(path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read snapshot: %v", err)})
+		return
+	}
+	importSnapshot(c, graph)
+}
+
+// LoadCLNSnapshot loads the graph from Core Lightning `listnodes` and
+// `listchannels` JSON output. The file locations default to ./listnodes.json
+// and ./listchannels.json and can be overridden with CLN_LISTNODES_PATH and
+// CLN_LISTCHANNELS_PATH.
+func LoadCLNSnapshot(c *gin.Context) {
+	if !beginOperation(c, "load-cln-snapshot") {
+		return
+	}
+	defer endOperation()
+
+	graph, err := cln.ReadSnapshot(envOrDefault("CLN_LISTNODES_PATH", "./listnodes.json"),
+		envOrDefault("CLN_LISTCHANNELS_PATH", "./listchannels.json"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read CLN snapshot: %v", err)})
+		return
+	}
+	importSnapshot(c, graph)
+}
+
+// LoadGossipStore loads the graph from a Core Lightning gossip_store file,
+// located at ./gossip_store unless overridden with CLN_GOSSIP_STORE_PATH.
+// ?verify=drop checks message signatures and skips messages that fail,
+// ?verify=flag keeps them marked with invalid_signature; GOSSIP_VERIFY sets
+// the default (off).
+func LoadGossipStore(c *gin.Context) {
+	verify, err := gossip.ParseVerifyMode(c.DefaultQuery("verify", os.Getenv("GOSSIP_VERIFY")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !beginOperation(c, "load-gossip-store") {
+		return
+	}
+	defer endOperation()
+
+	graph, invalid, err := cln.ReadGossipStore(envOrDefault("CLN_GOSSIP_STORE_PATH", "./gossip_store"), verify)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read gossip store: %v", err)})
+		return
+	}
+	if invalid.Malformed > 0 {
+		logf(c, "Skipped %d malformed gossip store messages", invalid.Malformed)
+	}
+	if verify != gossip.VerifyOff {
+		logf(c, "Gossip signatures checked (%s): %d channel announcements, %d channel updates and %d node announcements failed",
+			verify, invalid.ChannelAnnouncements, invalid.ChannelUpdates, invalid.NodeAnnouncements)
+	}
+	importSnapshot(c, graph)
 }
 
-// GetStatusHandler returns whether the graph update routine is currently running.
+// GetStatusHandler returns whether the graph update routine is running (and
+// into which namespace), which graph operation, if any, is in progress, and
+// which network the selected namespace belongs to. It never waits on a
+// running operation.
 func GetStatusHandler(c *gin.Context) {
-	mu.Lock()
-	defer mu.Unlock()
+	namespace := namespaceParam(c)
+	network, err := memgraph.GetNetwork(Driver, namespace)
+	if err != nil {
+		log.Printf("Failed to read graph network: %v", err)
+	}
+
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	var queue *memgraph.QueueStats
+	if updateQueue != nil {
+		stats := updateQueue.Stats()
+		queue = &stats
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"isRoutineRunning": isRoutineRunning,
+		"operation":        currentOperation,
+		"lastUpdateAt":     lastUpdateAt,
+		"updatesNamespace": updatesNamespace,
+		"queue":            queue,
+		"namespace":        namespace,
+		"network":          network,
+		"readOnly":         ReadOnly,
+		"databaseUp":       memgraph.DatabaseUp(),
+	})
+}
 
-	c.JSON(http.StatusOK, gin.H{"isRoutineRunning": isRoutineRunning})
+// subscriptionEnded handles the graph update subscription failing or its
+// stream closing by itself: it stops the routine owning stop, unless a newer
+// one has been started meanwhile, and saves the state so that updates are
+// not resumed after a restart. A subscription ended because ctx is done, on
+// shutdown, is left alone.
+func subscriptionEnded(ctx context.Context, stop <-chan struct{}) {
+	if ctx.Err() != nil {
+		return
+	}
+	stateMu.Lock()
+	ended := isRoutineRunning && stopChannel == stop
+	if ended {
+		close(stopChannel)
+		isRoutineRunning = false
+	}
+	stateMu.Unlock()
+	if ended {
+		saveState()
+	}
 }
 
-// subscribeToGraphUpdates subscribes to LND's graph topology update stream and
-// applies each update to Memgraph. Runs until the stop channel is closed.
-func subscribeToGraphUpdates(stop <-chan struct{}) {
-	graphUpdates, errors, err := LndServices.Client.SubscribeGraph(context.Background())
+// subscribeToGraphUpdates subscribes to the source's graph topology update
+// stream and applies each update to the given namespace. Updates pass through
+// an UpdateQueue so that slow writes never block the stream. Runs until the
+// stop channel is closed or BaseContext is done, which also ends the
+// subscription.
+func subscribeToGraphUpdates(stop <-chan struct{}, namespace string) {
+	ctx, cancel := context.WithCancel(BaseContext)
+	defer cancel()
+	defer memgraph.StartLive(namespace)()
+	graphUpdates, updateErrors, err := Source.SubscribeGraph(ctx)
 	if err != nil {
 		log.Printf("Failed to subscribe to graph updates: %v", err)
-		mu.Lock()
-		isRoutineRunning = false
-		mu.Unlock()
+		subscriptionEnded(ctx, stop)
 		return
 	}
 
+	queue := memgraph.NewUpdateQueue("lnd")
+	stateMu.Lock()
+	updateQueue = queue
+	stateMu.Unlock()
+	go queue.Run(stop, func(update *lndclient.GraphTopologyUpdate) {
+		memgraph.ProcessUpdates(ctx, Driver, namespace, update)
+		recordUpdateApplied()
+	})
+
+	// Catch up on channels closed while updates were not running.
+	if n, err := memgraph.MarkClosedChannels(ctx, Driver, namespace); err != nil {
+		log.Printf("Failed to backfill closed channels: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d channels closed while updates were not running", n)
+	}
+
 	log.Println("Subscribed to graph topology updates. Waiting for updates...")
 	for {
 		select {
-		case update := <-graphUpdates:
-			memgraph.ProcessUpdates(Driver, update)
-		case err := <-errors:
+		case update, ok := <-graphUpdates:
+			if !ok {
+				log.Println("Graph update subscription ended.")
+				subscriptionEnded(ctx, stop)
+				return
+			}
+			queue.Push(update)
+		case err, ok := <-updateErrors:
+			if !ok {
+				log.Println("Graph update subscription ended.")
+				subscriptionEnded(ctx, stop)
+				return
+			}
 			log.Printf("Error receiving graph update: %v", err)
 		case <-stop:
 			log.Println("Stopping graph update loop.")
 			return
+		case <-ctx.Done():
+			log.Println("Stopping graph update loop.")
+			return
 		}
 	}
 }
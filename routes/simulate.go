@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// simulatePaymentRequest is the body of a payment simulation. MaxRoutes
+// defaults to 3 and may be at most 10.
+type simulatePaymentRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	AmountSat   int64  `json:"amount_sat"`
+	MaxRoutes   int    `json:"max_routes"`
+}
+
+// SimulatePaymentHandler simulates route selection for a payment against the
+// stored policies and liquidity bounds of the selected namespace and returns
+// candidate routes with their fees and estimated success probability. With
+// ?convert=true, the amount and route fees are also given in BTC and fiat.
+func SimulatePaymentHandler(c *gin.Context) {
+	quote, ok := quoteParam(c)
+	if !ok {
+		return
+	}
+	var req simulatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if !lnd.IsValidPubKey(req.Source) || !lnd.IsValidPubKey(req.Destination) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination must be 66 hex characters"})
+		return
+	}
+	if req.Source == req.Destination {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination must differ"})
+		return
+	}
+	if req.AmountSat <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount_sat must be positive"})
+		return
+	}
+	if req.MaxRoutes == 0 {
+		req.MaxRoutes = 3
+	}
+	if req.MaxRoutes < 1 || req.MaxRoutes > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_routes must be between 1 and 10"})
+		return
+	}
+
+	result, err := memgraph.SimulatePayment(Driver, namespaceParam(c), req.Source, req.Destination,
+		req.AmountSat*1000, req.MaxRoutes)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to simulate payment: %v", err)})
+		return
+	}
+	amounts := map[string]float64{"amount_msat": float64(result.AmountMsat) / 1000}
+	for i, route := range result.Routes {
+		amounts[fmt.Sprintf("routes.%d.total_fee_msat", i)] = float64(route.TotalFeeMsat) / 1000
+	}
+	c.JSON(http.StatusOK, struct {
+		*memgraph.PaymentSimulation
+		Values *amountValues `json:"values,omitempty"`
+	}{result, convertAmounts(quote, amounts)})
+}
+
+// MaxFlowHandler returns the capacity-bounded max-flow between ?source= and
+// ?destination= over the selected namespace's directed channel graph, with
+// the channels of the minimum cut. With ?convert=true, the max-flow is also
+// given in BTC and fiat.
+func MaxFlowHandler(c *gin.Context) {
+	quote, ok := quoteParam(c)
+	if !ok {
+		return
+	}
+	source, destination := c.Query("source"), c.Query("destination")
+	if !lnd.IsValidPubKey(source) || !lnd.IsValidPubKey(destination) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination must be 66 hex characters"})
+		return
+	}
+	if source == destination {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and destination must differ"})
+		return
+	}
+
+	result, err := memgraph.MaxFlow(Driver, namespaceParam(c), source, destination)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute max-flow: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, struct {
+		*memgraph.MaxFlowResult
+		Values *amountValues `json:"values,omitempty"`
+	}{result, convertAmounts(quote, map[string]float64{"max_flow_sat": float64(result.MaxFlowSat)})})
+}
@@ -0,0 +1,307 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/objectstore"
+)
+
+// SnapshotInfo describes a snapshot file in the snapshot library.
+type SnapshotInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// errSnapshotNotFound is returned when a named snapshot is not in the library.
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// archive is the S3-compatible bucket holding the snapshot library, or nil
+// if the library is the local SNAPSHOT_DIR. Object keys are archivePrefix
+// followed by the snapshot name.
+var (
+	archive       *objectstore.Client
+	archivePrefix string
+)
+
+// ConfigureArchive moves the snapshot library to an S3-compatible bucket if
+// S3_BUCKET is set, so that archived dumps do not depend on a local volume.
+// S3_ENDPOINT selects a non-AWS service such as MinIO, S3_REGION the region
+// (default us-east-1), S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY and optionally
+// S3_SESSION_TOKEN the credentials, and S3_PREFIX the key prefix (default
+// snapshots/). Buckets are addressed path-style when S3_ENDPOINT is set,
+// unless S3_PATH_STYLE says otherwise.
+func ConfigureArchive() error {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	pathStyle := endpoint != ""
+	if v := os.Getenv("S3_PATH_STYLE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("S3_PATH_STYLE must be true or false, got %q", v)
+		}
+		pathStyle = b
+	}
+	client, err := objectstore.New(objectstore.Config{
+		Endpoint:        endpoint,
+		Region:          os.Getenv("S3_REGION"),
+		Bucket:          bucket,
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("S3_SESSION_TOKEN"),
+		PathStyle:       pathStyle,
+	})
+	if err != nil {
+		return err
+	}
+	archive = client
+	archivePrefix = envOrDefault("S3_PREFIX", "snapshots/")
+	log.Printf("Snapshot library stored in %s", ArchiveLocation())
+	return nil
+}
+
+// ArchiveLocation returns the s3:// URL of the snapshot library, or "" if it
+// is kept locally.
+func ArchiveLocation() string {
+	if archive == nil {
+		return ""
+	}
+	return fmt.Sprintf("s3://%s/%s", archive.Bucket(), archivePrefix)
+}
+
+// SnapshotDir returns the snapshot library directory, ./snapshots unless set
+// with SNAPSHOT_DIR.
+func SnapshotDir() string {
+	return envOrDefault("SNAPSHOT_DIR", "./snapshots")
+}
+
+// validSnapshotName rejects snapshot names that would escape the library.
+func validSnapshotName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// openSnapshot opens a snapshot in the library by name, returning
+// errSnapshotNotFound if there is none.
+func openSnapshot(name string) (io.ReadCloser, error) {
+	if archive != nil {
+		body, err := archive.Get(archivePrefix + name)
+		if errors.Is(err, objectstore.ErrNotFound) {
+			return nil, errSnapshotNotFound
+		}
+		return body, err
+	}
+	file, err := os.Open(filepath.Join(SnapshotDir(), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errSnapshotNotFound
+	}
+	return file, err
+}
+
+// listSnapshots returns the snapshots in the library, local or archived,
+// newest first.
+func listSnapshots() ([]SnapshotInfo, error) {
+	if archive != nil {
+		return listArchivedSnapshots()
+	}
+	return listLocalSnapshots(SnapshotDir())
+}
+
+// listLocalSnapshots returns the JSON files in dir, newest first. A missing
+// directory holds no snapshots.
+func listLocalSnapshots(dir string) ([]SnapshotInfo, error) {
+	return listLocalFiles(dir, ".json")
+}
+
+// listLocalFiles returns the files in dir whose names end in suffix, newest
+// first. A missing directory holds no files.
+func listLocalFiles(dir, suffix string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	snapshots := []SnapshotInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, SnapshotInfo{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Modified.After(snapshots[j].Modified) })
+	return snapshots, nil
+}
+
+// listArchivedSnapshots returns the JSON objects directly under the archive
+// prefix, newest first.
+func listArchivedSnapshots() ([]SnapshotInfo, error) {
+	objects, err := archive.List(archivePrefix)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []SnapshotInfo{}
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, archivePrefix)
+		if validSnapshotName(name) != nil {
+			continue
+		}
+		snapshots = append(snapshots, SnapshotInfo{Name: name, Size: object.Size, Modified: object.LastModified})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Modified.After(snapshots[j].Modified) })
+	return snapshots, nil
+}
+
+// dumpSnapshot archives a namespace's graph as a new timestamped snapshot,
+// in the bucket if one is configured and in dir otherwise. Returns the
+// snapshot's name and where it was written.
+func dumpSnapshot(namespace, dir string) (string, string, error) {
+	if archive == nil {
+		path, err := lnd.DumpSnapshot(Driver, namespace, dir)
+		if err != nil {
+			return "", "", err
+		}
+		return filepath.Base(path), path, nil
+	}
+
+	graph, err := lnd.ReadGraphFromMemgraph(Driver, namespace)
+	if err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(graph); err != nil {
+		return "", "", fmt.Errorf("failed to encode dump: %w", err)
+	}
+	name := lnd.DumpName(namespace, time.Now())
+	if err := archive.Put(archivePrefix+name, buf.Bytes(), "application/json"); err != nil {
+		return "", "", err
+	}
+	return name, ArchiveLocation() + name, nil
+}
+
+// DumpConfig configures automatic graph dumps.
+type DumpConfig struct {
+	Namespace string
+	// Dir is where dumps are written if no bucket is configured.
+	Dir       string
+	Interval  time.Duration
+	Retention RetentionPolicy
+}
+
+// Dumps is the automatic dump configuration, nil if dumps are disabled. It
+// must be set before RunSnapshotDumps is started.
+var Dumps *DumpConfig
+
+// RunSnapshotDumps archives a namespace's graph as configured by Dumps every
+// interval until stop is closed, and applies the dump retention policy after
+// each dump. Dumps go to the bucket if one is configured and to the dump
+// directory otherwise.
+func RunSnapshotDumps(stop <-chan struct{}) {
+	config := *Dumps
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, location, err := dumpSnapshot(config.Namespace, config.Dir)
+			if err != nil {
+				log.Printf("Graph dump failed: %v", err)
+				continue
+			}
+			log.Printf("Dumped namespace %q to %s", config.Namespace, location)
+			deleted, err := pruneDumps(config.Namespace, config.Dir, config.Retention)
+			if err != nil {
+				log.Printf("Dump pruning failed: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Pruned %d old dumps of namespace %q", deleted, config.Namespace)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ListSnapshotsHandler returns the snapshots in the library with their size
+// and modification time, newest first.
+func ListSnapshotsHandler(c *gin.Context) {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// LoadSnapshotHandler imports a describegraph snapshot from the library into
+// the selected namespace. Supports ?dry_run=true like the other snapshot loads.
+func LoadSnapshotHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := validSnapshotName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	snapshot, err := openSnapshot(name)
+	if errors.Is(err, errSnapshotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to open snapshot: %v", err)})
+		return
+	}
+	defer snapshot.Close()
+
+	if !beginOperation(c, "load-snapshot") {
+		return
+	}
+	defer endOperation()
+
+	graph, err := lnd.DecodeSnapshot(snapshot)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read snapshot: %v", err)})
+		return
+	}
+	logf(c, "Loading snapshot %s", name)
+	importSnapshot(c, graph)
+}
+
+// DumpSnapshotHandler writes the selected namespace's graph to a new
+// timestamped snapshot in the library and returns its name.
+func DumpSnapshotHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	namespace := namespaceParam(c)
+	name, location, err := dumpSnapshot(namespace, SnapshotDir())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Dumped namespace %q to %s", namespace, location)
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot written.", "name": name})
+}
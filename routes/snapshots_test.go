@@ -0,0 +1,29 @@
+package routes
+
+import "testing"
+
+func TestValidSnapshotName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"graph-2024-01-01.json", true},
+		{"", false},
+		{".json", false},
+		{".hidden.json", false},
+		{"../graph.json", false},
+		{"../../etc/passwd.json", false},
+		{"dir/graph.json", false},
+		{"/tmp/graph.json", false},
+		{"..", false},
+		{"graph.txt", false},
+		{"graph.json.gz", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validSnapshotName(test.name); (err == nil) != test.valid {
+				t.Errorf("validSnapshotName(%q) = %v, want valid %v", test.name, err, test.valid)
+			}
+		})
+	}
+}
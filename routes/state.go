@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"ln-stream/memgraph"
+)
+
+// stateSaveInterval limits how often the last processed update time is
+// written to disk while updates are streaming.
+const stateSaveInterval = 30 * time.Second
+
+// persistedState is the part of the routine state that survives restarts.
+type persistedState struct {
+	UpdatesEnabled  bool      `json:"updates_enabled"`
+	Namespace       string    `json:"namespace,omitempty"`
+	LastUpdateAt    time.Time `json:"last_update_at"`
+	ActiveNamespace string    `json:"active_namespace,omitempty"`
+}
+
+var (
+	// lastUpdateAt is the time the most recent graph update was applied. It
+	// is restored on startup, so /get-status keeps reporting it after a
+	// restart until updates resume. Protected by stateMu.
+	lastUpdateAt time.Time
+	// lastStateSave is only touched by the update goroutine.
+	lastStateSave time.Time
+)
+
+// stateFilePath returns where the routine state is persisted. It defaults to
+// ./ln-stream-state.json and can be set with STATE_FILE.
+func stateFilePath() string {
+	return envOrDefault("STATE_FILE", "./ln-stream-state.json")
+}
+
+// saveState writes the current routine state to disk. Failures are logged
+// since losing the state only affects the next restart.
+func saveState() {
+	stateMu.RLock()
+	state := persistedState{UpdatesEnabled: isRoutineRunning, Namespace: updatesNamespace, LastUpdateAt: lastUpdateAt,
+		ActiveNamespace: activeNamespace}
+	stateMu.RUnlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Failed to encode state: %v", err)
+		return
+	}
+	// Write to a temporary file first so a crash never leaves a truncated state file.
+	path := stateFilePath()
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		log.Printf("Failed to write state file: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("Failed to write state file: %v", err)
+	}
+}
+
+// recordUpdateApplied notes that a graph update was applied and persists the
+// timestamp at most once per stateSaveInterval.
+func recordUpdateApplied() {
+	stateMu.Lock()
+	lastUpdateAt = time.Now()
+	stateMu.Unlock()
+
+	if time.Since(lastStateSave) >= stateSaveInterval {
+		lastStateSave = time.Now()
+		saveState()
+	}
+}
+
+// RestoreState loads the persisted routine state, including the active
+// dataset, and restarts the graph update routine if it was enabled before
+// the last shutdown. Should be called once at
+// startup, after Source and Driver are set.
+func RestoreState(ctx context.Context) error {
+	data, err := os.ReadFile(stateFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode state file: %w", err)
+	}
+
+	stateMu.Lock()
+	lastUpdateAt = state.LastUpdateAt
+	activeNamespace = state.ActiveNamespace
+	stateMu.Unlock()
+	if state.ActiveNamespace != "" {
+		log.Printf("Serving dataset %q by default", state.ActiveNamespace)
+	}
+
+	if !state.UpdatesEnabled {
+		return nil
+	}
+	if Source == nil {
+		log.Println("Graph updates were enabled before restart, but LND is not configured")
+		return nil
+	}
+	namespace := state.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace()
+	}
+	if err := memgraph.EnsureNetwork(ctx, Driver, namespace, lndNetwork()); err != nil {
+		return fmt.Errorf("not resuming graph updates: %w", err)
+	}
+
+	stateMu.Lock()
+	stopChannel = make(chan struct{})
+	isRoutineRunning = true
+	updatesNamespace = namespace
+	stop := stopChannel
+	stateMu.Unlock()
+	log.Printf("Resuming graph updates into namespace %q (last update applied at %s)", namespace,
+		state.LastUpdateAt.Format(time.RFC3339))
+	go subscribeToGraphUpdates(stop, namespace)
+	return nil
+}
@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/memgraph"
+)
+
+var (
+	// summaryMu protects summaries, the cached network summary per namespace.
+	// It is separate from opMu so that reading stats never waits on a running import.
+	summaryMu sync.Mutex
+	summaries = map[string]*memgraph.NetworkSummary{}
+)
+
+// refreshSummary recomputes the cached network summary of a namespace. Called
+// after every successful sync; failures are logged and leave the previous
+// summary in place.
+func refreshSummary(namespace string) {
+	s, err := memgraph.ComputeNetworkSummary(Driver, namespace)
+	if err != nil {
+		log.Printf("Failed to refresh network summary for namespace %q: %v", namespace, err)
+		return
+	}
+	summaryMu.Lock()
+	summaries[namespace] = s
+	summaryMu.Unlock()
+}
+
+// NetworkSummaryHandler returns percentile breakdowns of channel capacity,
+// base fee and fee rate. The summary is computed on first use and cached
+// until the next sync of the namespace. With ?convert=true, the capacity
+// percentiles are also given in BTC and fiat at the current price.
+func NetworkSummaryHandler(c *gin.Context) {
+	quote, ok := quoteParam(c)
+	if !ok {
+		return
+	}
+	namespace := namespaceParam(c)
+	summaryMu.Lock()
+	s := summaries[namespace]
+	summaryMu.Unlock()
+
+	if s == nil {
+		var err error
+		s, err = memgraph.ComputeNetworkSummary(Driver, namespace)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute summary: %v", err)})
+			return
+		}
+		summaryMu.Lock()
+		summaries[namespace] = s
+		summaryMu.Unlock()
+	}
+
+	c.JSON(http.StatusOK, struct {
+		*memgraph.NetworkSummary
+		Values *amountValues `json:"values,omitempty"`
+	}{s, convertAmounts(quote, map[string]float64{
+		"capacity.p10": s.Capacity.P10,
+		"capacity.p50": s.Capacity.P50,
+		"capacity.p90": s.Capacity.P90,
+		"capacity.p99": s.Capacity.P99,
+	})})
+}
+
+// FeeHistogramHandler returns histograms of base fee and fee rate across
+// enabled channel directions. The bucket count defaults to 20 and can be set
+// with ?buckets= (1-1000).
+func FeeHistogramHandler(c *gin.Context) {
+	buckets, err := strconv.Atoi(c.DefaultQuery("buckets", "20"))
+	if err != nil || buckets < 1 || buckets > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "buckets must be an integer between 1 and 1000"})
+		return
+	}
+
+	histograms, err := memgraph.ComputeFeeHistograms(Driver, namespaceParam(c), buckets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute fee histograms: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, histograms)
+}
+
+// DegreeDistributionHandler returns the number of nodes per channel count.
+// With ?weighted=true it also returns a histogram (?buckets=, default 20) of
+// each node's total channel capacity.
+func DegreeDistributionHandler(c *gin.Context) {
+	weighted := c.Query("weighted") == "true"
+	buckets, err := strconv.Atoi(c.DefaultQuery("buckets", "20"))
+	if err != nil || buckets < 1 || buckets > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "buckets must be an integer between 1 and 1000"})
+		return
+	}
+
+	dist, err := memgraph.ComputeDegreeDistribution(Driver, namespaceParam(c), weighted, buckets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute degree distribution: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, dist)
+}
+
+// ConnectivityStatsHandler returns the number of nodes and their capacity per
+// connectivity class (clearnet, tor, hybrid, none), and how many nodes
+// advertise IPv4, IPv6 and Tor addresses. List the nodes of a class with
+// /api/nodes?connectivity=.
+func ConnectivityStatsHandler(c *gin.Context) {
+	stats, err := memgraph.ComputeConnectivityStats(Driver, namespaceParam(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to compute connectivity stats: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// MetricsHandler exposes update queue counters, write rates and update lag in
+// the Prometheus text format.
+func MetricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	memgraph.WriteMetrics(c.Writer)
+}
+
+// CriticalElementsHandler returns the articulation points and bridges of the
+// selected namespace, as tagged after the last import. With ?refresh=true
+// they are recomputed first, e.g. after live updates changed the graph; this
+// takes the operation lock since it modifies the graph.
+func CriticalElementsHandler(c *gin.Context) {
+	namespace := namespaceParam(c)
+	if c.Query("refresh") == "true" {
+		if !beginOperation(c, "critical-refresh") {
+			return
+		}
+		defer endOperation()
+		if err := memgraph.TagCriticalElements(c.Request.Context(), Driver, namespace); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	result, err := memgraph.GetCriticalElements(Driver, namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
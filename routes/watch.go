@@ -0,0 +1,182 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// watchRequest is the body of a watch registration: a pubkey for node
+// watches, a channel_id for channel watches.
+type watchRequest struct {
+	Pubkey    string `json:"pubkey"`
+	ChannelID string `json:"channel_id"`
+}
+
+// WatchNodeHandler registers a node watch for the selected namespace. Changes
+// to the node's announcement or its channels' policies are recorded as events
+// from then on.
+func WatchNodeHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	var req watchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if !lnd.IsValidPubKey(req.Pubkey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pubkey must be 66 hex characters"})
+		return
+	}
+
+	namespace := namespaceParam(c)
+	if err := memgraph.AddWatch(c.Request.Context(), Driver, namespace, memgraph.WatchNode, req.Pubkey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Watching node %s in namespace %q", req.Pubkey, namespace)
+	c.JSON(http.StatusOK, gin.H{"message": "Node watched.", "pubkey": req.Pubkey, "namespace": namespace})
+}
+
+// ListNodeWatchesHandler returns the watched nodes of the selected namespace.
+func ListNodeWatchesHandler(c *gin.Context) {
+	watches, err := memgraph.ListWatches(Driver, namespaceParam(c), memgraph.WatchNode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list watches: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, watches)
+}
+
+// UnwatchNodeHandler removes a node watch. Recorded events are kept.
+func UnwatchNodeHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	err := memgraph.RemoveWatch(c.Request.Context(), Driver, namespaceParam(c), memgraph.WatchNode, c.Param("pubkey"))
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node is not watched"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node unwatched."})
+}
+
+// WatchChannelHandler registers a channel watch for the selected namespace.
+// The channel ID may be given as BLOCKxTXxOUTPUT, BLOCK:TX:OUTPUT or as a
+// numeric short channel ID. Every policy change, disable/enable flip and the
+// close of the channel are recorded as events from then on.
+func WatchChannelHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	var req watchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	chanID, err := memgraph.NormalizeChannelID(req.ChannelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	namespace := namespaceParam(c)
+	if err := memgraph.AddWatch(c.Request.Context(), Driver, namespace, memgraph.WatchChannel, chanID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logf(c, "Watching channel %s in namespace %q", chanID, namespace)
+	c.JSON(http.StatusOK, gin.H{"message": "Channel watched.", "channel_id": chanID, "namespace": namespace})
+}
+
+// ListChannelWatchesHandler returns the watched channels of the selected namespace.
+func ListChannelWatchesHandler(c *gin.Context) {
+	watches, err := memgraph.ListWatches(Driver, namespaceParam(c), memgraph.WatchChannel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list watches: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, watches)
+}
+
+// UnwatchChannelHandler removes a channel watch. Recorded events are kept.
+func UnwatchChannelHandler(c *gin.Context) {
+	if blockChange(c) {
+		return
+	}
+	chanID, err := memgraph.NormalizeChannelID(c.Param("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = memgraph.RemoveWatch(c.Request.Context(), Driver, namespaceParam(c), memgraph.WatchChannel, chanID)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel is not watched"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Channel unwatched."})
+}
+
+// WatchEventsHandler returns recorded watch events, oldest first. Filters:
+// ?kind= (node or channel), ?target=, ?since= (unix seconds or RFC 3339) and
+// ?limit= (default 100, at most 10000). Channel targets may be given in any
+// form accepted by NormalizeChannelID.
+func WatchEventsHandler(c *gin.Context) {
+	target := c.Query("target")
+	if c.Query("kind") == memgraph.WatchChannel && target != "" {
+		chanID, err := memgraph.NormalizeChannelID(target)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		target = chanID
+	}
+	since, err := parseSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 10000"})
+		return
+	}
+
+	events, err := memgraph.ListWatchEvents(Driver, namespaceParam(c), c.Query("kind"), target, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list events: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// parseSince parses a ?since= value given as unix seconds or RFC 3339. An
+// empty value means the beginning of time.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be unix seconds or RFC 3339, got %q", value)
+	}
+	return t, nil
+}
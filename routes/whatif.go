@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ln-stream/lnd"
+	"ln-stream/memgraph"
+)
+
+// whatIfRequest is the body of a what-if simulation. AmountSat defaults to
+// 10000 and Limit, the number of centrality changes returned, to 20.
+type whatIfRequest struct {
+	memgraph.WhatIfScenario
+	AmountSat int64 `json:"amount_sat"`
+	Limit     int   `json:"limit"`
+}
+
+// WhatIfHandler applies hypothetical node and channel removals and channel
+// additions to an in-memory copy of the selected namespace's graph and
+// reports their impact on connectivity, on the shortest paths between the
+// given pairs and on node betweenness. The stored graph is not changed.
+func WhatIfHandler(c *gin.Context) {
+	var req whatIfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if len(req.RemoveNodes)+len(req.RemoveChannels)+len(req.AddChannels) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "remove_nodes, remove_channels or add_channels must not be empty"})
+		return
+	}
+	for _, pubKey := range req.RemoveNodes {
+		if !lnd.IsValidPubKey(pubKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "remove_nodes must be 66 hex characters each"})
+			return
+		}
+	}
+	for _, ch := range req.AddChannels {
+		if !lnd.IsValidPubKey(ch.Node1) || !lnd.IsValidPubKey(ch.Node2) || ch.Node1 == ch.Node2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add_channels must connect two different 66 hex character pubkeys"})
+			return
+		}
+		if ch.Capacity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add_channels capacity must be positive"})
+			return
+		}
+	}
+	if len(req.Pairs) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most 20 pairs may be given"})
+		return
+	}
+	for _, pair := range req.Pairs {
+		if !lnd.IsValidPubKey(pair.Source) || !lnd.IsValidPubKey(pair.Destination) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pair source and destination must be 66 hex characters"})
+			return
+		}
+	}
+	if req.AmountSat == 0 {
+		req.AmountSat = 10000
+	}
+	if req.AmountSat < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount_sat must be positive"})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	if req.Limit < 1 || req.Limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer between 1 and 1000"})
+		return
+	}
+	req.AmountMsat = req.AmountSat * 1000
+
+	result, err := memgraph.WhatIf(Driver, namespaceParam(c), req.WhatIfScenario, req.Limit)
+	if errors.Is(err, memgraph.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to simulate scenario: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -7,6 +7,12 @@ fetch('/get-status')
         updateIndicator(data.isRoutineRunning);
         toggleStatus = data.isRoutineRunning;
         updateToggleButton();
+        if (data.readOnly) {
+            // Every control changes the graph, so hide them on read-only instances.
+            ["resetButton", "toggleButton", "localButton", "snapshotSelect", "snapshotButton"].forEach(id => {
+                document.getElementById(id).hidden = true;
+            });
+        }
     })
     .catch(error => {
         // Handle error if the request fails
@@ -82,6 +88,43 @@ document.getElementById("localButton").onclick = function () {
     });
 };
 
+// Fill the snapshot picker from the snapshot library.
+function loadSnapshotList() {
+    fetch('/api/snapshots', {cache: "no-store"})
+        .then(response => response.json())
+        .then(snapshots => {
+            var select = document.getElementById("snapshotSelect");
+            select.innerHTML = "";
+            snapshots.forEach(snapshot => {
+                var option = document.createElement("option");
+                option.value = snapshot.name;
+                option.textContent = snapshot.name + " (" + (snapshot.size / 1e6).toFixed(1) + " MB, " +
+                    new Date(snapshot.modified).toLocaleDateString() + ")";
+                select.appendChild(option);
+            });
+            document.getElementById("snapshotButton").disabled = snapshots.length === 0;
+        })
+        .catch(error => {
+            console.error("Failed to list snapshots:", error);
+        });
+}
+
+document.getElementById("snapshotButton").onclick = function () {
+    var name = document.getElementById("snapshotSelect").value;
+    alert("Loading snapshot " + name + ".");
+    disableButtons();
+    fetch('/api/snapshots/' + encodeURIComponent(name) + '/load', {method: "POST"}).then(response => {
+        if (response.ok) {
+            alert("Snapshot loaded.");
+        } else {
+            alert("Failed to load snapshot.");
+        }
+        enableButtons();
+    });
+};
+
+loadSnapshotList();
+
 document.getElementById("resetButton").onclick = function () {
     alert("Graph reset initiated.");
     disableButtons(); // Disable buttons when the request is initiated
@@ -99,4 +142,28 @@ document.getElementById("toggleButton").onclick = function () {
     fetchToggleUpdates();
 };
 
-updateToggleButton();
\ No newline at end of file
+// Keep the indicator and counters current over a WebSocket, reconnecting if it drops.
+function connectLiveCounters() {
+    var protocol = location.protocol === "https:" ? "wss:" : "ws:";
+    var socket = new WebSocket(protocol + "//" + location.host + "/ws/live");
+
+    socket.onmessage = function (event) {
+        var data = JSON.parse(event.data);
+        document.getElementById("nodeCount").textContent = data.nodes;
+        document.getElementById("channelCount").textContent = data.channels;
+        document.getElementById("updateCount").textContent = data.updatesApplied;
+        var lastUpdate = new Date(data.lastUpdateAt);
+        document.getElementById("lastUpdate").textContent =
+            lastUpdate.getFullYear() > 1 ? lastUpdate.toLocaleTimeString() : "-";
+        toggleStatus = data.isRoutineRunning;
+        updateIndicator(data.isRoutineRunning);
+        updateToggleButton();
+    };
+
+    socket.onclose = function () {
+        setTimeout(connectLiveCounters, 5000);
+    };
+}
+
+updateToggleButton();
+connectLiveCounters();
@@ -0,0 +1,219 @@
+// Package synthetic generates fake Lightning Network topologies for
+// benchmarking and teaching. Channels are attached by preferential
+// attachment (Barabási–Albert), which reproduces the hub-dominated degree
+// distribution of the real network, and capacities and fees are drawn from
+// configurable distributions.
+package synthetic
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"ln-stream/lnd"
+)
+
+// Network is the network recorded for generated graphs, so that live data
+// is never mixed into them.
+const Network = "synthetic"
+
+// Limits on the size of generated graphs.
+const (
+	MaxNodes    = 200_000
+	MaxChannels = 2_000_000
+)
+
+// Capacity selects the distribution channel capacities (in satoshis) are
+// drawn from. Draws are clamped to [Min, Max].
+type Capacity struct {
+	// Distribution is "lognormal" (default), "uniform" or "pareto".
+	Distribution string `json:"distribution"`
+	// Median and Sigma parameterize the lognormal distribution.
+	Median int64   `json:"median"`
+	Sigma  float64 `json:"sigma"`
+	// Alpha is the shape of the pareto distribution, whose scale is Min.
+	Alpha float64 `json:"alpha"`
+	Min   int64   `json:"min"`
+	Max   int64   `json:"max"`
+}
+
+// Config describes the graph to generate. Zero values take the defaults
+// filled in by WithDefaults.
+type Config struct {
+	Nodes int `json:"nodes"`
+	// Channels is the total number of channels. The first nodes form a
+	// fully connected core; every later node then opens about
+	// Channels/Nodes channels to existing nodes, chosen with probability
+	// proportional to their degree.
+	Channels int      `json:"channels"`
+	Capacity Capacity `json:"capacity"`
+	// Seed makes generation reproducible; 0 picks a random seed.
+	Seed int64 `json:"seed"`
+}
+
+// WithDefaults returns c with unset fields filled in: 1000 nodes, four
+// channels per node, and lognormal capacities around 2M sat.
+func (c Config) WithDefaults() Config {
+	if c.Nodes == 0 {
+		c.Nodes = 1000
+	}
+	if c.Channels == 0 {
+		c.Channels = 4 * c.Nodes
+	}
+	if c.Capacity.Distribution == "" {
+		c.Capacity.Distribution = "lognormal"
+	}
+	if c.Capacity.Median == 0 {
+		c.Capacity.Median = 2_000_000
+	}
+	if c.Capacity.Sigma == 0 {
+		c.Capacity.Sigma = 1.2
+	}
+	if c.Capacity.Alpha == 0 {
+		c.Capacity.Alpha = 1.2
+	}
+	if c.Capacity.Min == 0 {
+		c.Capacity.Min = 20_000
+	}
+	if c.Capacity.Max == 0 {
+		c.Capacity.Max = 1_000_000_000
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	return c
+}
+
+// Validate reports why a configuration (with defaults applied) cannot be
+// generated.
+func (c Config) Validate() error {
+	if c.Nodes < 2 || c.Nodes > MaxNodes {
+		return fmt.Errorf("nodes must be between 2 and %d", MaxNodes)
+	}
+	if c.Channels > MaxChannels {
+		return fmt.Errorf("channels must be at most %d", MaxChannels)
+	}
+	// The core needs one node more than the number of channels each later
+	// node opens, and every later node opens at least one.
+	if c.Channels < c.Nodes-1 {
+		return fmt.Errorf("channels must be at least nodes-1 (%d) to connect every node", c.Nodes-1)
+	}
+	if perNode := c.Channels / c.Nodes; perNode+1 > c.Nodes {
+		return errors.New("too many channels for the number of nodes")
+	}
+	switch c.Capacity.Distribution {
+	case "lognormal", "uniform", "pareto":
+	default:
+		return fmt.Errorf("unknown capacity distribution %q", c.Capacity.Distribution)
+	}
+	if c.Capacity.Min <= 0 || c.Capacity.Max < c.Capacity.Min {
+		return errors.New("capacity min must be positive and at most max")
+	}
+	if c.Capacity.Sigma < 0 || c.Capacity.Alpha <= 0 {
+		return errors.New("capacity sigma must be non-negative and alpha positive")
+	}
+	return nil
+}
+
+// Generate builds a graph in the describegraph snapshot format, so that it
+// can be imported, validated and exported like any snapshot. The
+// configuration must have defaults applied and be valid.
+func Generate(c Config) *lnd.Graph {
+	rng := rand.New(rand.NewSource(c.Seed))
+	now := time.Now().Unix()
+	graph := &lnd.Graph{Network: Network, Nodes: make([]lnd.Node, c.Nodes), Edges: make([]lnd.ChannelEdge, 0, c.Channels)}
+	for i := range graph.Nodes {
+		pubKey := make([]byte, 33)
+		rng.Read(pubKey[1:])
+		pubKey[0] = byte(2 + rng.Intn(2))
+		graph.Nodes[i] = lnd.Node{
+			Pub_Key:    fmt.Sprintf("%x", pubKey),
+			LastUpdate: now,
+			Alias:      fmt.Sprintf("synthetic-%d", i),
+			Color:      fmt.Sprintf("#%06x", rng.Intn(1<<24)),
+			Features:   map[string]interface{}{},
+			Addresses:  []interface{}{},
+		}
+	}
+
+	// endpoints lists every channel end, so picking a uniform element picks
+	// a node with probability proportional to its degree.
+	var endpoints []int
+	height := uint64(700_000)
+	connect := func(a, b int) {
+		height += uint64(rng.Intn(3))
+		scid := height<<40 | uint64(rng.Intn(3000))<<16 | uint64(rng.Intn(2))
+		capacity := c.Capacity.draw(rng)
+		graph.Edges = append(graph.Edges, lnd.ChannelEdge{
+			ChannelId:   strconv.FormatUint(scid, 10),
+			LastUpdate:  now,
+			Capacity:    strconv.FormatInt(capacity, 10),
+			Node1_Pub:   graph.Nodes[a].Pub_Key,
+			Node2_Pub:   graph.Nodes[b].Pub_Key,
+			Node1Policy: randomPolicy(rng, capacity, now),
+			Node2Policy: randomPolicy(rng, capacity, now),
+		})
+		endpoints = append(endpoints, a, b)
+	}
+
+	perNode := max(1, c.Channels/c.Nodes)
+	core := perNode + 1
+	for a := 0; a < core; a++ {
+		for b := a + 1; b < core && len(graph.Edges) < c.Channels; b++ {
+			connect(a, b)
+		}
+	}
+	for n := core; n < c.Nodes; n++ {
+		// Spread what is left of the channel budget evenly over the
+		// remaining nodes, each opening at least one channel.
+		remaining := c.Channels - len(graph.Edges)
+		opens := max(1, min(n, remaining/(c.Nodes-n)))
+		if extra := remaining % (c.Nodes - n); extra > 0 && rng.Intn(c.Nodes-n) < extra && opens < n {
+			opens++
+		}
+		peers := map[int]bool{}
+		for len(peers) < opens {
+			peers[endpoints[rng.Intn(len(endpoints))]] = true
+		}
+		for peer := range peers {
+			connect(n, peer)
+		}
+	}
+	return graph
+}
+
+// draw returns a capacity from the configured distribution.
+func (c Capacity) draw(rng *rand.Rand) int64 {
+	var v float64
+	switch c.Distribution {
+	case "uniform":
+		v = float64(c.Min) + rng.Float64()*float64(c.Max-c.Min)
+	case "pareto":
+		v = float64(c.Min) / math.Pow(1-rng.Float64(), 1/c.Alpha)
+	default:
+		v = float64(c.Median) * math.Exp(c.Sigma*rng.NormFloat64())
+	}
+	return min(c.Max, max(c.Min, int64(v)))
+}
+
+// randomPolicy returns a routing policy with fees resembling the real
+// network's: mostly small proportional fees and a base fee of 0 or 1 sat.
+func randomPolicy(rng *rand.Rand, capacity, now int64) lnd.RoutingPolicy {
+	feeBase := 0
+	if rng.Intn(2) == 0 {
+		feeBase = 1000
+	}
+	feeRate := int64(math.Exp(math.Log(100) + 1.5*rng.NormFloat64()))
+	return lnd.RoutingPolicy{
+		TimeLockDelta:    []int{40, 80, 144}[rng.Intn(3)],
+		MinHtlc:          "1000",
+		FeeBaseMsat:      strconv.Itoa(feeBase),
+		FeeRateMilliMsat: strconv.FormatInt(min(5000, feeRate), 10),
+		Disabled:         rng.Intn(50) == 0,
+		MaxHtlcMsat:      strconv.FormatInt(capacity*990, 10),
+		LastUpdate:       int(now),
+	}
+}